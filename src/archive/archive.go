@@ -0,0 +1,320 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/patppuccin/viewr/src/resolver"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+type Format string
+
+const (
+	FormatZip   Format = "zip"
+	FormatTarGz Format = "tar.gz"
+)
+
+var ErrLimitExceeded = errors.New("archive size limit exceeded")
+
+// Name of the checksum manifest added to batch archives
+const ManifestName = "MANIFEST.sha256"
+
+// Already-compressed formats are stored as-is in ZIP archives, deflating them
+// again only burns CPU
+var storedExtensions = []string{
+	".7z", ".avi", ".bz2", ".flac", ".gif", ".gz", ".jpeg", ".jpg", ".mkv", ".mov",
+	".mp3", ".mp4", ".ogg", ".png", ".rar", ".tgz", ".webm", ".webp", ".xz", ".zip", ".zst",
+}
+
+type Limits struct {
+	MaxBytes int64
+	MaxFiles int
+}
+
+// Entry is a single file or folder scheduled for an archive
+type Entry struct {
+	Name    string // Slash-separated path, relative to the backend root
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	IsDir   bool
+}
+
+func ParseFormat(s string) (Format, bool) {
+	switch strings.ToLower(s) {
+	case "", "zip":
+		return FormatZip, true
+	case "tar.gz", "tgz", "targz":
+		return FormatTarGz, true
+	}
+	return "", false
+}
+
+func (f Format) ContentType() string {
+	if f == FormatTarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Collect walks the given paths of a backend and returns the entries to archive,
+// named relative to the backend root. Symlinks the backend refuses to follow are
+// skipped and symlinked folders are never descended into, so archives can't loop.
+// Limits are enforced up front, before anything is written to the client.
+func Collect(ctx context.Context, backend storage.Backend, relPaths []string, limits Limits) ([]Entry, error) {
+	var (
+		entries    []Entry
+		totalBytes int64
+		fileCount  int
+		seen       = map[string]bool{}
+	)
+
+	add := func(entry Entry) error {
+		if seen[entry.Name] {
+			return nil
+		}
+		seen[entry.Name] = true
+
+		if !entry.IsDir {
+			fileCount++
+			totalBytes += entry.Size
+			if (limits.MaxFiles > 0 && fileCount > limits.MaxFiles) || (limits.MaxBytes > 0 && totalBytes > limits.MaxBytes) {
+				return ErrLimitExceeded
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	}
+
+	for _, relPath := range relPaths {
+		cleanPath, err := resolver.Clean(relPath)
+		if err != nil {
+			return nil, err
+		}
+
+		err = fs.WalkDir(backend, storage.Name(cleanPath), func(name string, de fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var info fs.FileInfo
+			if de.Type()&fs.ModeSymlink != 0 {
+				if info, err = backend.Stat(name); err != nil || info.IsDir() {
+					return nil
+				}
+			} else if info, err = de.Info(); err != nil {
+				return nil
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				return nil
+			}
+
+			if name == "." {
+				name = ""
+			}
+			return add(Entry{
+				Name:    name,
+				Size:    info.Size(),
+				Mode:    info.Mode(),
+				ModTime: info.ModTime(),
+				IsDir:   info.IsDir(),
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// Write streams the entries into w as the given archive format. The prefix is
+// stripped from entry names, so downloading "docs/guides" with prefix "docs"
+// yields "guides/..." inside the archive.
+func Write(ctx context.Context, w io.Writer, backend storage.Backend, format Format, prefix string, entries []Entry) error {
+	return write(ctx, w, backend, format, prefix, entries, false)
+}
+
+// WriteWithManifest is Write plus a trailing ManifestName file that lists the
+// source path and SHA-256 checksum of every file, in sha256sum(1) format.
+func WriteWithManifest(ctx context.Context, w io.Writer, backend storage.Backend, format Format, prefix string, entries []Entry) error {
+	return write(ctx, w, backend, format, prefix, entries, true)
+}
+
+func write(ctx context.Context, w io.Writer, backend storage.Backend, format Format, prefix string, entries []Entry, withManifest bool) error {
+	var aw archiveWriter
+	switch format {
+	case FormatTarGz:
+		gw, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+		if err != nil {
+			return err
+		}
+		aw = &tarGzWriter{gw: gw, tw: tar.NewWriter(gw)}
+	default:
+		zw := zip.NewWriter(w)
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, flate.BestSpeed)
+		})
+		aw = &zipWriter{zw: zw}
+	}
+
+	var manifest bytes.Buffer
+	for _, entry := range entries {
+		name := archiveName(prefix, entry.Name)
+		if name == "" {
+			continue
+		}
+
+		fw, err := aw.create(name, entry)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir {
+			continue
+		}
+
+		hash := sha256.New()
+		if err := copyFile(ctx, io.MultiWriter(fw, hash), backend, entry); err != nil {
+			return err
+		}
+		if withManifest {
+			manifest.WriteString(hex.EncodeToString(hash.Sum(nil)) + "  " + entry.Name + "\n")
+		}
+	}
+
+	if withManifest {
+		fw, err := aw.create(ManifestName, Entry{
+			Size:    int64(manifest.Len()),
+			Mode:    0o644,
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := manifest.WriteTo(fw); err != nil {
+			return err
+		}
+	}
+
+	return aw.close()
+}
+
+// Archive format writers
+
+type archiveWriter interface {
+	create(name string, entry Entry) (io.Writer, error)
+	close() error
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (a *zipWriter) create(name string, entry Entry) (io.Writer, error) {
+	header := &zip.FileHeader{
+		Name:     name,
+		Modified: entry.ModTime,
+		Method:   zip.Deflate,
+	}
+	header.SetMode(entry.Mode)
+	if entry.IsDir {
+		header.Name += "/"
+		header.Method = zip.Store
+	} else if slices.Contains(storedExtensions, strings.ToLower(path.Ext(name))) {
+		header.Method = zip.Store
+	}
+	return a.zw.CreateHeader(header)
+}
+
+func (a *zipWriter) close() error {
+	return a.zw.Close()
+}
+
+type tarGzWriter struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func (a *tarGzWriter) create(name string, entry Entry) (io.Writer, error) {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     int64(entry.Mode.Perm()),
+		ModTime:  entry.ModTime,
+		Typeflag: tar.TypeReg,
+		Size:     entry.Size,
+		Format:   tar.FormatPAX,
+	}
+	if entry.IsDir {
+		header.Name += "/"
+		header.Typeflag = tar.TypeDir
+		header.Size = 0
+	}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	return a.tw, nil
+}
+
+func (a *tarGzWriter) close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gw.Close()
+}
+
+// Local helpers
+
+func archiveName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return strings.TrimPrefix(name, prefix+"/")
+}
+
+// copyFile copies exactly the size recorded during Collect, so the archive stays
+// consistent with its headers even when a file grows while being streamed
+func copyFile(ctx context.Context, w io.Writer, backend storage.Backend, entry Entry) error {
+	file, err := backend.Open(entry.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	_, err = io.CopyN(w, &ctxReader{ctx: ctx, r: file}, entry.Size)
+	return err
+}
+
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/patppuccin/viewr/src/constants"
 	"github.com/patppuccin/viewr/src/helpers"
 	"github.com/patppuccin/viewr/src/include"
 	"github.com/patppuccin/viewr/src/models"
@@ -31,6 +32,19 @@ func Load(configFilePath string, flags *pflag.FlagSet) {
 			Port:     5567,
 			Address:  "127.0.0.1",
 		},
+		Archive: models.ArchiveConfig{
+			MaxBytes: constants.DefaultArchiveMaxBytes,
+			MaxFiles: constants.DefaultArchiveMaxFiles,
+		},
+		Preview: models.PreviewConfig{
+			MaxSize: constants.DefaultPreviewMaxSize,
+		},
+		Thumbnails: models.ThumbnailConfig{
+			MaxCacheSize: constants.DefaultThumbnailCacheSize,
+		},
+		Gallery: models.GalleryConfig{
+			SlideshowInterval: constants.DefaultSlideshowInterval,
+		},
 		Paths: []models.PathConfig{},
 	}
 	GlobalConfigSrc = "defaults"
@@ -58,6 +72,23 @@ func Load(configFilePath string, flags *pflag.FlagSet) {
 	GlobalConfig = &cfg
 	GlobalConfigSrc = cfgSrc
 
+	// Fill in optional sections left out of the config file
+	if GlobalConfig.Archive.MaxBytes <= 0 {
+		GlobalConfig.Archive.MaxBytes = constants.DefaultArchiveMaxBytes
+	}
+	if GlobalConfig.Archive.MaxFiles <= 0 {
+		GlobalConfig.Archive.MaxFiles = constants.DefaultArchiveMaxFiles
+	}
+	if GlobalConfig.Preview.MaxSize <= 0 {
+		GlobalConfig.Preview.MaxSize = constants.DefaultPreviewMaxSize
+	}
+	if GlobalConfig.Thumbnails.MaxCacheSize <= 0 {
+		GlobalConfig.Thumbnails.MaxCacheSize = constants.DefaultThumbnailCacheSize
+	}
+	if GlobalConfig.Gallery.SlideshowInterval <= 0 {
+		GlobalConfig.Gallery.SlideshowInterval = constants.DefaultSlideshowInterval
+	}
+
 	// Track & handle per-field Overrides
 	configOverrides := map[string]string{}
 	setConfigOverride := func(field, src string) { configOverrides[field] = src }
@@ -1,5 +1,7 @@
 package constants
 
+import "time"
+
 // Compile-time configurations ///////////////////
 var AppVersion string
 
@@ -21,6 +23,65 @@ const AssetLogoURL = "/assets/icons/logo.png"
 const AssetFaviconURL = "/assets/icons/favicon.ico"
 const AssetOGImageURL = "assets/images/og.png"
 
+// Download Configurations ///////////////////////
+
+const DefaultArchiveMaxBytes int64 = 10 << 30 // 10 GiB
+const DefaultArchiveMaxFiles = 100000
+
+// Preview Configurations ////////////////////////
+
+const DefaultPreviewMaxSize int64 = 2 << 20 // 2 MiB
+
+// Thumbnail Configurations //////////////////////
+
+const DefaultThumbnailSize = 256
+const DefaultThumbnailCacheSize int64 = 512 << 20 // 512 MiB
+
+// Gallery Configurations ////////////////////////
+
+const DefaultSlideshowInterval = 5 * time.Second
+const GalleryImageRatio = 0.5 // Share of a folder's files that must be images to offer its gallery
+
+// Player Configurations /////////////////////////
+
+const MaxSubtitleSize int64 = 4 << 20 // 4 MiB
+const MaxPlaylistTracks = 2000
+
+// Table Configurations //////////////////////////
+
+const DefaultTableLimit = 100
+const MaxTableLimit = 1000
+const TableSniffSize int64 = 64 << 10 // 64 KiB, read to detect the delimiter & binary files
+const TableIndexFiles = 64            // Files whose page offsets are remembered
+
+// Data Configurations ///////////////////////////
+
+const DataTreeMaxSize int64 = 16 << 20 // 16 MiB of JSON & XML parsed for a tree, larger YAML & TOML isn't parsed
+const DataTreeMaxNodes = 20000
+const DataTreeMaxDepth = 200
+
+// Hex Configurations ////////////////////////////
+
+const HexPageSize int64 = 4096 // Bytes per page of the hex viewer, 256 rows
+
+// OPDS Configurations ///////////////////////////
+
+const OPDSPageSize = 100
+
+// Search Configurations /////////////////////////
+
+const DefaultSearchLimit = 100
+const MaxSearchLimit = 1000
+const DefaultContentIndexMaxFileSize int64 = 10 << 20 // 10 MiB
+
+// Grep Configurations ///////////////////////////
+
+const DefaultGrepLimit = 200
+const MaxGrepLimit = 5000
+const GrepMaxFiles = 20000
+const GrepMaxFileSize int64 = 10 << 20 // 10 MiB
+const GrepTimeout = 30 * time.Second
+
 // CLI Configurations ////////////////////////////
 
 var LogLevels = []string{"debug", "info", "warn", "error"}
@@ -29,4 +90,8 @@ var LogLevels = []string{"debug", "info", "warn", "error"}
 
 type CtxKey int
 
-const AppCtxKey CtxKey = iota
+const (
+	AppCtxKey CtxKey = iota
+	StorageCtxKey
+	ThumbsCtxKey
+)
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/helpers"
+	"go.etcd.io/bbolt"
+)
+
+// Open opens (or creates) the local database, kept in data/ next to logs/ in the
+// root path. Only one process can hold it at a time.
+func Open() (*bbolt.DB, error) {
+	rootPath, err := helpers.GetRootPath()
+	if err != nil {
+		return nil, helpers.SafeErr("failed to resolve root path", err)
+	}
+	dataDir := filepath.Join(rootPath, "data")
+
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return nil, helpers.SafeErr("failed to create data directory", err)
+	}
+
+	dbConn, err := bbolt.Open(filepath.Join(dataDir, constants.AppAbbrName+".db"), 0o600, &bbolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, helpers.SafeErr("failed to open database", err)
+	}
+	return dbConn, nil
+}
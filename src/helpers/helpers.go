@@ -8,6 +8,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/patppuccin/viewr/src/constants"
 )
@@ -128,3 +129,8 @@ func DoesYAMLFileExist(path string) bool {
 	ext := strings.ToLower(filepath.Ext(path))
 	return ext == ".yaml" || ext == ".yml"
 }
+
+func FileETag(size int64, modTime time.Time) string {
+	// Strong validator derived from size & mtime, cheap enough for multi-GB files
+	return `"` + strconv.FormatInt(modTime.UnixNano(), 36) + "-" + strconv.FormatInt(size, 36) + `"`
+}
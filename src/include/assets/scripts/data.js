@@ -0,0 +1,60 @@
+// Copies the path of a value in the data tree, like .server.port, and expands or
+// collapses the whole tree.
+(function () {
+    "use strict";
+
+    var tree = document.querySelector(".tree");
+    if (!tree) {
+        return;
+    }
+
+    function copy(text) {
+        if (navigator.clipboard && window.isSecureContext) {
+            return navigator.clipboard.writeText(text);
+        }
+        // Plain HTTP has no clipboard API
+        var input = document.createElement("textarea");
+        input.value = text;
+        input.style.position = "fixed";
+        input.style.opacity = "0";
+        document.body.appendChild(input);
+        input.select();
+        var ok = document.execCommand("copy");
+        input.remove();
+        return ok ? Promise.resolve() : Promise.reject(new Error("copy failed"));
+    }
+
+    tree.addEventListener("click", function (event) {
+        var button = event.target.closest(".copy-path");
+        if (!button) {
+            return;
+        }
+        // Copying doesn't toggle the value it's in
+        event.preventDefault();
+        copy(button.dataset.path).then(function () {
+            button.classList.add("copied");
+            setTimeout(function () {
+                button.classList.remove("copied");
+            }, 1200);
+        });
+    });
+
+    function toggleAll(open) {
+        tree.querySelectorAll("details").forEach(function (details) {
+            details.open = open;
+        });
+    }
+
+    var expand = document.getElementById("tree-expand");
+    var collapse = document.getElementById("tree-collapse");
+    if (expand) {
+        expand.addEventListener("click", function () {
+            toggleAll(true);
+        });
+    }
+    if (collapse) {
+        collapse.addEventListener("click", function () {
+            toggleAll(false);
+        });
+    }
+})();
@@ -0,0 +1,238 @@
+// Opens gallery images in a lightbox with keyboard navigation, a slideshow & an
+// EXIF side panel. The open image is kept in the URL hash, so it can be linked.
+(function () {
+    "use strict";
+
+    var box = document.getElementById("lightbox");
+    var tiles = Array.prototype.slice.call(document.querySelectorAll(".gallery .tile"));
+    if (!box || tiles.length === 0) {
+        return;
+    }
+
+    var image = box.querySelector("figure img");
+    var name = box.querySelector("figcaption .name");
+    var count = box.querySelector("figcaption .count");
+    var details = box.querySelector(".exif dl");
+    var play = box.querySelector("[data-action=play]");
+    var download = box.querySelector("[data-action=download]");
+    var intervalSelect = document.getElementById("slideshow-interval");
+
+    var intervalKey = "viewr.slideshowInterval";
+    var current = -1;
+    var timer = null;
+    var metadata = {};
+
+    // The last interval picked wins over the configured one
+    var saved = window.localStorage.getItem(intervalKey);
+    if (saved && intervalSelect.querySelector("option[value='" + saved + "']")) {
+        intervalSelect.value = saved;
+    }
+    intervalSelect.addEventListener("change", function () {
+        window.localStorage.setItem(intervalKey, intervalSelect.value);
+        if (timer) {
+            schedule();
+        }
+    });
+
+    function show(index) {
+        current = (index + tiles.length) % tiles.length;
+        var tile = tiles[current];
+
+        image.src = tile.href;
+        name.textContent = tile.dataset.name;
+        count.textContent = current + 1 + " / " + tiles.length;
+        download.href = tile.href + "?download";
+        box.hidden = false;
+        document.body.classList.add("lightbox-open");
+        history.replaceState(null, "", "#" + encodeURIComponent(tile.dataset.name));
+
+        // Warm the cache for the image shown next
+        new Image().src = tiles[(current + 1) % tiles.length].href;
+
+        loadDetails(tile);
+        if (timer) {
+            schedule();
+        }
+    }
+
+    function close() {
+        stop();
+        box.hidden = true;
+        image.removeAttribute("src");
+        document.body.classList.remove("lightbox-open");
+        history.replaceState(null, "", window.location.pathname + window.location.search);
+        if (tiles[current]) {
+            tiles[current].focus();
+        }
+    }
+
+    function schedule() {
+        window.clearTimeout(timer);
+        timer = window.setTimeout(function () {
+            show(current + 1);
+        }, parseInt(intervalSelect.value, 10) * 1000);
+    }
+
+    function start() {
+        if (box.hidden) {
+            show(current < 0 ? 0 : current);
+        }
+        timer = true;
+        schedule();
+        play.textContent = "❚❚";
+    }
+
+    function stop() {
+        window.clearTimeout(timer);
+        timer = null;
+        play.textContent = "▶";
+    }
+
+    // EXIF side panel //////////////////////////////
+
+    function loadDetails(tile) {
+        var url = tile.dataset.meta;
+        if (metadata[url]) {
+            renderDetails(metadata[url]);
+            return;
+        }
+        details.textContent = "Loading…";
+        fetch(url, { headers: { Accept: "application/json" } })
+            .then(function (res) {
+                return res.ok ? res.json() : {};
+            })
+            .catch(function () {
+                return {};
+            })
+            .then(function (meta) {
+                metadata[url] = meta;
+                if (tiles[current] === tile) {
+                    renderDetails(meta);
+                }
+            });
+    }
+
+    function renderDetails(meta) {
+        var exif = meta.exif || {};
+        var camera = [exif.make, exif.model].filter(Boolean).join(" ");
+        if (exif.make && exif.model && exif.model.indexOf(exif.make) === 0) {
+            camera = exif.model;
+        }
+        var exposure = [
+            exif.exposureTime && exif.exposureTime + " s",
+            exif.fNumber && "f/" + exif.fNumber,
+            exif.iso && "ISO " + exif.iso,
+            exif.focalLength && exif.focalLength + " mm",
+        ].filter(Boolean).join(" · ");
+
+        var rows = [
+            ["Camera", camera],
+            ["Lens", [exif.lensMake, exif.lensModel].filter(Boolean).join(" ")],
+            ["Exposure", exposure],
+            ["Flash", exif.flash === undefined ? "" : exif.flash ? "Fired" : "Off"],
+            ["Taken", exif.takenAt],
+            ["Dimensions", exif.width && exif.height ? exif.width + " × " + exif.height : ""],
+            ["Location", exif.latitude !== undefined ? exif.latitude + ", " + exif.longitude : ""],
+            ["Software", exif.software],
+        ];
+
+        details.textContent = "";
+        rows.forEach(function (row) {
+            if (!row[1]) {
+                return;
+            }
+            var dt = document.createElement("dt");
+            var dd = document.createElement("dd");
+            dt.textContent = row[0];
+            dd.textContent = row[1];
+            details.append(dt, dd);
+        });
+        if (!meta.exif) {
+            details.textContent = "No EXIF data.";
+        }
+    }
+
+    // Events ///////////////////////////////////////
+
+    tiles.forEach(function (tile, index) {
+        tile.addEventListener("click", function (event) {
+            if (event.ctrlKey || event.metaKey || event.shiftKey) {
+                return; // Opening in a new tab
+            }
+            event.preventDefault();
+            show(index);
+        });
+    });
+
+    document.getElementById("slideshow-start").addEventListener("click", start);
+
+    box.addEventListener("click", function (event) {
+        var button = event.target.closest("[data-action]");
+        if (!button) {
+            if (event.target === box) {
+                close();
+            }
+            return;
+        }
+        switch (button.dataset.action) {
+            case "prev":
+                show(current - 1);
+                break;
+            case "next":
+                show(current + 1);
+                break;
+            case "play":
+                timer ? stop() : start();
+                break;
+            case "info":
+                box.classList.toggle("no-details");
+                break;
+            case "close":
+                close();
+                break;
+        }
+    });
+
+    document.addEventListener("keydown", function (event) {
+        if (box.hidden || event.altKey || event.ctrlKey || event.metaKey) {
+            return;
+        }
+        switch (event.key) {
+            case "ArrowLeft":
+                show(current - 1);
+                break;
+            case "ArrowRight":
+                show(current + 1);
+                break;
+            case "Home":
+                show(0);
+                break;
+            case "End":
+                show(tiles.length - 1);
+                break;
+            case " ":
+                timer ? stop() : start();
+                break;
+            case "i":
+                box.classList.toggle("no-details");
+                break;
+            case "Escape":
+                close();
+                break;
+            default:
+                return;
+        }
+        event.preventDefault();
+    });
+
+    // Reopen the image linked by the hash
+    if (window.location.hash.length > 1) {
+        var linked = decodeURIComponent(window.location.hash.slice(1));
+        var index = tiles.findIndex(function (tile) {
+            return tile.dataset.name === linked;
+        });
+        if (index >= 0) {
+            show(index);
+        }
+    }
+})();
@@ -0,0 +1,41 @@
+// Scrolls to the byte jumped to, and highlights a byte's hex & character
+// together while hovering either.
+(function () {
+    "use strict";
+
+    var dump = document.querySelector(".hexdump");
+    if (!dump) {
+        return;
+    }
+
+    var target = dump.querySelector(".bytes .target");
+    if (target) {
+        target.scrollIntoView({ block: "center" });
+    }
+
+    function pair(span) {
+        var cell = span.parentElement;
+        var other = cell.classList.contains("bytes") ? cell.nextElementSibling : cell.previousElementSibling;
+        var index = Array.prototype.indexOf.call(cell.children, span);
+        return other ? other.children[index] : null;
+    }
+
+    function toggle(event, on) {
+        var span = event.target.closest(".bytes span, .chars span");
+        if (!span) {
+            return;
+        }
+        var other = pair(span);
+        span.classList.toggle("hover", on);
+        if (other) {
+            other.classList.toggle("hover", on);
+        }
+    }
+
+    dump.addEventListener("mouseover", function (event) {
+        toggle(event, true);
+    });
+    dump.addEventListener("mouseout", function (event) {
+        toggle(event, false);
+    });
+})();
@@ -0,0 +1,81 @@
+// Remembers where playback of each file left off & moves through the folder's
+// other media with Shift+N / Shift+P, optionally playing the next one on its own.
+(function () {
+    "use strict";
+
+    var player = document.getElementById("player");
+    if (!player) {
+        return;
+    }
+
+    var positionKey = "viewr.position:" + player.dataset.positionKey;
+    var playNextKey = "viewr.playNext";
+    var playNext = document.getElementById("player-autoplay");
+    var next = document.querySelector("a[rel=next]");
+    var prev = document.querySelector("a[rel=prev]");
+    var lastSaved = 0;
+
+    // Only a page reached through "Play next" starts playing on its own
+    var continued = window.sessionStorage.getItem(playNextKey) === "1";
+    window.sessionStorage.removeItem(playNextKey);
+
+    if (playNext) {
+        playNext.checked = window.localStorage.getItem(playNextKey) === "1";
+        playNext.addEventListener("change", function () {
+            window.localStorage.setItem(playNextKey, playNext.checked ? "1" : "0");
+        });
+    }
+
+    // Resume where playback stopped, unless that was all but the end
+    player.addEventListener("loadedmetadata", function () {
+        var saved = parseFloat(window.localStorage.getItem(positionKey));
+        if (saved > 0 && (!player.duration || saved < player.duration - 5)) {
+            player.currentTime = saved;
+        }
+        if (continued) {
+            player.play().catch(function () {});
+        }
+    });
+
+    // Saved every few seconds while playing & whenever it stops
+    player.addEventListener("timeupdate", function () {
+        var now = Date.now();
+        if (now - lastSaved >= 5000) {
+            lastSaved = now;
+            save();
+        }
+    });
+    player.addEventListener("pause", save);
+    window.addEventListener("pagehide", save);
+
+    player.addEventListener("ended", function () {
+        window.localStorage.removeItem(positionKey);
+        if (next && playNext && playNext.checked) {
+            window.sessionStorage.setItem(playNextKey, "1");
+            window.location.href = next.href;
+        }
+    });
+
+    // Source errors don't bubble, so they're caught on the way down
+    player.addEventListener("error", function () {
+        player.parentElement.classList.add("failed");
+    }, true);
+
+    document.addEventListener("keydown", function (event) {
+        if (!event.shiftKey || event.ctrlKey || event.metaKey || event.altKey || /^(INPUT|SELECT|TEXTAREA)$/.test(event.target.tagName)) {
+            return;
+        }
+        var link = event.key === "N" ? next : event.key === "P" ? prev : null;
+        if (link) {
+            event.preventDefault();
+            window.location.href = link.href;
+        }
+    });
+
+    function save() {
+        if (player.ended || player.currentTime < 1) {
+            return;
+        }
+        window.localStorage.setItem(positionKey, player.currentTime.toFixed(1));
+    }
+})();
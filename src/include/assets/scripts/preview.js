@@ -0,0 +1,53 @@
+// Highlights the lines picked by #L10 & #L10-L25 anchors. Shift-clicking a line
+// number extends the current anchor into a range.
+(function () {
+    "use strict";
+
+    function parseHash() {
+        var match = /^#L(\d+)(?:-L?(\d+))?$/.exec(window.location.hash);
+        if (!match) {
+            return null;
+        }
+        var start = parseInt(match[1], 10);
+        var end = match[2] ? parseInt(match[2], 10) : start;
+        return { start: Math.min(start, end), end: Math.max(start, end) };
+    }
+
+    function highlight() {
+        document.querySelectorAll(".preview .line.selected").forEach(function (line) {
+            line.classList.remove("selected");
+        });
+
+        var range = parseHash();
+        if (!range) {
+            return;
+        }
+        for (var n = range.start; n <= range.end; n++) {
+            var number = document.getElementById("L" + n);
+            if (number && number.parentElement) {
+                number.parentElement.classList.add("selected");
+            }
+        }
+
+        var first = document.getElementById("L" + range.start);
+        if (first) {
+            first.scrollIntoView({ block: "center" });
+        }
+    }
+
+    document.addEventListener("click", function (event) {
+        var link = event.target.closest(".preview .lnlinks");
+        var range = parseHash();
+        if (!link || !event.shiftKey || !range) {
+            return;
+        }
+        event.preventDefault();
+        var line = parseInt(link.getAttribute("href").slice(2), 10);
+        var start = Math.min(range.start, line);
+        var end = Math.max(range.start, line);
+        window.location.hash = start === end ? "#L" + start : "#L" + start + "-L" + end;
+    });
+
+    window.addEventListener("hashchange", highlight);
+    highlight();
+})();
@@ -0,0 +1,30 @@
+// Filters the rows of the table page shown by the text typed above each column,
+// keeping the rows holding all of it.
+(function () {
+    "use strict";
+
+    var inputs = Array.prototype.slice.call(document.querySelectorAll(".data-table .filters input"));
+    var rows = Array.prototype.slice.call(document.querySelectorAll(".data-table tbody tr"));
+    if (inputs.length === 0) {
+        return;
+    }
+
+    function filter() {
+        var terms = inputs.map(function (input) {
+            return { column: parseInt(input.dataset.column, 10) + 1, text: input.value.trim().toLowerCase() };
+        }).filter(function (term) {
+            return term.text !== "";
+        });
+
+        rows.forEach(function (row) {
+            row.hidden = !terms.every(function (term) {
+                var cell = row.cells[term.column];
+                return cell && cell.textContent.toLowerCase().indexOf(term.text) >= 0;
+            });
+        });
+    }
+
+    inputs.forEach(function (input) {
+        input.addEventListener("input", filter);
+    });
+})();
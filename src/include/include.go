@@ -5,5 +5,8 @@ import "embed"
 //go:embed assets/*
 var Assets embed.FS
 
+//go:embed templates/*
+var Templates embed.FS
+
 //go:embed config/viewr-config.yaml
 var DefaultConfig []byte
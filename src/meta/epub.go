@@ -0,0 +1,222 @@
+package meta
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var ErrNoCover = errors.New("no cover image")
+
+// Markup in descriptions, which are often HTML
+var markupTag = regexp.MustCompile(`<[^>]*>`)
+
+// EPUB is what an e-book's package document tells about it
+type EPUB struct {
+	Version     string `json:"version,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	Description string `json:"description,omitempty"` // Plain text
+	Date        string `json:"date,omitempty"`
+	Identifier  string `json:"identifier,omitempty"`
+	CoverType   string `json:"coverType,omitempty"` // Media type of the cover image, if it has one
+
+	cover string // Name of the cover image within the book
+}
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Version  string `xml:"version,attr"`
+	Metadata struct {
+		Titles      []string `xml:"title"`
+		Creators    []string `xml:"creator"`
+		Languages   []string `xml:"language"`
+		Publishers  []string `xml:"publisher"`
+		Description string   `xml:"description"`
+		Dates       []string `xml:"date"`
+		Identifiers []string `xml:"identifier"`
+		Meta        []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+}
+
+// ReadEPUB reads the metadata of an EPUB from its package document
+func ReadEPUB(r io.ReaderAt, size int64) (*EPUB, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	return readPackage(zr)
+}
+
+// EPUBCover reads the cover image of an EPUB, along with its media type
+func EPUBCover(r io.ReaderAt, size int64) ([]byte, string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, "", ErrUnsupported
+	}
+	book, err := readPackage(zr)
+	if err != nil {
+		return nil, "", err
+	}
+	if book.cover == "" {
+		return nil, "", ErrNoCover
+	}
+	data, err := readZipFile(zr, book.cover)
+	if err != nil {
+		return nil, "", ErrNoCover
+	}
+	return data, book.CoverType, nil
+}
+
+// readPackage finds the package document through the container & reads it
+func readPackage(zr *zip.Reader) (*EPUB, error) {
+	var container epubContainer
+	if err := readZipXML(zr, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	opfPath := ""
+	for _, root := range container.Rootfiles {
+		if root.MediaType == "" || root.MediaType == "application/oebps-package+xml" {
+			opfPath = root.FullPath
+			break
+		}
+	}
+	if opfPath == "" {
+		return nil, ErrUnsupported
+	}
+	var pkg opfPackage
+	if err := readZipXML(zr, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+
+	md := pkg.Metadata
+	book := &EPUB{
+		Version:     pkg.Version,
+		Title:       first(md.Titles),
+		Author:      strings.Join(trimAll(md.Creators), ", "),
+		Language:    first(md.Languages),
+		Publisher:   first(md.Publishers),
+		Description: strings.Join(strings.Fields(html.UnescapeString(markupTag.ReplaceAllString(md.Description, " "))), " "),
+		Date:        first(md.Dates),
+		Identifier:  first(md.Identifiers),
+	}
+
+	// EPUB 3 marks the cover in the manifest, EPUB 2 names it in a meta element,
+	// which EPUB 3 books may keep for older readers
+	coverID := ""
+	for _, m := range md.Meta {
+		if m.Name == "cover" {
+			coverID = m.Content
+		}
+	}
+	cover := -1
+	for i, item := range pkg.Manifest {
+		if !strings.HasPrefix(item.MediaType, "image/") {
+			continue
+		}
+		if slices.Contains(strings.Fields(item.Properties), "cover-image") {
+			cover = i
+			break
+		}
+		if coverID != "" && item.ID == coverID {
+			cover = i
+		}
+	}
+	if cover >= 0 {
+		item := pkg.Manifest[cover]
+		href, err := url.PathUnescape(item.Href)
+		if err != nil {
+			href = item.Href
+		}
+		book.cover = path.Join(path.Dir(opfPath), href)
+		book.CoverType = item.MediaType
+	}
+	return book, nil
+}
+
+// readEPUB reads an EPUB for Read, as a document
+func readEPUB(r io.ReaderAt, size int64) (*Metadata, error) {
+	book, err := ReadEPUB(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return &Metadata{Format: "epub", Document: &Document{
+		Version: book.Version,
+		Title:   book.Title,
+		Author:  book.Author,
+	}}, nil
+}
+
+// isEPUB checks for the uncompressed "mimetype" file EPUBs must start with
+func isEPUB(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("PK\x03\x04")) && len(head) >= 58 &&
+		string(head[30:38]) == "mimetype" && string(head[38:58]) == "application/epub+zip"
+}
+
+// EPUB helpers
+
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return io.ReadAll(io.LimitReader(f, maxBoxSize))
+}
+
+func readZipXML(zr *zip.Reader, name string, v any) error {
+	data, err := readZipFile(zr, name)
+	if err != nil {
+		return ErrUnsupported
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return ErrUnsupported
+	}
+	return nil
+}
+
+func first(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func trimAll(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package meta
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReadEPUB(t *testing.T) {
+	data := buildEPUB(t, map[string]string{
+		"OEBPS/content.opf": `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Field Guide</dc:title>
+    <dc:creator>Ada</dc:creator>
+    <dc:creator>Grace</dc:creator>
+    <dc:language>en</dc:language>
+    <dc:description>&lt;p&gt;Notes &amp;amp; tips&lt;/p&gt;</dc:description>
+    <meta name="cover" content="old-cover"/>
+  </metadata>
+  <manifest>
+    <item id="old-cover" href="images/old.png" media-type="image/png"/>
+    <item id="cover" href="images/cover%20art.jpg" media-type="image/jpeg" properties="cover-image"/>
+  </manifest>
+</package>`,
+		"OEBPS/images/cover art.jpg": "\xff\xd8cover",
+	})
+
+	book, err := ReadEPUB(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != "Field Guide" || book.Author != "Ada, Grace" || book.Language != "en" || book.Description != "Notes & tips" || book.Version != "3.0" {
+		t.Fatalf("ReadEPUB() = %+v", book)
+	}
+
+	cover, coverType, err := EPUBCover(bytes.NewReader(data), int64(len(data)))
+	if err != nil || string(cover) != "\xff\xd8cover" || coverType != "image/jpeg" {
+		t.Fatalf("EPUBCover() = %q, %q, %v", cover, coverType, err)
+	}
+
+	md := readTest(t, data, "guide.epub")
+	if md.Format != "epub" || md.Document == nil || md.Document.Title != "Field Guide" {
+		t.Fatalf("Read() = %+v", md)
+	}
+
+	// EPUB 2 books name the cover in a meta element
+	data = buildEPUB(t, map[string]string{
+		"OEBPS/content.opf": `<package version="2.0"><metadata><meta name="cover" content="c"/></metadata>
+<manifest><item id="c" href="c.gif" media-type="image/gif"/></manifest></package>`,
+	})
+	if _, _, err := EPUBCover(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrNoCover) {
+		t.Fatalf("EPUBCover() of a missing file err = %v, want %v", err, ErrNoCover)
+	}
+	if book, err := ReadEPUB(bytes.NewReader(data), int64(len(data))); err != nil || book.CoverType != "image/gif" {
+		t.Fatalf("ReadEPUB() = %+v, %v", book, err)
+	}
+}
+
+// buildEPUB zips files into an EPUB, with its mimetype & container
+func buildEPUB(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name, data string, method uint16) {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("mimetype", "application/epub+zip", zip.Store)
+	add("META-INF/container.xml", `<container><rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`, zip.Deflate)
+	for name, data := range files {
+		add(name, data, zip.Deflate)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,322 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrNoEXIF = errors.New("no EXIF metadata")
+
+// EXIF holds the parts of an image's EXIF metadata worth showing
+type EXIF struct {
+	Make         string   `json:"make,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	LensMake     string   `json:"lensMake,omitempty"`
+	LensModel    string   `json:"lensModel,omitempty"`
+	Software     string   `json:"software,omitempty"`
+	TakenAt      string   `json:"takenAt,omitempty"`      // As recorded, like 2024-05-01 14:03:22, in the camera's time zone
+	ExposureTime string   `json:"exposureTime,omitempty"` // Like 1/250
+	FNumber      float64  `json:"fNumber,omitempty"`
+	ISO          int      `json:"iso,omitempty"`
+	FocalLength  float64  `json:"focalLength,omitempty"` // mm
+	Flash        *bool    `json:"flash,omitempty"`
+	Width        int      `json:"width,omitempty"`
+	Height       int      `json:"height,omitempty"`
+	Orientation  int      `json:"orientation,omitempty"` // 1-8, as in the TIFF spec
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+}
+
+// TIFF tags read from IFD0, the EXIF IFD & the GPS IFD
+const (
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829a
+	tagFNumber          = 0x829d
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagFlash            = 0x9209
+	tagFocalLength      = 0x920a
+	tagPixelXDimension  = 0xa002
+	tagPixelYDimension  = 0xa003
+	tagLensMake         = 0xa433
+	tagLensModel        = 0xa434
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+// ReadEXIF finds & parses the EXIF metadata in the start of a JPEG, PNG, WebP or
+// TIFF based (including most camera raw) file
+func ReadEXIF(data []byte) (*EXIF, error) {
+	tiff, ok := findTIFF(data)
+	if !ok {
+		return nil, ErrNoEXIF
+	}
+	t, err := newTIFFReader(tiff)
+	if err != nil {
+		return nil, err
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(tiff[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	x := &EXIF{
+		Make:        t.text(ifd0[tagMake]),
+		Model:       t.text(ifd0[tagModel]),
+		Software:    t.text(ifd0[tagSoftware]),
+		TakenAt:     exifDate(t.text(ifd0[tagDateTime])),
+		Orientation: t.int(ifd0[tagOrientation]),
+	}
+
+	if entry, ok := ifd0[tagExifIFD]; ok {
+		if sub, err := t.readIFD(uint32(t.int(entry))); err == nil {
+			if taken := exifDate(t.text(sub[tagDateTimeOriginal])); taken != "" {
+				x.TakenAt = taken
+			}
+			if num, den, ok := t.rational(sub[tagExposureTime]); ok && num > 0 {
+				x.ExposureTime = exposureTime(num, den)
+			}
+			if num, den, ok := t.rational(sub[tagFNumber]); ok && den != 0 {
+				x.FNumber = round(float64(num)/float64(den), 1)
+			}
+			if num, den, ok := t.rational(sub[tagFocalLength]); ok && den != 0 {
+				x.FocalLength = round(float64(num)/float64(den), 1)
+			}
+			if entry, ok := sub[tagFlash]; ok {
+				fired := t.int(entry)&1 == 1
+				x.Flash = &fired
+			}
+			x.ISO = t.int(sub[tagISO])
+			x.Width = t.int(sub[tagPixelXDimension])
+			x.Height = t.int(sub[tagPixelYDimension])
+			x.LensMake = t.text(sub[tagLensMake])
+			x.LensModel = t.text(sub[tagLensModel])
+		}
+	}
+
+	if entry, ok := ifd0[tagGPSIFD]; ok {
+		if gps, err := t.readIFD(uint32(t.int(entry))); err == nil {
+			lat, latOK := t.degrees(gps[tagGPSLatitude], t.text(gps[tagGPSLatitudeRef]) == "S")
+			lon, lonOK := t.degrees(gps[tagGPSLongitude], t.text(gps[tagGPSLongitudeRef]) == "W")
+			if latOK && lonOK {
+				x.Latitude, x.Longitude = &lat, &lon
+			}
+		}
+	}
+	return x, nil
+}
+
+// findTIFF locates the TIFF structure holding the EXIF data within a file
+func findTIFF(data []byte) ([]byte, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return data, true
+
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		// JPEG segments up to the first APP1 holding EXIF
+		for pos := 2; pos+4 <= len(data); {
+			if data[pos] != 0xff {
+				return nil, false
+			}
+			marker := data[pos+1]
+			if marker == 0xd8 || (marker >= 0xd0 && marker <= 0xd7) || marker == 0x01 || marker == 0xff {
+				pos += 2
+				continue
+			}
+			if marker == 0xda || marker == 0xd9 { // Image data starts, no EXIF came before it
+				return nil, false
+			}
+			length := int(binary.BigEndian.Uint16(data[pos+2:]))
+			end := pos + 2 + length
+			if length < 2 || end > len(data) {
+				return nil, false
+			}
+			if segment := data[pos+4 : end]; marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				return segment[6:], true
+			}
+			pos = end
+		}
+
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		for pos := 8; pos+8 <= len(data); {
+			length := int(binary.BigEndian.Uint32(data[pos:]))
+			kind := string(data[pos+4 : pos+8])
+			end := pos + 8 + length
+			if length < 0 || end > len(data) {
+				return nil, false
+			}
+			if kind == "eXIf" {
+				return data[pos+8 : end], true
+			}
+			if kind == "IDAT" || kind == "IEND" {
+				return nil, false
+			}
+			pos = end + 4 // CRC
+		}
+
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		for pos := 12; pos+8 <= len(data); {
+			length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+			end := pos + 8 + length
+			if length < 0 || end > len(data) {
+				return nil, false
+			}
+			if string(data[pos:pos+4]) == "EXIF" {
+				return bytes.TrimPrefix(data[pos+8:end], []byte("Exif\x00\x00")), true
+			}
+			pos = end + length%2 // Chunks are padded to even sizes
+		}
+	}
+	return nil, false
+}
+
+// TIFF reading ///////////////////////////////////
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type tiffEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// Sizes of the TIFF field types, by type number
+var tiffTypeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+func newTIFFReader(data []byte) (*tiffReader, error) {
+	if len(data) < 8 {
+		return nil, ErrNoEXIF
+	}
+	switch string(data[:2]) {
+	case "II":
+		return &tiffReader{data: data, order: binary.LittleEndian}, nil
+	case "MM":
+		return &tiffReader{data: data, order: binary.BigEndian}, nil
+	}
+	return nil, ErrNoEXIF
+}
+
+// readIFD reads the entries of the IFD at offset, skipping any that point outside
+// the data
+func (t *tiffReader) readIFD(offset uint32) (map[uint16]tiffEntry, error) {
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return nil, ErrNoEXIF
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	pos := int(offset) + 2
+	if pos+count*12 > len(t.data) {
+		return nil, ErrNoEXIF
+	}
+
+	entries := make(map[uint16]tiffEntry, count)
+	for i := range count {
+		raw := t.data[pos+i*12 : pos+i*12+12]
+		entry := tiffEntry{kind: t.order.Uint16(raw[2:]), count: t.order.Uint32(raw[4:])}
+		size, ok := tiffTypeSizes[entry.kind]
+		if !ok || entry.count > uint32(len(t.data)) {
+			continue
+		}
+
+		total := uint64(size) * uint64(entry.count)
+		if total <= 4 {
+			entry.value = raw[8 : 8+total]
+		} else {
+			start := uint64(t.order.Uint32(raw[8:]))
+			if start+total > uint64(len(t.data)) {
+				continue
+			}
+			entry.value = t.data[start : start+total]
+		}
+		entries[t.order.Uint16(raw)] = entry
+	}
+	return entries, nil
+}
+
+func (t *tiffReader) text(e tiffEntry) string {
+	if e.kind != 2 {
+		return ""
+	}
+	s, _, _ := strings.Cut(string(e.value), "\x00")
+	return strings.TrimSpace(s)
+}
+
+func (t *tiffReader) int(e tiffEntry) int {
+	switch {
+	case e.kind == 3 && len(e.value) >= 2:
+		return int(t.order.Uint16(e.value))
+	case (e.kind == 4 || e.kind == 9) && len(e.value) >= 4:
+		return int(t.order.Uint32(e.value))
+	case (e.kind == 1 || e.kind == 7) && len(e.value) >= 1:
+		return int(e.value[0])
+	}
+	return 0
+}
+
+func (t *tiffReader) rational(e tiffEntry) (uint32, uint32, bool) {
+	return t.rationalAt(e, 0)
+}
+
+func (t *tiffReader) rationalAt(e tiffEntry, i int) (uint32, uint32, bool) {
+	if (e.kind != 5 && e.kind != 10) || len(e.value) < (i+1)*8 {
+		return 0, 0, false
+	}
+	return t.order.Uint32(e.value[i*8:]), t.order.Uint32(e.value[i*8+4:]), true
+}
+
+// degrees reads a GPS coordinate stored as degrees, minutes & seconds
+func (t *tiffReader) degrees(e tiffEntry, negative bool) (float64, bool) {
+	var parts [3]float64
+	for i := range parts {
+		num, den, ok := t.rationalAt(e, i)
+		if !ok || den == 0 {
+			return 0, false
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	deg := round(parts[0]+parts[1]/60+parts[2]/3600, 6)
+	if negative {
+		deg = -deg
+	}
+	return deg, true
+}
+
+// EXIF helpers
+
+// exifDate turns EXIF's 2024:05:01 14:03:22 into 2024-05-01 14:03:22
+func exifDate(s string) string {
+	if len(s) < 10 || strings.HasPrefix(s, "0000") {
+		return ""
+	}
+	return strings.Replace(s[:10], ":", "-", 2) + s[10:]
+}
+
+func exposureTime(num, den uint32) string {
+	if den == 0 {
+		return ""
+	}
+	if num >= den {
+		return strconv.FormatFloat(round(float64(num)/float64(den), 1), 'f', -1, 64)
+	}
+	return "1/" + strconv.FormatFloat(math.Round(float64(den)/float64(num)), 'f', 0, 64)
+}
+
+func round(f float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(f*p) / p
+}
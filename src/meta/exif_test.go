@@ -0,0 +1,133 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestReadEXIF(t *testing.T) {
+	tiff := buildTIFF([][]testEntry{
+		{
+			ascii(tagMake, "Canon"),
+			ascii(tagModel, "Canon EOS R6"),
+			short(tagOrientation, 6),
+			{tag: tagExifIFD, sub: 1},
+			{tag: tagGPSIFD, sub: 2},
+		},
+		{
+			rational(tagExposureTime, 1, 250),
+			rational(tagFNumber, 28, 10),
+			short(tagISO, 400),
+			ascii(tagDateTimeOriginal, "2024:05:01 14:03:22"),
+			rational(tagFocalLength, 50, 1),
+			short(tagFlash, 0x10),
+			ascii(tagLensModel, "RF24-105mm F4 L IS USM"),
+		},
+		{
+			ascii(tagGPSLatitudeRef, "N"),
+			rational(tagGPSLatitude, 48, 1, 51, 1, 2964, 100),
+			ascii(tagGPSLongitudeRef, "W"),
+			rational(tagGPSLongitude, 2, 1, 17, 1, 4020, 100),
+		},
+	})
+
+	// The same EXIF data within a JPEG, a PNG & on its own
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x04, 0x00, 0x00, 0xff, 0xe1}
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(len(app1)+2))
+	jpeg = append(append(jpeg, app1...), 0xff, 0xda)
+
+	png := []byte("\x89PNG\r\n\x1a\n")
+	png = append(binary.BigEndian.AppendUint32(png, 0), "IHDR\x00\x00\x00\x00"...)
+	png = append(binary.BigEndian.AppendUint32(png, uint32(len(tiff))), "eXIf"...)
+	png = append(append(png, tiff...), 0, 0, 0, 0)
+
+	for name, data := range map[string][]byte{"jpeg": jpeg, "png": png, "tiff": tiff} {
+		x, err := ReadEXIF(data)
+		if err != nil {
+			t.Fatalf("%s: ReadEXIF() error = %v", name, err)
+		}
+		if x.Make != "Canon" || x.Model != "Canon EOS R6" || x.LensModel != "RF24-105mm F4 L IS USM" {
+			t.Fatalf("%s: camera = %q %q %q", name, x.Make, x.Model, x.LensModel)
+		}
+		if x.ExposureTime != "1/250" || x.FNumber != 2.8 || x.ISO != 400 || x.FocalLength != 50 {
+			t.Fatalf("%s: exposure = %s f/%v ISO %d %vmm", name, x.ExposureTime, x.FNumber, x.ISO, x.FocalLength)
+		}
+		if x.TakenAt != "2024-05-01 14:03:22" || x.Orientation != 6 || x.Flash == nil || *x.Flash {
+			t.Fatalf("%s: TakenAt = %q, Orientation = %d, Flash = %v", name, x.TakenAt, x.Orientation, x.Flash)
+		}
+		if x.Latitude == nil || *x.Latitude != 48.858233 || *x.Longitude != -2.294500 {
+			t.Fatalf("%s: position = %v, %v", name, x.Latitude, x.Longitude)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"text":      []byte("hello"),
+		"no exif":   {0xff, 0xd8, 0xff, 0xda},
+		"truncated": jpeg[:40],
+	} {
+		if _, err := ReadEXIF(data); err == nil {
+			t.Fatalf("%s: ReadEXIF() returned no error", name)
+		}
+	}
+}
+
+type testEntry struct {
+	tag  uint16
+	kind uint16
+	data []byte
+	sub  int // Index of the IFD this entry points at, if any
+}
+
+func ascii(tag uint16, s string) testEntry {
+	return testEntry{tag: tag, kind: 2, data: append([]byte(s), 0)}
+}
+
+func short(tag uint16, v uint16) testEntry {
+	return testEntry{tag: tag, kind: 3, data: binary.LittleEndian.AppendUint16(nil, v)}
+}
+
+func rational(tag uint16, parts ...uint32) testEntry {
+	var data []byte
+	for _, p := range parts {
+		data = binary.LittleEndian.AppendUint32(data, p)
+	}
+	return testEntry{tag: tag, kind: 5, data: data}
+}
+
+// buildTIFF lays out little endian IFDs one after another, followed by the values
+// not fitting in their entries
+func buildTIFF(ifds [][]testEntry) []byte {
+	sizes := map[uint16]int{2: 1, 3: 2, 4: 4, 5: 8}
+	offsets := make([]int, len(ifds))
+	next := 8
+	for i, ifd := range ifds {
+		offsets[i] = next
+		next += 2 + 12*len(ifd) + 4
+	}
+
+	var out, values bytes.Buffer
+	out.WriteString("II*\x00")
+	_ = binary.Write(&out, binary.LittleEndian, uint32(8))
+	for _, ifd := range ifds {
+		_ = binary.Write(&out, binary.LittleEndian, uint16(len(ifd)))
+		for _, e := range ifd {
+			if e.sub > 0 {
+				e.kind, e.data = 4, binary.LittleEndian.AppendUint32(nil, uint32(offsets[e.sub]))
+			}
+			_ = binary.Write(&out, binary.LittleEndian, []uint16{e.tag, e.kind})
+			_ = binary.Write(&out, binary.LittleEndian, uint32(len(e.data)/sizes[e.kind]))
+			if len(e.data) <= 4 {
+				out.Write(append(e.data, make([]byte, 4-len(e.data))...))
+			} else {
+				_ = binary.Write(&out, binary.LittleEndian, uint32(next+values.Len()))
+				values.Write(e.data)
+			}
+		}
+		_ = binary.Write(&out, binary.LittleEndian, uint32(0))
+	}
+	out.Write(values.Bytes())
+	return out.Bytes()
+}
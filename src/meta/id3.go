@@ -0,0 +1,400 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Genres of ID3v1 & numeric ID3v2 genre references, up to the Winamp additions
+var id3Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop", "Jazz", "Metal",
+	"New Age", "Oldies", "Other", "Pop", "R&B", "Rap", "Reggae", "Rock", "Techno", "Industrial",
+	"Alternative", "Ska", "Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk",
+	"Fusion", "Trance", "Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic",
+	"Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream", "Southern Rock", "Comedy", "Cult", "Gangsta",
+	"Top 40", "Christian Rap", "Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes",
+	"Trailer", "Lo-Fi", "Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// readMP3 reads the ID3 tags of an MP3 file & works out its duration from the
+// frame headers
+func readMP3(r io.ReaderAt, size int64, head []byte) (*Metadata, error) {
+	md := &Metadata{Format: "mp3"}
+
+	// ID3v2 sits at the start, the audio follows it
+	var audioStart int64
+	if len(head) >= 10 && bytes.HasPrefix(head, []byte("ID3")) {
+		tagSize := int64(synchsafe(head[6:10])) + 10
+		if head[5]&0x10 != 0 { // Footer
+			tagSize += 10
+		}
+		data, err := readAt(r, 0, min(tagSize, maxTagBytes))
+		if err != nil {
+			return nil, err
+		}
+		if tags := readID3v2(data); !tags.isZero() {
+			md.Tags = tags
+		}
+		audioStart = tagSize
+	}
+
+	// ID3v1 sits in the last 128 bytes, its fields are used when there's no ID3v2
+	if size-audioStart >= 128 {
+		tail, err := readAt(r, size-128, 128)
+		if err != nil {
+			return nil, err
+		}
+		if tags := readID3v1(tail); tags != nil {
+			if md.Tags == nil {
+				md.Tags = tags
+			}
+			size -= 128
+		}
+	}
+
+	frames, err := readAt(r, audioStart, min(size-audioStart, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	md.Media = mp3Media(frames, size-audioStart)
+	if md.Tags == nil && md.Media == nil {
+		return nil, ErrUnsupported
+	}
+	return md, nil
+}
+
+// readID3v2 reads the text frames of an ID3v2.2, 2.3 or 2.4 tag
+func readID3v2(data []byte) *Tags {
+	tags := &Tags{}
+	if len(data) < 10 {
+		return tags
+	}
+	version, flags := data[3], data[5]
+	body := data[10:]
+	if version < 2 || version > 4 {
+		return tags
+	}
+	if flags&0x80 != 0 && version < 4 {
+		body = unsynchronise(body)
+	}
+	if flags&0x40 != 0 && version >= 3 && len(body) >= 4 { // Extended header
+		extSize := int(binary.BigEndian.Uint32(body))
+		if version == 3 {
+			extSize += 4
+		} else {
+			extSize = synchsafe(body)
+		}
+		if extSize > len(body) {
+			return tags
+		}
+		body = body[extSize:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+	for pos := 0; pos+headerSize <= len(body); {
+		id := string(body[pos : pos+idSize])
+		if id[0] == 0 { // Padding
+			break
+		}
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(body[pos+3])<<16 | int(body[pos+4])<<8 | int(body[pos+5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(body[pos+4:]))
+		case 4:
+			frameSize = synchsafe(body[pos+4:])
+		}
+		start := pos + headerSize
+		if frameSize < 0 || start+frameSize > len(body) {
+			break
+		}
+		frame := body[start : start+frameSize]
+		if version == 4 && body[pos+9]&0x02 != 0 {
+			frame = unsynchronise(frame)
+		}
+		pos = start + frameSize
+
+		switch id {
+		case "TIT2", "TT2":
+			tags.Title = id3Text(frame)
+		case "TPE1", "TP1":
+			tags.Artist = id3Text(frame)
+		case "TALB", "TAL":
+			tags.Album = id3Text(frame)
+		case "TPE2", "TP2":
+			tags.AlbumArtist = id3Text(frame)
+		case "TCON", "TCO":
+			tags.Genre = id3Genre(id3Text(frame))
+		case "TYER", "TYE", "TDRC":
+			if year := id3Text(frame); len(year) >= 4 {
+				tags.Year = year[:4]
+			}
+		case "TRCK", "TRK":
+			tags.Track, tags.TrackTotal = splitNumber(id3Text(frame))
+		case "TPOS", "TPA":
+			tags.Disc, tags.DiscTotal = splitNumber(id3Text(frame))
+		case "COMM", "COM":
+			// Encoding, language & a description come before the comment itself
+			if len(frame) > 4 && tags.Comment == "" {
+				values := id3Strings(frame[0], frame[4:])
+				if len(values) > 1 {
+					tags.Comment = values[1]
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// readID3v1 reads the fixed width fields of an ID3v1(.1) tag
+func readID3v1(tail []byte) *Tags {
+	if len(tail) != 128 || !bytes.HasPrefix(tail, []byte("TAG")) {
+		return nil
+	}
+	field := func(b []byte) string {
+		s, _, _ := strings.Cut(latin1(b), "\x00")
+		return strings.TrimSpace(s)
+	}
+	tags := &Tags{
+		Title:   field(tail[3:33]),
+		Artist:  field(tail[33:63]),
+		Album:   field(tail[63:93]),
+		Year:    field(tail[93:97]),
+		Comment: field(tail[97:127]),
+	}
+	if tail[125] == 0 && tail[126] != 0 { // ID3v1.1 track number
+		tags.Track = int(tail[126])
+		tags.Comment = field(tail[97:125])
+	}
+	if int(tail[127]) < len(id3Genres) {
+		tags.Genre = id3Genres[tail[127]]
+	}
+	return tags
+}
+
+// mp3Media reads the first MPEG audio frame, using its Xing or VBRI header for the
+// duration of VBR files, and the bitrate for CBR ones
+func mp3Media(data []byte, audioSize int64) *Media {
+	for pos := 0; pos+4 <= len(data); pos++ {
+		frame, ok := parseMP3Frame(data[pos:])
+		if !ok {
+			continue
+		}
+		// A second frame right after the first tells real headers from noise
+		if next := pos + frame.length; next+4 <= len(data) {
+			if _, ok := parseMP3Frame(data[next:]); !ok {
+				continue
+			}
+		}
+
+		media := &Media{
+			Container:  "mp3",
+			AudioCodec: "mp3",
+			SampleRate: frame.sampleRate,
+			Channels:   frame.channels,
+			Bitrate:    frame.bitrate,
+		}
+		if frame.layer != 3 {
+			media.Container, media.AudioCodec = "mpeg", "mp"+strconv.Itoa(frame.layer)
+		}
+
+		audioSize -= int64(pos)
+		if frames := frame.vbrFrames(data[pos:]); frames > 0 {
+			media.Duration = round(float64(frames)*float64(frame.samples)/float64(frame.sampleRate), 3)
+			media.Bitrate = int(float64(audioSize) * 8 / media.Duration / 1000)
+		} else if frame.bitrate > 0 {
+			media.Duration = round(float64(audioSize)*8/float64(frame.bitrate*1000), 3)
+		}
+		return media
+	}
+	return nil
+}
+
+type mp3Frame struct {
+	mpeg1      bool
+	layer      int
+	bitrate    int // kbit/s
+	sampleRate int
+	channels   int
+	samples    int // Per frame
+	length     int // Bytes
+}
+
+var (
+	mp3Bitrates = map[[2]int][15]int{
+		{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+		{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	mp3SampleRates = [3]int{44100, 48000, 32000}
+)
+
+func parseMP3Frame(b []byte) (mp3Frame, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mp3Frame{}, false
+	}
+	version := (b[1] >> 3) & 3 // 0: MPEG 2.5, 2: MPEG 2, 3: MPEG 1
+	layer := 4 - int((b[1]>>1)&3)
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int((b[2] >> 2) & 3)
+	if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	f := mp3Frame{mpeg1: version == 3, layer: layer, channels: 2}
+	table := [2]int{2, layer}
+	f.sampleRate = mp3SampleRates[rateIndex]
+	switch version {
+	case 3:
+		table[0] = 1
+	case 2:
+		f.sampleRate /= 2
+	case 0:
+		f.sampleRate /= 4
+	}
+	f.bitrate = mp3Bitrates[table][bitrateIndex]
+	if b[3]>>6 == 3 {
+		f.channels = 1
+	}
+
+	padding := int(b[2]>>1) & 1
+	switch {
+	case layer == 1:
+		f.samples = 384
+		f.length = (12*f.bitrate*1000/f.sampleRate + padding) * 4
+	case layer == 3 && !f.mpeg1:
+		f.samples = 576
+		f.length = 72*f.bitrate*1000/f.sampleRate + padding
+	default:
+		f.samples = 1152
+		f.length = 144*f.bitrate*1000/f.sampleRate + padding
+	}
+	return f, f.length > 4
+}
+
+// vbrFrames reads the frame count of a Xing/Info or VBRI header within a frame
+func (f mp3Frame) vbrFrames(frame []byte) int {
+	sideInfo := 32
+	switch {
+	case f.mpeg1 && f.channels == 1:
+		sideInfo = 17
+	case !f.mpeg1 && f.channels == 2:
+		sideInfo = 17
+	case !f.mpeg1:
+		sideInfo = 9
+	}
+	if xing := 4 + sideInfo; len(frame) >= xing+12 {
+		tag := string(frame[xing : xing+4])
+		if (tag == "Xing" || tag == "Info") && frame[xing+7]&1 != 0 {
+			return int(binary.BigEndian.Uint32(frame[xing+8:]))
+		}
+	}
+	if vbri := 36; len(frame) >= vbri+18 && string(frame[vbri:vbri+4]) == "VBRI" {
+		return int(binary.BigEndian.Uint32(frame[vbri+14:]))
+	}
+	return 0
+}
+
+// ID3 helpers
+
+func synchsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// unsynchronise undoes ID3 unsynchronisation, which inserts a zero after 0xff
+func unsynchronise(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+// id3Text reads the first value of a text frame
+func id3Text(frame []byte) string {
+	if len(frame) < 2 {
+		return ""
+	}
+	values := id3Strings(frame[0], frame[1:])
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// id3Strings decodes the null separated strings of a frame in the given encoding
+func id3Strings(encoding byte, b []byte) []string {
+	var values []string
+	switch encoding {
+	case 1, 2: // UTF-16 with a byte order mark, or big endian without
+		for len(b) >= 2 {
+			end := 0
+			for end+1 < len(b) && (b[end] != 0 || b[end+1] != 0) {
+				end += 2
+			}
+			values = append(values, strings.TrimSpace(decodeUTF16(b[:min(end, len(b))], encoding == 2)))
+			b = b[min(end+2, len(b)):]
+		}
+	default: // ISO-8859-1 or UTF-8
+		for _, s := range strings.Split(string(b), "\x00") {
+			if encoding == 0 {
+				s = latin1([]byte(s))
+			}
+			values = append(values, strings.TrimSpace(s))
+		}
+	}
+	for len(values) > 0 && values[len(values)-1] == "" {
+		values = values[:len(values)-1]
+	}
+	return values
+}
+
+func decodeUTF16(b []byte, bigEndian bool) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		b, bigEndian = b[2:], true
+	case bytes.HasPrefix(b, []byte{0xff, 0xfe}):
+		b, bigEndian = b[2:], false
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(b[i*2:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(b[i*2:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// id3Genre resolves numeric genre references like "(17)" or "17"
+func id3Genre(s string) string {
+	ref := strings.TrimSuffix(strings.TrimPrefix(s, "("), ")")
+	if n, err := strconv.Atoi(ref); err == nil && n >= 0 && n < len(id3Genres) {
+		return id3Genres[n]
+	}
+	// "(17)Rock" style carries the name as well
+	if strings.HasPrefix(s, "(") {
+		if _, name, ok := strings.Cut(s, ")"); ok && name != "" {
+			return name
+		}
+	}
+	return s
+}
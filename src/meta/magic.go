@@ -0,0 +1,145 @@
+package meta
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// MagicSize is how much of the start of a file Identify looks at, up to the
+// signature of ISO images
+const MagicSize = 0x8006
+
+// FileType is the probable type of a file, going by its signature
+type FileType struct {
+	Name      string `json:"name"` // e.g. "PNG image"
+	MediaType string `json:"mediaType,omitempty"`
+}
+
+// Signatures by which files are identified, the more specific ones first
+var signatures = []struct {
+	FileType
+	match func(head []byte) bool
+}{
+	// Images
+	{FileType{"PNG image", "image/png"}, at(0, "\x89PNG\r\n\x1a\n")},
+	{FileType{"JPEG image", "image/jpeg"}, at(0, "\xff\xd8\xff")},
+	{FileType{"GIF image", "image/gif"}, at(0, "GIF8")},
+	{FileType{"WebP image", "image/webp"}, allOf(at(0, "RIFF"), at(8, "WEBP"))},
+	{FileType{"TIFF image", "image/tiff"}, anyOf(at(0, "II*\x00"), at(0, "MM\x00*"))},
+	{FileType{"HEIF image", "image/heic"}, allOf(at(4, "ftyp"), anyOf(at(8, "heic"), at(8, "heix"), at(8, "mif1")))},
+	{FileType{"AVIF image", "image/avif"}, allOf(at(4, "ftyp"), at(8, "avif"))},
+	{FileType{"Icon", "image/vnd.microsoft.icon"}, at(0, "\x00\x00\x01\x00")},
+	{FileType{"Photoshop document", "image/vnd.adobe.photoshop"}, at(0, "8BPS")},
+
+	// Audio & video
+	{FileType{"MP3 audio with ID3 tags", "audio/mpeg"}, at(0, "ID3")},
+	{FileType{"FLAC audio", "audio/flac"}, at(0, "fLaC")},
+	{FileType{"Ogg media", "audio/ogg"}, at(0, "OggS")},
+	{FileType{"WAVE audio", "audio/wav"}, allOf(at(0, "RIFF"), at(8, "WAVE"))},
+	{FileType{"AVI video", "video/x-msvideo"}, allOf(at(0, "RIFF"), at(8, "AVI "))},
+	{FileType{"MIDI audio", "audio/midi"}, at(0, "MThd")},
+	{FileType{"QuickTime video", "video/quicktime"}, allOf(at(4, "ftyp"), at(8, "qt  "))},
+	{FileType{"MP4 media", "video/mp4"}, at(4, "ftyp")},
+	{FileType{"Matroska or WebM media", "video/x-matroska"}, at(0, "\x1a\x45\xdf\xa3")},
+
+	// Documents
+	{FileType{"PDF document", "application/pdf"}, at(0, "%PDF-")},
+	{FileType{"EPUB e-book", "application/epub+zip"}, isEPUB},
+	{FileType{"PostScript document", "application/postscript"}, at(0, "%!PS")},
+	{FileType{"RTF document", "application/rtf"}, at(0, "{\\rtf")},
+	{FileType{"Legacy Office document", "application/x-ole-storage"}, at(0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")},
+	{FileType{"SQLite database", "application/vnd.sqlite3"}, at(0, "SQLite format 3\x00")},
+	{FileType{"Parquet data", "application/vnd.apache.parquet"}, at(0, "PAR1")},
+
+	// Archives & compressed files
+	{FileType{"ZIP archive", "application/zip"}, anyOf(at(0, "PK\x03\x04"), at(0, "PK\x05\x06"))},
+	{FileType{"gzip compressed data", "application/gzip"}, at(0, "\x1f\x8b")},
+	{FileType{"bzip2 compressed data", "application/x-bzip2"}, at(0, "BZh")},
+	{FileType{"xz compressed data", "application/x-xz"}, at(0, "\xfd7zXZ\x00")},
+	{FileType{"Zstandard compressed data", "application/zstd"}, at(0, "\x28\xb5\x2f\xfd")},
+	{FileType{"LZ4 compressed data", "application/x-lz4"}, at(0, "\x04\x22\x4d\x18")},
+	{FileType{"7-Zip archive", "application/x-7z-compressed"}, at(0, "7z\xbc\xaf\x27\x1c")},
+	{FileType{"RAR archive", "application/vnd.rar"}, at(0, "Rar!\x1a\x07")},
+	{FileType{"Debian package", "application/vnd.debian.binary-package"}, at(0, "!<arch>\ndebian")},
+	{FileType{"ar archive", "application/x-archive"}, at(0, "!<arch>\n")},
+	{FileType{"RPM package", "application/x-rpm"}, at(0, "\xed\xab\xee\xdb")},
+	{FileType{"tar archive", "application/x-tar"}, at(257, "ustar")},
+	{FileType{"ISO 9660 disk image", "application/x-iso9660-image"}, at(0x8001, "CD001")},
+
+	// Executables
+	{FileType{"ELF executable", "application/x-elf"}, at(0, "\x7fELF")},
+	{FileType{"Windows or DOS executable", "application/vnd.microsoft.portable-executable"}, at(0, "MZ")},
+	{FileType{"Mach-O executable", "application/x-mach-binary"}, anyOf(at(0, "\xcf\xfa\xed\xfe"), at(0, "\xce\xfa\xed\xfe"))},
+	{FileType{"Java class or Mach-O universal binary", "application/java-vm"}, at(0, "\xca\xfe\xba\xbe")},
+	{FileType{"WebAssembly module", "application/wasm"}, at(0, "\x00asm")},
+	{FileType{"Android Dalvik executable", "application/vnd.android.dex"}, at(0, "dex\n")},
+
+	// Fonts & captures
+	{FileType{"WOFF font", "font/woff"}, at(0, "wOFF")},
+	{FileType{"WOFF2 font", "font/woff2"}, at(0, "wOF2")},
+	{FileType{"OpenType font", "font/otf"}, at(0, "OTTO")},
+	{FileType{"TrueType font", "font/ttf"}, at(0, "\x00\x01\x00\x00\x00")},
+	{FileType{"pcap capture", "application/vnd.tcpdump.pcap"}, anyOf(at(0, "\xd4\xc3\xb2\xa1"), at(0, "\xa1\xb2\xc3\xd4"))},
+	{FileType{"pcapng capture", "application/x-pcapng"}, at(0, "\x0a\x0d\x0d\x0a")},
+}
+
+// Identify tells the probable type of a file from the start of it, MagicSize
+// bytes of it at most. Files without a known signature are told apart as text
+// or not.
+func Identify(head []byte) (FileType, bool) {
+	for _, sig := range signatures {
+		if sig.match(head) {
+			return sig.FileType, true
+		}
+	}
+	if len(head) > 0 && isText(head) {
+		return FileType{Name: "Text", MediaType: "text/plain"}, true
+	}
+	return FileType{}, false
+}
+
+// Magic helpers
+
+// at matches files holding magic at offset
+func at(offset int, magic string) func([]byte) bool {
+	return func(head []byte) bool {
+		return len(head) >= offset+len(magic) && string(head[offset:offset+len(magic)]) == magic
+	}
+}
+
+// isText checks for UTF-8 without NUL bytes
+func isText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	// The start may end in the middle of a character
+	for i := 0; i < utf8.UTFMax && len(head) > 0; i++ {
+		if utf8.Valid(head) {
+			return true
+		}
+		head = head[:len(head)-1]
+	}
+	return false
+}
+
+func allOf(matches ...func([]byte) bool) func([]byte) bool {
+	return func(head []byte) bool {
+		for _, match := range matches {
+			if !match(head) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func anyOf(matches ...func([]byte) bool) func([]byte) bool {
+	return func(head []byte) bool {
+		for _, match := range matches {
+			if match(head) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,44 @@
+package meta
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdentify(t *testing.T) {
+	tar := make([]byte, 512)
+	copy(tar[257:], "ustar\x0000")
+	iso := make([]byte, MagicSize)
+	copy(iso[0x8001:], "CD001")
+
+	tests := []struct {
+		name string
+		head []byte
+		want string
+		ok   bool
+	}{
+		{name: "png", head: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), want: "PNG image", ok: true},
+		{name: "webp", head: []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), want: "WebP image", ok: true},
+		{name: "wave", head: []byte("RIFF\x24\x00\x00\x00WAVEfmt "), want: "WAVE audio", ok: true},
+		{name: "avif", head: []byte("\x00\x00\x00\x1cftypavif"), want: "AVIF image", ok: true},
+		{name: "mp4", head: []byte("\x00\x00\x00\x18ftypisom"), want: "MP4 media", ok: true},
+		{name: "deb", head: []byte("!<arch>\ndebian-binary   "), want: "Debian package", ok: true},
+		{name: "tar", head: tar, want: "tar archive", ok: true},
+		{name: "iso", head: iso, want: "ISO 9660 disk image", ok: true},
+		{name: "elf", head: []byte("\x7fELF\x02\x01\x01"), want: "ELF executable", ok: true},
+		{name: "text cut mid character", head: []byte("caf\xc3"), want: "Text", ok: true},
+		{name: "unknown", head: []byte{0x13, 0x37, 0x00, 0xff}},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		got, ok := Identify(tt.head)
+		if got.Name != tt.want || ok != tt.ok {
+			t.Errorf("Identify(%s) = %q, %v, want %q, %v", tt.name, got.Name, ok, tt.want, tt.ok)
+		}
+	}
+
+	// Latin-1 isn't taken for text
+	if got, ok := Identify([]byte(strings.Repeat("caf\xe9 ", 10))); ok {
+		t.Errorf("Identify(latin-1) = %q", got.Name)
+	}
+}
@@ -0,0 +1,334 @@
+package meta
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// EBML element IDs read from Matroska & WebM files
+const (
+	ebmlDocType       = 0x4282
+	mkvSegment        = 0x18538067
+	mkvSeekHead       = 0x114d9b74
+	mkvSeek           = 0x4dbb
+	mkvSeekID         = 0x53ab
+	mkvSeekPosition   = 0x53ac
+	mkvInfo           = 0x1549a966
+	mkvTimestampScale = 0x2ad7b1
+	mkvDuration       = 0x4489
+	mkvTitle          = 0x7ba9
+	mkvTracks         = 0x1654ae6b
+	mkvTrackEntry     = 0xae
+	mkvTrackType      = 0x83
+	mkvCodecID        = 0x86
+	mkvVideo          = 0xe0
+	mkvPixelWidth     = 0xb0
+	mkvPixelHeight    = 0xba
+	mkvAudio          = 0xe1
+	mkvSamplingFreq   = 0xb5
+	mkvChannels       = 0x9f
+	mkvTags           = 0x1254c367
+	mkvTag            = 0x7373
+	mkvSimpleTag      = 0x67c8
+	mkvTagName        = 0x45a3
+	mkvTagString      = 0x4487
+	mkvCluster        = 0x1f43b675
+)
+
+type ebmlElement struct {
+	id   uint32
+	body []byte
+}
+
+// readMatroska reads the segment info, tracks & tags of a Matroska or WebM file.
+// They usually come before the first cluster, the seek head points at the rest.
+func readMatroska(r io.ReaderAt, size int64) (*Metadata, error) {
+	head, err := readAt(r, 0, min(size, 256))
+	if err != nil {
+		return nil, err
+	}
+	// The EBML header has to be whole & of a known size, unlike the segment
+	id, headerLen, bodyLen, ok := ebmlHeader(head)
+	if !ok || id != 0x1a45dfa3 || bodyLen < 0 || headerLen+bodyLen > int64(len(head)) {
+		return nil, ErrUnsupported
+	}
+
+	md := &Metadata{Format: "matroska", Media: &Media{Container: "matroska"}}
+	for _, el := range ebmlElements(head[headerLen : headerLen+bodyLen]) {
+		if el.id == ebmlDocType && string(el.body) == "webm" {
+			md.Format, md.Media.Container = "webm", "webm"
+		}
+	}
+
+	// The segment holds everything else, its children are read one by one
+	pos := headerLen + bodyLen
+	header, err := readAt(r, pos, 12)
+	if err != nil {
+		return nil, err
+	}
+	id, headerLen, bodyLen, ok = ebmlHeader(header)
+	if !ok || id != mkvSegment {
+		return md, nil
+	}
+	segmentStart := pos + headerLen
+	segmentEnd := size
+	if bodyLen >= 0 {
+		segmentEnd = min(size, segmentStart+bodyLen)
+	}
+
+	seen := map[uint32]bool{}
+	var seeks []int64
+	read := func(id uint32, body []byte) {
+		seen[id] = true
+		switch id {
+		case mkvSeekHead:
+			for _, seek := range ebmlElements(body) {
+				if seek.id != mkvSeek {
+					continue
+				}
+				var target uint32
+				var position int64 = -1
+				for _, el := range ebmlElements(seek.body) {
+					switch el.id {
+					case mkvSeekID:
+						target = uint32(ebmlUint(el.body))
+					case mkvSeekPosition:
+						position = int64(ebmlUint(el.body))
+					}
+				}
+				if (target == mkvInfo || target == mkvTracks || target == mkvTags) && position >= 0 {
+					seeks = append(seeks, segmentStart+position)
+				}
+			}
+		case mkvInfo:
+			readMatroskaInfo(md, body)
+		case mkvTracks:
+			readMatroskaTracks(md.Media, body)
+		case mkvTags:
+			readMatroskaTags(md, body)
+		}
+	}
+
+	// Children up to the first cluster, then the ones the seek head points at
+	for pos = segmentStart; pos < segmentEnd; {
+		id, body, next, err := readEBMLElement(r, pos, segmentEnd)
+		if err != nil || id == mkvCluster {
+			break
+		}
+		if body != nil {
+			read(id, body)
+		}
+		pos = next
+	}
+	for _, seek := range seeks {
+		id, body, _, err := readEBMLElement(r, seek, segmentEnd)
+		if err == nil && body != nil && !seen[id] {
+			read(id, body)
+		}
+	}
+
+	if md.Media.Duration > 0 {
+		md.Media.Bitrate = int(float64(size) * 8 / md.Media.Duration / 1000)
+	}
+	return md, nil
+}
+
+func readMatroskaInfo(md *Metadata, info []byte) {
+	scale := uint64(1000000) // Nanoseconds per timestamp unit
+	var duration float64
+	for _, el := range ebmlElements(info) {
+		switch el.id {
+		case mkvTimestampScale:
+			scale = ebmlUint(el.body)
+		case mkvDuration:
+			duration = ebmlFloat(el.body)
+		case mkvTitle:
+			if title := string(el.body); title != "" {
+				if md.Tags == nil {
+					md.Tags = &Tags{}
+				}
+				md.Tags.Title = title
+			}
+		}
+	}
+	if duration > 0 {
+		md.Media.Duration = round(duration*float64(scale)/1e9, 3)
+	}
+}
+
+func readMatroskaTracks(media *Media, tracks []byte) {
+	for _, entry := range ebmlElements(tracks) {
+		if entry.id != mkvTrackEntry {
+			continue
+		}
+		var kind uint64
+		var codec string
+		var video, audio []byte
+		for _, el := range ebmlElements(entry.body) {
+			switch el.id {
+			case mkvTrackType:
+				kind = ebmlUint(el.body)
+			case mkvCodecID:
+				codec = string(el.body)
+			case mkvVideo:
+				video = el.body
+			case mkvAudio:
+				audio = el.body
+			}
+		}
+
+		switch {
+		case kind == 1 && media.VideoCodec == "":
+			media.VideoCodec = codec
+			for _, el := range ebmlElements(video) {
+				switch el.id {
+				case mkvPixelWidth:
+					media.Width = int(ebmlUint(el.body))
+				case mkvPixelHeight:
+					media.Height = int(ebmlUint(el.body))
+				}
+			}
+		case kind == 2 && media.AudioCodec == "":
+			media.AudioCodec = codec
+			media.Channels = 1 // The default when left out
+			for _, el := range ebmlElements(audio) {
+				switch el.id {
+				case mkvSamplingFreq:
+					media.SampleRate = int(ebmlFloat(el.body))
+				case mkvChannels:
+					media.Channels = int(ebmlUint(el.body))
+				}
+			}
+		}
+	}
+}
+
+// readMatroskaTags reads the simple tags, named like Vorbis comments
+func readMatroskaTags(md *Metadata, data []byte) {
+	tags := md.Tags
+	if tags == nil {
+		tags = &Tags{}
+	}
+	for _, tag := range ebmlElements(data) {
+		if tag.id != mkvTag {
+			continue
+		}
+		for _, simple := range ebmlElements(tag.body) {
+			if simple.id != mkvSimpleTag {
+				continue
+			}
+			var name, value string
+			for _, el := range ebmlElements(simple.body) {
+				switch el.id {
+				case mkvTagName:
+					name = string(el.body)
+				case mkvTagString:
+					value = string(el.body)
+				}
+			}
+			if name == "DATE_RELEASED" || name == "DATE_RECORDED" {
+				name = "DATE"
+			}
+			tags.setVorbis(name, value)
+		}
+	}
+	if !tags.isZero() {
+		md.Tags = tags
+	}
+}
+
+// EBML helpers
+
+// ebmlVint reads a variable length integer, keeping its length marker for IDs
+func ebmlVint(b []byte, keepMarker bool) (uint64, int, bool) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, false
+	}
+	length := bits.LeadingZeros8(b[0]) + 1
+	if len(b) < length {
+		return 0, 0, false
+	}
+	value := uint64(b[0])
+	if !keepMarker {
+		value &= 0xff >> length
+	}
+	for _, c := range b[1:length] {
+		value = value<<8 | uint64(c)
+	}
+	return value, length, true
+}
+
+// ebmlHeader reads an element's ID & body size, -1 for unknown sizes
+func ebmlHeader(b []byte) (uint32, int64, int64, bool) {
+	id, idLen, ok := ebmlVint(b, true)
+	if !ok || idLen > 4 {
+		return 0, 0, 0, false
+	}
+	size, sizeLen, ok := ebmlVint(b[idLen:], false)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	bodyLen := int64(size)
+	if size == 1<<(7*sizeLen)-1 { // All ones mean unknown
+		bodyLen = -1
+	}
+	return uint32(id), int64(idLen + sizeLen), bodyLen, true
+}
+
+// readEBMLElement reads the element at pos, returning its body only when it's one
+// of the metadata elements worth reading
+func readEBMLElement(r io.ReaderAt, pos, end int64) (uint32, []byte, int64, error) {
+	header, err := readAt(r, pos, min(12, end-pos))
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	id, headerLen, bodyLen, ok := ebmlHeader(header)
+	if !ok || bodyLen < 0 {
+		return 0, nil, 0, ErrUnsupported
+	}
+	next := pos + headerLen + bodyLen
+	switch id {
+	case mkvSeekHead, mkvInfo, mkvTracks, mkvTags:
+		if bodyLen > maxBoxSize {
+			return id, nil, next, nil
+		}
+		body, err := readAt(r, pos+headerLen, bodyLen)
+		return id, body, next, err
+	}
+	return id, nil, next, nil
+}
+
+// ebmlElements splits data into the elements it holds
+func ebmlElements(data []byte) []ebmlElement {
+	var elements []ebmlElement
+	for pos := 0; pos < len(data); {
+		id, headerLen, bodyLen, ok := ebmlHeader(data[pos:])
+		if !ok || bodyLen < 0 || int64(pos)+headerLen+bodyLen > int64(len(data)) {
+			break
+		}
+		start := pos + int(headerLen)
+		elements = append(elements, ebmlElement{id: id, body: data[start : start+int(bodyLen)]})
+		pos = start + int(bodyLen)
+	}
+	return elements
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b[:min(len(b), 8)] {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func ebmlFloat(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
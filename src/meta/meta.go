@@ -0,0 +1,214 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	headerSize  = 256 << 10 // Most formats keep their metadata in this much of the start
+	maxBoxSize  = 32 << 20  // Larger metadata structures aren't read
+	maxTagBytes = 4 << 20   // Nor are tags beyond this, they're mostly cover art past it
+)
+
+var ErrUnsupported = errors.New("unsupported file format")
+
+// Metadata is what could be read from a file, by kind of file
+type Metadata struct {
+	Format   string    `json:"format"` // e.g. jpeg, mp3, flac, mp4, matroska, pdf, epub
+	EXIF     *EXIF     `json:"exif,omitempty"`
+	Tags     *Tags     `json:"tags,omitempty"`
+	Media    *Media    `json:"media,omitempty"`
+	Document *Document `json:"document,omitempty"`
+}
+
+// Tags are the common audio tags, from ID3, Vorbis comments or MP4 items
+type Tags struct {
+	Title       string `json:"title,omitempty"`
+	Artist      string `json:"artist,omitempty"`
+	Album       string `json:"album,omitempty"`
+	AlbumArtist string `json:"albumArtist,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Year        string `json:"year,omitempty"`
+	Track       int    `json:"track,omitempty"`
+	TrackTotal  int    `json:"trackTotal,omitempty"`
+	Disc        int    `json:"disc,omitempty"`
+	DiscTotal   int    `json:"discTotal,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// Media describes the streams of audio & video files
+type Media struct {
+	Container  string  `json:"container,omitempty"`
+	Duration   float64 `json:"duration,omitempty"` // Seconds
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	VideoCodec string  `json:"videoCodec,omitempty"`
+	AudioCodec string  `json:"audioCodec,omitempty"`
+	SampleRate int     `json:"sampleRate,omitempty"` // Hz
+	Channels   int     `json:"channels,omitempty"`
+	Bitrate    int     `json:"bitrate,omitempty"` // kbit/s
+}
+
+// Document describes PDF & EPUB files
+type Document struct {
+	Version string `json:"version,omitempty"`
+	Pages   int    `json:"pages,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Author  string `json:"author,omitempty"`
+}
+
+// Read reads the metadata of a file of the given size, picking the format by its
+// first bytes & its name. Only the parts of the file holding metadata are read.
+func Read(r io.ReaderAt, size int64, name string) (*Metadata, error) {
+	head, err := readAt(r, 0, min(size, headerSize))
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(path.Ext(name))
+
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return readPDF(r, size, head)
+	case isEPUB(head):
+		return readEPUB(r, size)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return readFLAC(r, size)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return readOgg(r, size, head)
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return readMP4(r, size)
+	case bytes.HasPrefix(head, []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		return readMatroska(r, size)
+	case bytes.HasPrefix(head, []byte("ID3")), ext == ".mp3":
+		return readMP3(r, size, head)
+	}
+
+	if x, err := ReadEXIF(head); err == nil {
+		return &Metadata{Format: imageFormat(head), EXIF: x}, nil
+	}
+	if format := imageFormat(head); format != "" {
+		return &Metadata{Format: format}, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// Metadata helpers
+
+// readAt reads n bytes at off, fewer at the end of the file
+func readAt(r io.ReaderAt, off, n int64) ([]byte, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+func imageFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xff, 0xd8}):
+		return "jpeg"
+	case bytes.HasPrefix(head, []byte("\x89PNG")):
+		return "png"
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return "webp"
+	case bytes.HasPrefix(head, []byte("GIF8")):
+		return "gif"
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "tiff"
+	}
+	return ""
+}
+
+// splitNumber reads "3/12" style numbers, like track & disc numbers
+func splitNumber(s string) (int, int) {
+	num, total, _ := strings.Cut(strings.TrimSpace(s), "/")
+	return atoi(num), atoi(total)
+}
+
+// atoi reads the leading digits of s, 0 when there are none
+func atoi(s string) int {
+	n := 0
+	for _, c := range strings.TrimSpace(s) {
+		if c < '0' || c > '9' || n > 1e8 {
+			break
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
+
+func (t *Tags) isZero() bool {
+	return *t == Tags{}
+}
+
+// setVorbis sets a tag from a Vorbis comment style KEY=value field, as used by
+// FLAC & Ogg files
+func (t *Tags) setVorbis(key, value string) {
+	switch strings.ToUpper(key) {
+	case "TITLE":
+		t.Title = value
+	case "ARTIST":
+		if t.Artist == "" {
+			t.Artist = value
+		}
+	case "ALBUM":
+		t.Album = value
+	case "ALBUMARTIST", "ALBUM ARTIST":
+		t.AlbumArtist = value
+	case "GENRE":
+		t.Genre = value
+	case "DATE", "YEAR":
+		if len(value) >= 4 {
+			t.Year = value[:4]
+		}
+	case "TRACKNUMBER":
+		t.Track, t.TrackTotal = splitNumber(value)
+	case "TRACKTOTAL", "TOTALTRACKS":
+		t.TrackTotal = atoi(value)
+	case "DISCNUMBER":
+		t.Disc, t.DiscTotal = splitNumber(value)
+	case "DISCTOTAL", "TOTALDISCS":
+		t.DiscTotal = atoi(value)
+	case "COMMENT", "DESCRIPTION":
+		t.Comment = value
+	}
+}
+
+// readVorbisComment reads the comment fields following a Vorbis comment header
+func readVorbisComment(data []byte) *Tags {
+	tags := &Tags{}
+	if len(data) < 4 {
+		return tags
+	}
+	vendor := int(binary.LittleEndian.Uint32(data))
+	pos := 4 + vendor
+	if vendor < 0 || pos+4 > len(data) {
+		return tags
+	}
+	count := int(binary.LittleEndian.Uint32(data[pos:]))
+	pos += 4
+	for range count {
+		if pos+4 > len(data) {
+			break
+		}
+		length := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if length < 0 || pos+length > len(data) {
+			break
+		}
+		if key, value, ok := strings.Cut(string(data[pos:pos+length]), "="); ok {
+			tags.setVorbis(key, strings.TrimSpace(value))
+		}
+		pos += length
+	}
+	return tags
+}
@@ -0,0 +1,273 @@
+package meta
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReadMP3(t *testing.T) {
+	// 10 CBR frames of MPEG 1 layer III at 128 kbit/s & 44.1 kHz, 417 bytes each
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	frames := bytes.Repeat(frame, 10)
+
+	tag := id3v23(
+		id3Frame("TIT2", "\x00Song"),
+		id3Frame("TPE1", "\x01\xff\xfeA\x00r\x00t\x00"), // UTF-16
+		id3Frame("TALB", "\x03Album"),
+		id3Frame("TRCK", "\x003/12"),
+		id3Frame("TCON", "\x00(17)"),
+		id3Frame("COMM", "\x00eng\x00Nice"),
+	)
+
+	md := readTest(t, append(tag, frames...), "song.mp3")
+	want := Tags{Title: "Song", Artist: "Art", Album: "Album", Track: 3, TrackTotal: 12, Genre: "Rock", Comment: "Nice"}
+	if md.Format != "mp3" || md.Tags == nil || *md.Tags != want {
+		t.Fatalf("tags = %+v, want %+v", md.Tags, want)
+	}
+	if m := md.Media; m == nil || m.SampleRate != 44100 || m.Channels != 2 || m.Bitrate != 128 || m.Duration != 0.261 {
+		t.Fatalf("media = %+v", md.Media)
+	}
+
+	// VBR files state their frame count in a Xing header, ID3v1 tags sit at the end
+	xing := bytes.Clone(frames)
+	copy(xing[36:], "Xing\x00\x00\x00\x01\x00\x00\x00\x64")
+	v1 := make([]byte, 128)
+	copy(v1, "TAG")
+	copy(v1[3:], "Old Song")
+	v1[126], v1[127] = 7, 0
+
+	md = readTest(t, append(xing, v1...), "old.mp3")
+	if md.Tags == nil || md.Tags.Title != "Old Song" || md.Tags.Track != 7 || md.Tags.Genre != "Blues" {
+		t.Fatalf("ID3v1 tags = %+v", md.Tags)
+	}
+	if md.Media == nil || md.Media.Duration != 2.612 {
+		t.Fatalf("VBR media = %+v, want a 2.612s duration", md.Media)
+	}
+}
+
+func TestReadFLAC(t *testing.T) {
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint64(info[10:], 44100<<44|1<<41|15<<36|441000)
+
+	var data bytes.Buffer
+	data.WriteString("fLaC")
+	data.Write([]byte{0, 0, 0, 34})
+	data.Write(info)
+	comment := vorbisComment("TITLE=Song", "ARTIST=Band", "TRACKNUMBER=2", "TRACKTOTAL=9", "DATE=2021-03-04")
+	data.Write([]byte{0x84, 0, byte(len(comment) >> 8), byte(len(comment))})
+	data.Write(comment)
+
+	md := readTest(t, data.Bytes(), "song.flac")
+	want := Tags{Title: "Song", Artist: "Band", Track: 2, TrackTotal: 9, Year: "2021"}
+	if md.Tags == nil || *md.Tags != want {
+		t.Fatalf("tags = %+v, want %+v", md.Tags, want)
+	}
+	if m := md.Media; m.SampleRate != 44100 || m.Channels != 2 || m.Duration != 10 {
+		t.Fatalf("media = %+v", m)
+	}
+}
+
+func TestReadOgg(t *testing.T) {
+	head := []byte("OpusHead\x01\x02")
+	head = binary.LittleEndian.AppendUint16(head, 312)
+	head = binary.LittleEndian.AppendUint32(head, 44100)
+	head = append(head, 0, 0, 0)
+
+	var data []byte
+	data = append(data, oggPage(7, 0, head)...)
+	data = append(data, oggPage(7, 0, append([]byte("OpusTags"), vorbisComment("TITLE=Voice", "ALBUMARTIST=Various")...))...)
+	data = append(data, oggPage(7, 48000*5+312, make([]byte, 100))...)
+
+	md := readTest(t, data, "voice.opus")
+	if md.Tags == nil || md.Tags.Title != "Voice" || md.Tags.AlbumArtist != "Various" {
+		t.Fatalf("tags = %+v", md.Tags)
+	}
+	if m := md.Media; m.AudioCodec != "opus" || m.Channels != 2 || m.SampleRate != 44100 || m.Duration != 5 {
+		t.Fatalf("media = %+v", m)
+	}
+}
+
+func TestReadMP4(t *testing.T) {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 30500)
+
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], 1280<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], 720<<16)
+	video := box("trak", box("tkhd", tkhd), box("mdia", box("hdlr", []byte("\x00\x00\x00\x00\x00\x00\x00\x00vide")),
+		box("minf", box("stbl", box("stsd", append(make([]byte, 8), box("avc1", make([]byte, 78))...))))))
+
+	entry := make([]byte, 28)
+	binary.BigEndian.PutUint16(entry[16:], 2)
+	binary.BigEndian.PutUint32(entry[24:], 48000<<16)
+	audio := box("trak", box("mdia", box("hdlr", []byte("\x00\x00\x00\x00\x00\x00\x00\x00soun")),
+		box("minf", box("stbl", box("stsd", append(make([]byte, 8), box("mp4a", entry)...))))))
+
+	ilst := box("ilst",
+		box("\xa9nam", box("data", append(make([]byte, 8), "Clip"...))),
+		box("trkn", box("data", append(make([]byte, 8), 0, 0, 0, 4, 0, 10, 0, 0))),
+	)
+	udta := box("udta", box("meta", append(make([]byte, 4), append(box("hdlr", make([]byte, 25)), ilst...)...)))
+
+	// A moov at the end, as written by recorders
+	data := box("ftyp", []byte("isom\x00\x00\x02\x00"))
+	data = append(data, box("mdat", make([]byte, 1000))...)
+	data = append(data, box("moov", box("mvhd", mvhd), video, audio, udta)...)
+
+	md := readTest(t, data, "clip.mp4")
+	want := Media{Container: "mp4", Duration: 30.5, Width: 1280, Height: 720, VideoCodec: "avc1", AudioCodec: "mp4a", SampleRate: 48000, Channels: 2, Bitrate: md.Media.Bitrate}
+	if *md.Media != want {
+		t.Fatalf("media = %+v, want %+v", md.Media, want)
+	}
+	if md.Tags == nil || md.Tags.Title != "Clip" || md.Tags.Track != 4 || md.Tags.TrackTotal != 10 {
+		t.Fatalf("tags = %+v", md.Tags)
+	}
+}
+
+func TestReadMatroska(t *testing.T) {
+	duration := binary.BigEndian.AppendUint64(nil, math.Float64bits(12345))
+	rate := binary.BigEndian.AppendUint32(nil, math.Float32bits(48000))
+
+	data := ebml(0x1a45dfa3, ebml(ebmlDocType, []byte("webm")))
+	data = append(data, ebml(mkvSegment,
+		ebml(mkvInfo, ebml(mkvTimestampScale, []byte{0x0f, 0x42, 0x40}), ebml(mkvDuration, duration), ebml(mkvTitle, []byte("Film"))),
+		ebml(mkvTracks,
+			ebml(mkvTrackEntry, ebml(mkvTrackType, []byte{1}), ebml(mkvCodecID, []byte("V_VP9")),
+				ebml(mkvVideo, ebml(mkvPixelWidth, []byte{0x07, 0x80}), ebml(mkvPixelHeight, []byte{0x04, 0x38}))),
+			ebml(mkvTrackEntry, ebml(mkvTrackType, []byte{2}), ebml(mkvCodecID, []byte("A_OPUS")),
+				ebml(mkvAudio, ebml(mkvSamplingFreq, rate), ebml(mkvChannels, []byte{2}))),
+		),
+		ebml(mkvCluster, make([]byte, 100)),
+	)...)
+
+	md := readTest(t, data, "film.webm")
+	want := Media{Container: "webm", Duration: 12.345, Width: 1920, Height: 1080, VideoCodec: "V_VP9", AudioCodec: "A_OPUS", SampleRate: 48000, Channels: 2, Bitrate: md.Media.Bitrate}
+	if md.Format != "webm" || *md.Media != want {
+		t.Fatalf("media = %+v, want %+v", md.Media, want)
+	}
+	if md.Tags == nil || md.Tags.Title != "Film" {
+		t.Fatalf("tags = %+v", md.Tags)
+	}
+
+	// Elements of unknown size are refused in the header & skipped inside it
+	unknown := append([]byte{0x1a, 0x45, 0xdf, 0xa3, 0xff}, ebml(ebmlDocType, []byte("webm"))...)
+	if _, err := Read(bytes.NewReader(unknown), int64(len(unknown)), "film.webm"); err != ErrUnsupported {
+		t.Fatalf("Read(unknown size header) error = %v, want ErrUnsupported", err)
+	}
+	if elements := ebmlElements([]byte{0x42, 0x82, 0xff, 'w', 'e', 'b', 'm'}); len(elements) != 0 {
+		t.Fatalf("ebmlElements(unknown size) = %v, want none", elements)
+	}
+}
+
+func TestReadPDF(t *testing.T) {
+	plain := strings.Join([]string{
+		"%PDF-1.4",
+		"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj",
+		"2 0 obj << /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 /Resources << /Font << >> >> >> endobj",
+		"3 0 obj << /Type /Page /Parent 2 0 R >> endobj",
+		"9 0 obj << /Title (Annual \\(draft\\) Report) /Author <FEFF004A006F> >> endobj",
+		"trailer << /Root 1 0 R /Info 9 0 R >>",
+		"%%EOF",
+	}, "\n")
+	md := readTest(t, []byte(plain), "report.pdf")
+	want := Document{Version: "1.4", Pages: 3, Title: "Annual (draft) Report", Author: "Jo"}
+	if md.Document == nil || *md.Document != want {
+		t.Fatalf("document = %+v, want %+v", md.Document, want)
+	}
+
+	// Newer files keep their objects in compressed object streams
+	objects := "<< /Type /Pages /Count 12 >> << /Title (Compressed) >>"
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	_, _ = zw.Write([]byte("2 0 9 28 " + objects))
+	_ = zw.Close()
+	packed := "%PDF-1.7\n7 0 obj << /Type /ObjStm /N 2 /First 9 /Filter /FlateDecode >> stream\n" +
+		stream.String() + "\nendstream endobj\n8 0 obj << /Type /XRef /Root 1 0 R /Info 9 0 R >> endobj\n%%EOF"
+	md = readTest(t, []byte(packed), "packed.pdf")
+	want = Document{Version: "1.7", Pages: 12, Title: "Compressed"}
+	if *md.Document != want {
+		t.Fatalf("document = %+v, want %+v", md.Document, want)
+	}
+}
+
+func TestReadUnsupported(t *testing.T) {
+	if _, err := Read(bytes.NewReader([]byte("just text")), 9, "notes.txt"); err != ErrUnsupported {
+		t.Fatalf("Read() error = %v, want ErrUnsupported", err)
+	}
+}
+
+func readTest(t *testing.T, data []byte, name string) *Metadata {
+	t.Helper()
+	md, err := Read(bytes.NewReader(data), int64(len(data)), name)
+	if err != nil {
+		t.Fatalf("Read(%s) error = %v", name, err)
+	}
+	return md
+}
+
+func id3v23(frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	body = append(body, make([]byte, 16)...) // Padding
+	size := len(body)
+	return append([]byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}, body...)
+}
+
+func id3Frame(id, data string) []byte {
+	frame := binary.BigEndian.AppendUint32([]byte(id), uint32(len(data)))
+	return append(append(frame, 0, 0), data...)
+}
+
+func vorbisComment(fields ...string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, 4)
+	data = append(data, "test"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(fields)))
+	for _, f := range fields {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(f)))
+		data = append(data, f...)
+	}
+	return data
+}
+
+// oggPage wraps a packet of under 64 KiB in a page, without a valid checksum
+func oggPage(serial uint32, granule uint64, packet []byte) []byte {
+	page := append([]byte("OggS"), 0, 0)
+	page = binary.LittleEndian.AppendUint64(page, granule)
+	page = binary.LittleEndian.AppendUint32(page, serial)
+	page = append(page, make([]byte, 8)...) // Sequence number & checksum
+
+	var lacing []byte
+	for n := len(packet); ; n -= 255 {
+		if n < 255 {
+			lacing = append(lacing, byte(n))
+			break
+		}
+		lacing = append(lacing, 255)
+	}
+	page = append(page, byte(len(lacing)))
+	return append(append(page, lacing...), packet...)
+}
+
+func box(kind string, children ...[]byte) []byte {
+	body := bytes.Join(children, nil)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(body)+8)), append([]byte(kind), body...)...)
+}
+
+// ebml encodes an element with an 8 byte size
+func ebml(id uint32, children ...[]byte) []byte {
+	var out []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	body := bytes.Join(children, nil)
+	out = append(out, 0x01)
+	out = append(out, binary.BigEndian.AppendUint64(nil, uint64(len(body)))[1:]...)
+	return append(out, body...)
+}
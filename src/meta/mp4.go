@@ -0,0 +1,247 @@
+package meta
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// MP4 brands that name their container better than "mp4"
+var mp4Brands = map[string]string{"M4A ": "m4a", "M4B ": "m4b", "M4V ": "m4v", "qt  ": "mov", "3gp4": "3gp", "3gp5": "3gp"}
+
+type mp4Box struct {
+	kind string
+	body []byte
+}
+
+// readMP4 reads the duration, tracks & iTunes style tags of an MP4 or QuickTime
+// file from its moov box, wherever in the file it is
+func readMP4(r io.ReaderAt, size int64) (*Metadata, error) {
+	md := &Metadata{Format: "mp4", Media: &Media{Container: "mp4"}}
+
+	for pos := int64(0); pos+8 <= size; {
+		header, err := readAt(r, pos, 16)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) < 8 {
+			break
+		}
+		boxSize, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		kind := string(header[4:8])
+		switch {
+		case boxSize == 1 && len(header) == 16:
+			boxSize, headerSize = int64(binary.BigEndian.Uint64(header[8:])), 16
+		case boxSize == 0:
+			boxSize = size - pos
+		}
+		if boxSize < headerSize {
+			break
+		}
+
+		switch kind {
+		case "ftyp":
+			if brand := string(header[8:12]); mp4Brands[brand] != "" {
+				md.Media.Container = mp4Brands[brand]
+			}
+		case "moov":
+			if boxSize > maxBoxSize {
+				return md, nil
+			}
+			moov, err := readAt(r, pos+headerSize, boxSize-headerSize)
+			if err != nil {
+				return nil, err
+			}
+			readMoov(md, moov)
+			if md.Media.Duration > 0 {
+				md.Media.Bitrate = int(float64(size) * 8 / md.Media.Duration / 1000)
+			}
+			return md, nil
+		}
+		pos += boxSize
+	}
+	return md, nil
+}
+
+func readMoov(md *Metadata, moov []byte) {
+	for _, box := range mp4Boxes(moov) {
+		switch box.kind {
+		case "mvhd":
+			if timescale, duration := mp4Duration(box.body); timescale > 0 {
+				md.Media.Duration = round(float64(duration)/float64(timescale), 3)
+			}
+		case "trak":
+			readTrak(md.Media, box.body)
+		case "udta":
+			for _, meta := range mp4Boxes(box.body) {
+				if meta.kind != "meta" {
+					continue
+				}
+				// QuickTime's meta box lacks the version & flags of MP4's
+				body := meta.body
+				if len(body) >= 8 && string(body[4:8]) != "hdlr" {
+					body = body[4:]
+				}
+				for _, ilst := range mp4Boxes(body) {
+					if ilst.kind == "ilst" {
+						if tags := readIlst(ilst.body); !tags.isZero() {
+							md.Tags = tags
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// readTrak reads the handler, dimensions & codec of a track
+func readTrak(media *Media, trak []byte) {
+	var handler, codec string
+	var width, height, channels, rate int
+	for _, box := range mp4Boxes(trak) {
+		switch box.kind {
+		case "tkhd":
+			// Dimensions are the last 8 bytes, as 16.16 fixed point
+			if n := len(box.body); n >= 84 {
+				width = int(binary.BigEndian.Uint32(box.body[n-8:]) >> 16)
+				height = int(binary.BigEndian.Uint32(box.body[n-4:]) >> 16)
+			}
+		case "mdia":
+			for _, mdia := range mp4Boxes(box.body) {
+				switch mdia.kind {
+				case "hdlr":
+					if len(mdia.body) >= 12 {
+						handler = string(mdia.body[8:12])
+					}
+				case "minf":
+					codec, channels, rate = readStsd(mdia.body)
+				}
+			}
+		}
+	}
+
+	switch handler {
+	case "vide":
+		if media.VideoCodec == "" {
+			media.VideoCodec, media.Width, media.Height = codec, width, height
+		}
+	case "soun":
+		if media.AudioCodec == "" {
+			media.AudioCodec, media.Channels, media.SampleRate = codec, channels, rate
+		}
+	}
+}
+
+// readStsd finds the first sample description within a minf box
+func readStsd(minf []byte) (string, int, int) {
+	for _, stbl := range mp4Boxes(minf) {
+		if stbl.kind != "stbl" {
+			continue
+		}
+		for _, stsd := range mp4Boxes(stbl.body) {
+			if stsd.kind != "stsd" || len(stsd.body) < 16 {
+				continue
+			}
+			entry := stsd.body[8:]
+			codec := strings.TrimSpace(string(entry[4:8]))
+			// Audio sample entries carry channels & the rate after 16 reserved bytes
+			if len(entry) >= 36 && (codec == "mp4a" || codec == "alac" || codec == "ac-3" || codec == "ec-3" || codec == "Opus" || codec == "fLaC") {
+				return codec, int(binary.BigEndian.Uint16(entry[24:])), int(binary.BigEndian.Uint32(entry[32:]) >> 16)
+			}
+			return codec, 0, 0
+		}
+	}
+	return "", 0, 0
+}
+
+// readIlst reads the iTunes style metadata items
+func readIlst(ilst []byte) *Tags {
+	tags := &Tags{}
+	for _, item := range mp4Boxes(ilst) {
+		var data []byte
+		for _, box := range mp4Boxes(item.body) {
+			if box.kind == "data" && len(box.body) >= 8 {
+				data = box.body[8:] // After the type & locale
+				break
+			}
+		}
+		if data == nil {
+			continue
+		}
+
+		text := strings.TrimSpace(string(data))
+		switch item.kind {
+		case "\xa9nam":
+			tags.Title = text
+		case "\xa9ART":
+			tags.Artist = text
+		case "\xa9alb":
+			tags.Album = text
+		case "aART":
+			tags.AlbumArtist = text
+		case "\xa9gen":
+			tags.Genre = text
+		case "gnre":
+			if len(data) >= 2 {
+				if n := int(binary.BigEndian.Uint16(data)) - 1; n >= 0 && n < len(id3Genres) {
+					tags.Genre = id3Genres[n]
+				}
+			}
+		case "\xa9day":
+			if len(text) >= 4 {
+				tags.Year = text[:4]
+			}
+		case "trkn":
+			if len(data) >= 6 {
+				tags.Track, tags.TrackTotal = int(binary.BigEndian.Uint16(data[2:])), int(binary.BigEndian.Uint16(data[4:]))
+			}
+		case "disk":
+			if len(data) >= 6 {
+				tags.Disc, tags.DiscTotal = int(binary.BigEndian.Uint16(data[2:])), int(binary.BigEndian.Uint16(data[4:]))
+			}
+		case "\xa9cmt", "desc":
+			tags.Comment = text
+		}
+	}
+	return tags
+}
+
+// MP4 helpers
+
+// mp4Boxes splits data into the boxes it holds, ignoring a truncated last one
+func mp4Boxes(data []byte) []mp4Box {
+	var boxes []mp4Box
+	for pos := 0; pos+8 <= len(data); {
+		size, headerSize := int(binary.BigEndian.Uint32(data[pos:])), 8
+		kind := string(data[pos+4 : pos+8])
+		switch {
+		case size == 1 && pos+16 <= len(data):
+			size, headerSize = int(binary.BigEndian.Uint64(data[pos+8:])), 16
+		case size == 0:
+			size = len(data) - pos
+		}
+		if size < headerSize || pos+size > len(data) {
+			break
+		}
+		boxes = append(boxes, mp4Box{kind: kind, body: data[pos+headerSize : pos+size]})
+		pos += size
+	}
+	return boxes
+}
+
+// mp4Duration reads the timescale & duration of an mvhd box
+func mp4Duration(body []byte) (uint32, uint64) {
+	if len(body) < 4 {
+		return 0, 0
+	}
+	if body[0] == 1 { // 64-bit times
+		if len(body) < 32 {
+			return 0, 0
+		}
+		return binary.BigEndian.Uint32(body[20:]), binary.BigEndian.Uint64(body[24:])
+	}
+	if len(body) < 20 {
+		return 0, 0
+	}
+	return binary.BigEndian.Uint32(body[12:]), uint64(binary.BigEndian.Uint32(body[16:]))
+}
@@ -0,0 +1,294 @@
+package meta
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PDFs up to this size are read whole, of larger ones the start & end are read,
+// where linearized files & the trailer keep what's looked for
+const (
+	pdfScanSize = 16 << 20
+	pdfEdgeSize = 2 << 20
+)
+
+var (
+	pdfVersion    = regexp.MustCompile(`^%PDF-(\d\.\d)`)
+	pdfLinearized = regexp.MustCompile(`/Linearized\b[^>]*?/N\s+(\d+)`)
+	pdfPagesType  = regexp.MustCompile(`/Type\s*/Pages[\s/>]`)
+	pdfCount      = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfInfoRef    = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	pdfObjStm     = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	pdfFirst      = regexp.MustCompile(`/First\s+(\d+)`)
+)
+
+// readPDF reads the version, page count, title & author of a PDF. It scans the
+// file's objects as text, inflating compressed object streams, rather than
+// following the cross-reference table.
+func readPDF(r io.ReaderAt, size int64, head []byte) (*Metadata, error) {
+	doc := &Document{}
+	if m := pdfVersion.FindSubmatch(head); m != nil {
+		doc.Version = string(m[1])
+	}
+
+	var data []byte
+	var err error
+	if size <= pdfScanSize {
+		data, err = readAt(r, 0, size)
+	} else {
+		var start, end []byte
+		if start, err = readAt(r, 0, pdfEdgeSize); err == nil {
+			end, err = readAt(r, size-pdfEdgeSize, pdfEdgeSize)
+			data = append(start, end...)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects within compressed object streams are scanned along with the rest
+	sources := [][]byte{data}
+	for _, loc := range pdfObjStm.FindAllIndex(data, -1) {
+		if stream := pdfObjectStream(data, loc[0]); stream != nil {
+			sources = append(sources, stream)
+		}
+	}
+
+	// Linearized files state the page count up front, others are counted from the
+	// page tree, whose root holds the largest count
+	if m := pdfLinearized.FindSubmatch(data[:min(len(data), 4096)]); m != nil {
+		doc.Pages, _ = strconv.Atoi(string(m[1]))
+	}
+	if doc.Pages == 0 {
+		for _, src := range sources {
+			for _, loc := range pdfPagesType.FindAllIndex(src, -1) {
+				start, end := pdfDictBounds(src, loc[0])
+				if m := pdfCount.FindSubmatch(src[start:end]); m != nil {
+					doc.Pages = max(doc.Pages, atoi(string(m[1])))
+				}
+			}
+		}
+	}
+
+	// The trailer names the document info dictionary, the last one is current
+	if refs := pdfInfoRef.FindAllSubmatch(data, -1); refs != nil {
+		ref := refs[len(refs)-1]
+		if info := pdfFindObject(sources, string(ref[1]), string(ref[2])); info != nil {
+			doc.Title = pdfDictString(info, "Title")
+			doc.Author = pdfDictString(info, "Author")
+		}
+	}
+
+	return &Metadata{Format: "pdf", Document: doc}, nil
+}
+
+// pdfObjectStream inflates the object stream whose dictionary is around pos,
+// laying out its objects like "n 0 obj ... endobj" so they're found like the rest
+func pdfObjectStream(data []byte, pos int) []byte {
+	dictStart, dictEnd := pdfDictBounds(data, pos)
+	dict := data[dictStart:dictEnd]
+	if !bytes.Contains(dict, []byte("/FlateDecode")) {
+		return nil
+	}
+	m := pdfFirst.FindSubmatch(dict)
+	if m == nil {
+		return nil
+	}
+	first := atoi(string(m[1]))
+
+	rest := data[dictEnd:]
+	start := bytes.Index(rest, []byte("stream"))
+	end := bytes.Index(rest, []byte("endstream"))
+	if start < 0 || end < start {
+		return nil
+	}
+	raw := bytes.TrimLeft(rest[start+len("stream"):end], "\r\n")
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	stream, _ := io.ReadAll(io.LimitReader(zr, maxBoxSize))
+	if first > len(stream) {
+		return nil
+	}
+
+	// The stream starts with pairs of object numbers & offsets from First
+	pairs := strings.Fields(string(stream[:first]))
+	var out bytes.Buffer
+	for i := 0; i+1 < len(pairs); i += 2 {
+		offset := first + atoi(pairs[i+1])
+		next := len(stream)
+		if i+3 < len(pairs) {
+			next = first + atoi(pairs[i+3])
+		}
+		if offset > next || next > len(stream) {
+			break
+		}
+		out.WriteString(pairs[i] + " 0 obj\n")
+		out.Write(stream[offset:next])
+		out.WriteString("\nendobj\n")
+	}
+	return out.Bytes()
+}
+
+// pdfFindObject finds the dictionary of object "num gen obj"
+func pdfFindObject(sources [][]byte, num, gen string) []byte {
+	pattern := regexp.MustCompile(`(?:^|[^\d])` + num + `\s+` + gen + `\s+obj\b`)
+	for _, src := range sources {
+		locs := pattern.FindAllIndex(src, -1)
+		if locs == nil {
+			continue
+		}
+		// Incremental updates append newer versions, so the last one wins
+		loc := locs[len(locs)-1]
+		open := bytes.Index(src[loc[1]:], []byte("<<"))
+		if open < 0 {
+			continue
+		}
+		start, end := pdfDictBounds(src, loc[1]+open+2)
+		return src[start:end]
+	}
+	return nil
+}
+
+// pdfDictBounds finds the dictionary enclosing pos, tracking nested dictionaries
+func pdfDictBounds(data []byte, pos int) (int, int) {
+	start, depth := 0, 0
+	for i := pos - 1; i > 0; i-- {
+		if data[i-1] == '>' && data[i] == '>' {
+			depth++
+			i--
+		} else if data[i-1] == '<' && data[i] == '<' {
+			if depth == 0 {
+				start = i - 1
+				break
+			}
+			depth--
+			i--
+		}
+	}
+
+	end := len(data)
+	depth = 0
+	for i := start + 2; i+1 < len(data); i++ {
+		if data[i] == '<' && data[i+1] == '<' {
+			depth++
+			i++
+		} else if data[i] == '>' && data[i+1] == '>' {
+			if depth == 0 {
+				end = i + 2
+				break
+			}
+			depth--
+			i++
+		}
+	}
+	return start, end
+}
+
+// pdfDictString reads a string value of a dictionary, in literal or hex form
+func pdfDictString(dict []byte, key string) string {
+	i := bytes.Index(dict, []byte("/"+key))
+	if i < 0 {
+		return ""
+	}
+	rest := bytes.TrimLeft(dict[i+len(key)+1:], " \t\r\n")
+	if len(rest) == 0 {
+		return ""
+	}
+
+	var raw []byte
+	switch rest[0] {
+	case '(':
+		raw = pdfLiteral(rest[1:])
+	case '<':
+		end := bytes.IndexByte(rest, '>')
+		if end < 0 {
+			return ""
+		}
+		hex := bytes.Join(bytes.Fields(rest[1:end]), nil)
+		if len(hex)%2 == 1 {
+			hex = append(hex, '0')
+		}
+		for j := 0; j+1 < len(hex); j += 2 {
+			b, err := strconv.ParseUint(string(hex[j:j+2]), 16, 8)
+			if err != nil {
+				return ""
+			}
+			raw = append(raw, byte(b))
+		}
+	default:
+		return ""
+	}
+	return strings.TrimSpace(pdfText(raw))
+}
+
+// pdfLiteral reads a literal string up to its closing parenthesis, resolving
+// escapes & balanced parentheses
+func pdfLiteral(b []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == '\\' && i+1 < len(b):
+			i++
+			switch e := b[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n': // Line continuation
+				if e == '\r' && i+1 < len(b) && b[i+1] == '\n' {
+					i++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for j := 0; j < 3 && i < len(b) && b[i] >= '0' && b[i] <= '7'; j++ {
+						n = n*8 + int(b[i]-'0')
+						i++
+					}
+					i--
+					out = append(out, byte(n))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case c == '(':
+			depth++
+			out = append(out, c)
+		case c == ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// pdfText decodes a text string, UTF-16 when it starts with a byte order mark &
+// PDFDocEncoding, taken as Latin-1, otherwise
+func pdfText(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xfe, 0xff}):
+		return decodeUTF16(b, true)
+	case bytes.HasPrefix(b, []byte{0xef, 0xbb, 0xbf}):
+		return string(b[3:])
+	}
+	return latin1(b)
+}
@@ -0,0 +1,169 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// readFLAC reads the stream info & Vorbis comment blocks of a FLAC file
+func readFLAC(r io.ReaderAt, size int64) (*Metadata, error) {
+	md := &Metadata{Format: "flac", Media: &Media{Container: "flac", AudioCodec: "flac"}}
+
+	for pos := int64(4); pos+4 <= size; {
+		header, err := readAt(r, pos, 4)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) < 4 {
+			break
+		}
+		last, kind := header[0]&0x80 != 0, header[0]&0x7f
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		pos += 4
+
+		switch kind {
+		case 0: // STREAMINFO
+			info, err := readAt(r, pos, min(length, 34))
+			if err != nil {
+				return nil, err
+			}
+			if len(info) == 34 {
+				packed := binary.BigEndian.Uint64(info[10:18])
+				rate := int(packed >> 44)
+				samples := packed & (1<<36 - 1)
+				md.Media.SampleRate = rate
+				md.Media.Channels = int(packed>>41&7) + 1
+				if rate > 0 && samples > 0 {
+					md.Media.Duration = round(float64(samples)/float64(rate), 3)
+					md.Media.Bitrate = int(float64(size) * 8 / md.Media.Duration / 1000)
+				}
+			}
+		case 4: // VORBIS_COMMENT
+			comment, err := readAt(r, pos, min(length, maxTagBytes))
+			if err != nil {
+				return nil, err
+			}
+			if tags := readVorbisComment(comment); !tags.isZero() {
+				md.Tags = tags
+			}
+		}
+
+		pos += length
+		if last {
+			break
+		}
+	}
+	return md, nil
+}
+
+// readOgg reads the identification & comment headers of the first logical stream
+// of an Ogg Vorbis or Opus file, and its duration from the last page
+func readOgg(r io.ReaderAt, size int64, head []byte) (*Metadata, error) {
+	packets, serial := oggPackets(head, 2)
+	if len(packets) == 0 {
+		return nil, ErrUnsupported
+	}
+
+	md := &Metadata{Format: "ogg", Media: &Media{Container: "ogg"}}
+	id := packets[0]
+	rate, preSkip := 0, 0
+	switch {
+	case bytes.HasPrefix(id, []byte("\x01vorbis")) && len(id) >= 16:
+		md.Media.AudioCodec = "vorbis"
+		md.Media.Channels = int(id[11])
+		rate = int(binary.LittleEndian.Uint32(id[12:]))
+		md.Media.SampleRate = rate
+	case bytes.HasPrefix(id, []byte("OpusHead")) && len(id) >= 16:
+		md.Media.AudioCodec = "opus"
+		md.Media.Channels = int(id[9])
+		preSkip = int(binary.LittleEndian.Uint16(id[10:]))
+		md.Media.SampleRate = int(binary.LittleEndian.Uint32(id[12:]))
+		rate = 48000 // Opus granule positions always count at 48 kHz
+	case bytes.HasPrefix(id, []byte("\x7fFLAC")):
+		md.Media.AudioCodec = "flac"
+	default:
+		return md, nil
+	}
+
+	if len(packets) > 1 {
+		comment := packets[1]
+		for _, prefix := range []string{"\x03vorbis", "OpusTags"} {
+			if bytes.HasPrefix(comment, []byte(prefix)) {
+				if tags := readVorbisComment(comment[len(prefix):]); !tags.isZero() {
+					md.Tags = tags
+				}
+			}
+		}
+	}
+
+	// The granule position of the last page is the sample count
+	tail, err := readAt(r, max(0, size-64<<10), min(size, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0 && rate > 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+27 > len(tail) || binary.LittleEndian.Uint32(tail[i+14:]) != serial {
+			continue
+		}
+		granule := int64(binary.LittleEndian.Uint64(tail[i+6:]))
+		if granule > int64(preSkip) {
+			md.Media.Duration = round(float64(granule-int64(preSkip))/float64(rate), 3)
+			md.Media.Bitrate = int(float64(size) * 8 / md.Media.Duration / 1000)
+			break
+		}
+	}
+	return md, nil
+}
+
+// oggPackets reassembles the first n packets of the first logical stream in data,
+// returning them with the stream's serial number
+func oggPackets(data []byte, n int) ([][]byte, uint32) {
+	var packets [][]byte
+	var current []byte
+	var serial uint32
+
+	for pos := 0; pos+27 <= len(data) && len(packets) < n; {
+		if !bytes.Equal(data[pos:pos+4], []byte("OggS")) {
+			break
+		}
+		pageSerial := binary.LittleEndian.Uint32(data[pos+14:])
+		if pos == 0 {
+			serial = pageSerial
+		}
+		segments := int(data[pos+26])
+		if pos+27+segments > len(data) {
+			break
+		}
+		lacing := data[pos+27 : pos+27+segments]
+		body := pos + 27 + segments
+		for _, l := range lacing {
+			body += int(l)
+		}
+		if body > len(data) {
+			break
+		}
+		if pageSerial != serial { // Other streams are interleaved, like a video's audio
+			pos = body
+			continue
+		}
+
+		at := pos + 27 + segments
+		for _, l := range lacing {
+			current = append(current, data[at:at+int(l)]...)
+			at += int(l)
+			if l < 255 { // A packet ends with a segment shorter than 255 bytes
+				packets = append(packets, current)
+				current = nil
+				if len(packets) == n {
+					break
+				}
+			}
+		}
+		if len(current) > maxTagBytes {
+			break
+		}
+		pos = body
+	}
+	return packets, serial
+}
@@ -1,10 +1,20 @@
 package models
 
-import "github.com/rs/zerolog"
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.etcd.io/bbolt"
+)
 
 type AppConfig struct {
-	Server ServerConfig `yaml:"server"`
-	Paths  []PathConfig `yaml:"paths"`
+	Server     ServerConfig    `yaml:"server"`
+	Archive    ArchiveConfig   `yaml:"archive"`
+	Preview    PreviewConfig   `yaml:"preview"`
+	Thumbnails ThumbnailConfig `yaml:"thumbnails"`
+	Gallery    GalleryConfig   `yaml:"gallery"`
+	Search     SearchConfig    `yaml:"search"`
+	Paths      []PathConfig    `yaml:"paths"`
 }
 
 type ServerConfig struct {
@@ -13,14 +23,84 @@ type ServerConfig struct {
 	Address  string `yaml:"address"`
 }
 
+type ArchiveConfig struct {
+	MaxBytes int64 `yaml:"maxBytes"`
+	MaxFiles int   `yaml:"maxFiles"`
+}
+
+type PreviewConfig struct {
+	MaxSize int64 `yaml:"maxSize"` // Bytes, larger files are offered as downloads
+}
+
+type ThumbnailConfig struct {
+	Disable      bool   `yaml:"disable"`
+	CacheDir     string `yaml:"cacheDir"`     // data/thumbs beside the executable when empty
+	MaxCacheSize int64  `yaml:"maxCacheSize"` // Bytes, least recently used thumbnails go first
+}
+
+type GalleryConfig struct {
+	SlideshowInterval time.Duration `yaml:"slideshowInterval"` // e.g. 5s, the default delay between slides
+}
+
+type SearchConfig struct {
+	Disable         bool          `yaml:"disable"`
+	RefreshInterval time.Duration `yaml:"refreshInterval"` // e.g. 6h, rebuilds only at startup when 0
+}
+
 type PathConfig struct {
-	Name    string `yaml:"name"`
-	Path    string `yaml:"path"`
-	Disable bool   `yaml:"disable"`
+	Name                  string             `yaml:"name"`
+	Type                  string             `yaml:"type"` // local (default), zip, s3, sftp
+	Path                  string             `yaml:"path"`
+	Disable               bool               `yaml:"disable"`
+	AllowExternalSymlinks bool               `yaml:"allowExternalSymlinks"`
+	ContentIndex          ContentIndexConfig `yaml:"contentIndex"`
+	S3                    *S3Config          `yaml:"s3"`
+	SFTP                  *SFTPConfig        `yaml:"sftp"`
+}
+
+type ContentIndexConfig struct {
+	Enable      bool     `yaml:"enable"`
+	MaxFileSize int64    `yaml:"maxFileSize"` // Bytes, defaults to 10 MiB
+	Include     []string `yaml:"include"`     // Globs like *.go or logs/*.log, text-like files when empty
+	Exclude     []string `yaml:"exclude"`
+}
+
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint"` // e.g. https://minio.internal:9000
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	Prefix          string `yaml:"prefix"`
+	VirtualHosted   bool   `yaml:"virtualHosted"`   // bucket.endpoint instead of endpoint/bucket
+	CredentialsFile string `yaml:"credentialsFile"` // AWS shared credentials format
+	Profile         string `yaml:"profile"`
+	AccessKeyEnv    string `yaml:"accessKeyEnv"` // Defaults to AWS_ACCESS_KEY_ID
+	SecretKeyEnv    string `yaml:"secretKeyEnv"` // Defaults to AWS_SECRET_ACCESS_KEY
+}
+
+type SFTPConfig struct {
+	Host             string `yaml:"host"`
+	Port             int    `yaml:"port"` // Defaults to 22
+	User             string `yaml:"user"`
+	KeyFile          string `yaml:"keyFile"`
+	KeyPassphraseEnv string `yaml:"keyPassphraseEnv"`
+	KnownHosts       string `yaml:"knownHosts"` // Defaults to ~/.ssh/known_hosts
 }
 
 type AppContext struct {
 	Config *AppConfig
-	// DBConn *DBConn
+	DBConn *bbolt.DB
 	Logger *zerolog.Logger
 }
+
+type FileEntry struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"` // Slash-separated, relative to the configured path root
+	Size      int64     `json:"size"`
+	Mode      string    `json:"mode"`
+	ModTime   time.Time `json:"mtime"`
+	MimeType  string    `json:"mimeType,omitempty"`
+	IsDir     bool      `json:"isDir"`
+	IsSymlink bool      `json:"isSymlink"`
+	IsArchive bool      `json:"isArchive"` // Browsable like a folder
+	Kind      string    `json:"-"`
+}
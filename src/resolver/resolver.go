@@ -0,0 +1,95 @@
+package resolver
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/patppuccin/viewr/src/models"
+)
+
+var (
+	ErrInvalidPath = errors.New("invalid path")
+	ErrOutsideRoot = errors.New("path resolves outside of the configured root")
+)
+
+// Clean validates a client-supplied path and normalizes it into a slash-separated
+// path relative to a configured root. The root itself is returned as "". Paths
+// are expected decoded, percent signs in them are part of names; encoded
+// separators are refused where URLs are decoded.
+func Clean(p string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+
+	// Reject NUL bytes and backslash separators
+	if strings.ContainsRune(p, 0) || strings.ContainsRune(p, '\\') {
+		return "", ErrInvalidPath
+	}
+
+	// Reject absolute paths and volume names (e.g. "/etc", "C:/Windows")
+	if strings.HasPrefix(p, "/") || filepath.VolumeName(filepath.FromSlash(p)) != "" {
+		return "", ErrInvalidPath
+	}
+
+	// Reject any parent reference, even ones that would stay inside the root
+	for segment := range strings.SplitSeq(p, "/") {
+		if segment == ".." {
+			return "", ErrOutsideRoot
+		}
+	}
+
+	cleaned := path.Clean(p)
+	if cleaned == "." {
+		return "", nil
+	}
+	if !fs.ValidPath(cleaned) {
+		return "", ErrInvalidPath
+	}
+	return cleaned, nil
+}
+
+// Resolve maps a client-supplied path onto the local filesystem of a configured
+// root. Symlinks are followed, and the final target must stay within the root
+// unless the root explicitly allows external symlinks.
+func Resolve(pathCfg models.PathConfig, p string) (string, error) {
+	rel, err := Clean(p)
+	if err != nil {
+		return "", err
+	}
+
+	// The configured root is trusted, even when it is itself a symlink
+	root, err := filepath.Abs(pathCfg.Path)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	fullPath := filepath.Join(root, filepath.FromSlash(rel))
+	if pathCfg.AllowExternalSymlinks {
+		return fullPath, nil
+	}
+
+	realPath, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if !IsWithin(root, realPath) {
+		return "", ErrOutsideRoot
+	}
+	return realPath, nil
+}
+
+// IsWithin reports whether target is root itself or lies beneath it.
+func IsWithin(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel))
+}
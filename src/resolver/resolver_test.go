@@ -0,0 +1,172 @@
+package resolver
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/patppuccin/viewr/src/models"
+)
+
+func TestClean(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "empty is root", input: "", want: ""},
+		{name: "dot is root", input: ".", want: ""},
+		{name: "plain file", input: "docs/readme.md", want: "docs/readme.md"},
+		{name: "spaces are kept", input: "sub dir/a file.txt", want: "sub dir/a file.txt"},
+		{name: "duplicate separators", input: "docs//readme.md", want: "docs/readme.md"},
+		{name: "dot segments", input: "./docs/./readme.md", want: "docs/readme.md"},
+		{name: "trailing separator", input: "docs/", want: "docs"},
+		{name: "dotfile", input: ".config/app", want: ".config/app"},
+		{name: "triple dot is a name", input: ".../x", want: ".../x"},
+
+		{name: "parent escape", input: "../etc/passwd", wantErr: ErrOutsideRoot},
+		{name: "bare parent", input: "..", wantErr: ErrOutsideRoot},
+		{name: "nested parent escape", input: "docs/../../etc", wantErr: ErrOutsideRoot},
+		{name: "parent that stays inside", input: "docs/../readme.md", wantErr: ErrOutsideRoot},
+		{name: "trailing parent", input: "docs/..", wantErr: ErrOutsideRoot},
+
+		{name: "absolute path", input: "/etc/passwd", wantErr: ErrInvalidPath},
+		{name: "double slash absolute", input: "//server/share", wantErr: ErrInvalidPath},
+		{name: "backslash separator", input: `docs\..\..\etc`, wantErr: ErrInvalidPath},
+		{name: "windows absolute", input: `C:\Windows\win.ini`, wantErr: ErrInvalidPath},
+		{name: "nul byte", input: "readme.md\x00.png", wantErr: ErrInvalidPath},
+
+		{name: "percent signs are names", input: "logs/100%2e.txt", want: "logs/100%2e.txt"},
+		{name: "encoded slash is a name", input: "..%2F..%2Fetc", want: "..%2F..%2Fetc"},
+		{name: "encoded dots are a name", input: "%2e%2e/etc", want: "%2e%2e/etc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Clean(tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Clean(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Clean(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Clean(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+
+	mustMkdir(t, filepath.Join(root, "docs"))
+	mustMkdir(t, outside)
+	mustWrite(t, filepath.Join(root, "docs", "readme.md"))
+	mustWrite(t, filepath.Join(outside, "secret.txt"))
+	mustSymlink(t, filepath.Join(root, "docs"), filepath.Join(root, "internal-link"))
+	mustSymlink(t, filepath.Join(root, "docs", "readme.md"), filepath.Join(root, "readme-link.md"))
+	mustSymlink(t, outside, filepath.Join(root, "external-link"))
+	mustSymlink(t, filepath.Join(outside, "secret.txt"), filepath.Join(root, "docs", "secret-link.txt"))
+	mustSymlink(t, "../../outside", filepath.Join(root, "docs", "relative-link"))
+
+	tests := []struct {
+		name          string
+		input         string
+		allowExternal bool
+		want          string
+		wantErr       error
+	}{
+		{name: "root", input: "", want: root},
+		{name: "regular file", input: "docs/readme.md", want: filepath.Join(root, "docs", "readme.md")},
+		{name: "internal dir symlink", input: "internal-link/readme.md", want: filepath.Join(root, "docs", "readme.md")},
+		{name: "internal file symlink", input: "readme-link.md", want: filepath.Join(root, "docs", "readme.md")},
+		{name: "missing file", input: "docs/missing.md", wantErr: fs.ErrNotExist},
+
+		{name: "external dir symlink", input: "external-link", wantErr: ErrOutsideRoot},
+		{name: "file through external dir symlink", input: "external-link/secret.txt", wantErr: ErrOutsideRoot},
+		{name: "external file symlink", input: "docs/secret-link.txt", wantErr: ErrOutsideRoot},
+		{name: "relative external symlink", input: "docs/relative-link/secret.txt", wantErr: ErrOutsideRoot},
+		{name: "parent escape", input: "../outside/secret.txt", wantErr: ErrOutsideRoot},
+		{name: "absolute injection", input: filepath.ToSlash(outside), wantErr: ErrInvalidPath},
+		{name: "encoded escape is a name", input: "..%2foutside", wantErr: fs.ErrNotExist},
+
+		{name: "allowed external dir symlink", input: "external-link/secret.txt", allowExternal: true, want: filepath.Join(root, "external-link", "secret.txt")},
+		{name: "allowed root still refuses parent escape", input: "../outside", allowExternal: true, wantErr: ErrOutsideRoot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pathCfg := models.PathConfig{Name: "test", Path: root, AllowExternalSymlinks: tt.allowExternal}
+			got, err := Resolve(pathCfg, tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Resolve(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Resolve(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "srv", "share")
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{target: root, want: true},
+		{target: filepath.Join(root, "a", "b"), want: true},
+		{target: filepath.Join(root, "..share-sibling"), want: true},
+		{target: filepath.Join(string(filepath.Separator), "srv"), want: false},
+		{target: filepath.Join(string(filepath.Separator), "srv", "share-other"), want: false},
+		{target: filepath.Join(string(filepath.Separator), "etc", "passwd"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsWithin(root, tt.target); got != tt.want {
+			t.Errorf("IsWithin(%q, %q) = %v, want %v", root, tt.target, got, tt.want)
+		}
+	}
+}
+
+// Test helpers
+
+func mustMkdir(t *testing.T, p string) {
+	t.Helper()
+	if err := os.MkdirAll(p, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustWrite(t *testing.T, p string) {
+	t.Helper()
+	if err := os.WriteFile(p, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustSymlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+}
@@ -0,0 +1,492 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	maxLinesPerTerm    = 8   // Line numbers kept per term & document
+	maxTermLength      = 64  // Longer runs (hashes, base64) aren't worth indexing
+	maxSnippetLength   = 240 // Characters of a line shown in results
+	contentBatchTerms  = 50000
+	binarySniffLength  = 8000
+	termPathSeparator  = "\x00"
+	contentDocsKey     = "docs"     // Relative path -> Record, to detect changes
+	contentTermsKey    = "terms"    // Term \x00 relative path -> line numbers
+	contentDocTermsKey = "docterms" // Relative path -> its terms, to remove them again
+)
+
+var contentBucket = []byte("content") // Path name -> docs, terms & docterms buckets
+
+// Extensions indexed when a path doesn't configure its own include globs
+var textExtensions = []string{
+	".txt", ".md", ".markdown", ".rst", ".adoc", ".org", ".tex", ".log", ".csv", ".tsv",
+	".json", ".yaml", ".yml", ".toml", ".xml", ".ini", ".conf", ".cfg", ".properties", ".env",
+	".html", ".htm", ".css", ".scss", ".js", ".mjs", ".ts", ".tsx", ".jsx", ".vue", ".svelte",
+	".go", ".py", ".rb", ".rs", ".java", ".kt", ".scala", ".swift", ".c", ".h", ".cc", ".cpp",
+	".hpp", ".cs", ".php", ".pl", ".lua", ".r", ".sql", ".sh", ".bash", ".zsh", ".ps1", ".bat",
+	".gradle", ".tf", ".proto", ".graphql", ".dockerfile", ".mk",
+}
+
+// ContentQuery finds text files containing every word of Text, most matching
+// lines first unless sorted otherwise
+type ContentQuery struct {
+	Text   string
+	Filter Filter
+	Sort   SortField
+	Desc   bool
+	Paths  []string
+	Limit  int
+}
+
+// ContentResult is a matching file with the lines its words were found on
+type ContentResult struct {
+	PathName string
+	Record
+	Lines []int
+}
+
+// Line is one line of a file, for showing matches in context
+type Line struct {
+	Number int    `json:"line"`
+	Text   string `json:"text"`
+}
+
+// SearchContent looks up files containing all words of the query, most matching
+// lines first
+func (ix *Index) SearchContent(ctx context.Context, q ContentQuery) (results []ContentResult, truncated bool, err error) {
+	if ix.db == nil {
+		return nil, false, ErrUnavailable
+	}
+
+	var terms []string
+	tokenize(q.Text, func(term string) {
+		if !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	})
+	if len(terms) == 0 || len(q.Text) > maxPatternLength {
+		return nil, false, ErrInvalidPattern
+	}
+
+	err = ix.db.View(func(tx *bbolt.Tx) error {
+		content := tx.Bucket(contentBucket)
+		if content == nil {
+			return nil
+		}
+
+		for _, pathName := range q.Paths {
+			bucket := content.Bucket([]byte(pathName))
+			if bucket == nil {
+				continue
+			}
+			matches, err := matchTerms(ctx, bucket.Bucket([]byte(contentTermsKey)), terms)
+			if err != nil {
+				return err
+			}
+
+			docs := bucket.Bucket([]byte(contentDocsKey))
+			for relPath, lines := range matches {
+				rec := Record{Path: relPath}
+				if v := docs.Get([]byte(relPath)); v != nil {
+					_ = json.Unmarshal(v, &rec)
+				}
+				if !q.Filter.Match(rec) {
+					continue
+				}
+				results = append(results, ContentResult{PathName: pathName, Record: rec, Lines: lines})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Most matching lines first, then in path order
+	pathOrder := map[string]int{}
+	for i, pathName := range q.Paths {
+		pathOrder[pathName] = i
+	}
+	slices.SortFunc(results, func(a, b ContentResult) int {
+		if q.Sort != "" {
+			if c := compareRecords(a.Record, b.Record, q.Sort, q.Desc); c != 0 {
+				return c
+			}
+		}
+		if len(a.Lines) != len(b.Lines) {
+			return len(b.Lines) - len(a.Lines)
+		}
+		if a.PathName != b.PathName {
+			return pathOrder[a.PathName] - pathOrder[b.PathName]
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	if len(results) > q.Limit {
+		return results[:q.Limit], true, nil
+	}
+	return results, false, nil
+}
+
+// matchTerms intersects the documents of all terms, merging their line numbers
+func matchTerms(ctx context.Context, terms *bbolt.Bucket, words []string) (map[string][]int, error) {
+	var matches map[string][]int
+	if terms == nil {
+		return matches, nil
+	}
+
+	for _, word := range words {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		found := map[string][]int{}
+		prefix := []byte(word + termPathSeparator)
+		cursor := terms.Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			relPath := string(k[len(prefix):])
+			if matches != nil && matches[relPath] == nil {
+				continue
+			}
+			var lines []int
+			if err := json.Unmarshal(v, &lines); err != nil {
+				return nil, err
+			}
+			found[relPath] = lines
+		}
+
+		if matches == nil {
+			matches = found
+			continue
+		}
+		for relPath, lines := range matches {
+			if more, ok := found[relPath]; ok {
+				merged := append(lines, more...)
+				slices.Sort(merged)
+				matches[relPath] = slices.Compact(merged)
+			} else {
+				delete(matches, relPath)
+			}
+		}
+	}
+	return matches, nil
+}
+
+// ReadLines reads the given (sorted, 1-based) lines of a text file, cut down to a
+// readable length
+func ReadLines(backend storage.Backend, name string, numbers []int) ([]Line, error) {
+	if len(numbers) == 0 {
+		return nil, nil
+	}
+
+	file, err := backend.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var lines []Line
+	reader := bufio.NewReader(file)
+	for lineNo := 1; len(numbers) > 0; lineNo++ {
+		text, err := reader.ReadString('\n')
+		if lineNo == numbers[0] {
+			lines = append(lines, Line{Number: lineNo, Text: snippet(text)})
+			numbers = numbers[1:]
+		}
+		if err != nil {
+			break
+		}
+	}
+	return lines, nil
+}
+
+func snippet(line string) string {
+	line = strings.TrimRight(line, "\r\n")
+	if utf8.RuneCountInString(line) <= maxSnippetLength {
+		return line
+	}
+	return string([]rune(line)[:maxSnippetLength]) + "…"
+}
+
+// tokenize splits text into lowercase words of letters, digits & underscores
+func tokenize(text string, emit func(term string)) {
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		if n := utf8.RuneCountInString(text[start:end]); n >= 2 && n <= maxTermLength {
+			emit(strings.ToLower(text[start:end]))
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+}
+
+// Content indexing ///////////////////////////////
+
+// contentIndexer updates the content index of one path during a walk, re-reading
+// only the files whose size or mtime changed since the last build
+type contentIndexer struct {
+	db      *bbolt.DB
+	backend storage.Backend
+	name    []byte
+	cfg     models.ContentIndexConfig
+	seen    map[string]bool
+	pending []contentDoc
+	terms   int
+}
+
+type contentDoc struct {
+	rec   Record
+	terms map[string][]int
+}
+
+// newContentIndexer prepares content indexing for a mount, or drops its content
+// index when indexing isn't enabled for it
+func (ix *Index) newContentIndexer(mount *storage.Mount) (*contentIndexer, error) {
+	name := []byte(mount.Config.Name)
+	cfg := mount.Config.ContentIndex
+
+	if !cfg.Enable {
+		return nil, ix.db.Update(func(tx *bbolt.Tx) error {
+			if content := tx.Bucket(contentBucket); content != nil && content.Bucket(name) != nil {
+				return content.DeleteBucket(name)
+			}
+			return nil
+		})
+	}
+
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = constants.DefaultContentIndexMaxFileSize
+	}
+	err := ix.db.Update(func(tx *bbolt.Tx) error {
+		content, err := tx.CreateBucketIfNotExists(contentBucket)
+		if err != nil {
+			return err
+		}
+		bucket, err := content.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		for _, key := range []string{contentDocsKey, contentTermsKey, contentDocTermsKey} {
+			if _, err := bucket.CreateBucketIfNotExists([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentIndexer{db: ix.db, backend: mount.Backend, name: name, cfg: cfg, seen: map[string]bool{}}, nil
+}
+
+// visit (re)indexes a walked file when it is selected for indexing & has changed
+func (c *contentIndexer) visit(ctx context.Context, rec Record) error {
+	if rec.IsDir || rec.Size > c.cfg.MaxFileSize || !c.selects(rec.Path) {
+		return nil
+	}
+	c.seen[rec.Path] = true
+
+	var unchanged bool
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		var prev Record
+		v := c.bucket(tx, contentDocsKey).Get([]byte(rec.Path))
+		if v == nil || json.Unmarshal(v, &prev) != nil {
+			return nil
+		}
+		unchanged = prev.Size == rec.Size && prev.ModTime.Equal(rec.ModTime)
+		return nil
+	})
+	if err != nil || unchanged {
+		return err
+	}
+
+	// Unreadable & binary files are recorded without terms, so they aren't re-read
+	// until they change
+	doc := contentDoc{rec: rec, terms: map[string][]int{}}
+	if data, err := c.read(rec.Path); err == nil && IsText(data) {
+		for lineNo, line := range strings.Split(string(data), "\n") {
+			tokenize(line, func(term string) {
+				if lines := doc.terms[term]; len(lines) < maxLinesPerTerm && !slices.Contains(lines, lineNo+1) {
+					doc.terms[term] = append(lines, lineNo+1)
+				}
+			})
+		}
+	}
+
+	c.pending = append(c.pending, doc)
+	c.terms += len(doc.terms)
+	if c.terms >= contentBatchTerms {
+		return c.flush()
+	}
+	return ctx.Err()
+}
+
+// finish writes what's pending and forgets files that no longer exist (or are no
+// longer selected)
+func (c *contentIndexer) finish() error {
+	if err := c.flush(); err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		var gone []string
+		err := c.bucket(tx, contentDocsKey).ForEach(func(k, _ []byte) error {
+			if !c.seen[string(k)] {
+				gone = append(gone, string(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, relPath := range gone {
+			if err := c.remove(tx, relPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *contentIndexer) flush() error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		terms := c.bucket(tx, contentTermsKey)
+		for _, doc := range c.pending {
+			if err := c.remove(tx, doc.rec.Path); err != nil {
+				return err
+			}
+
+			docTerms := make([]string, 0, len(doc.terms))
+			for term, lines := range doc.terms {
+				value, err := json.Marshal(lines)
+				if err != nil {
+					return err
+				}
+				if err := terms.Put([]byte(term+termPathSeparator+doc.rec.Path), value); err != nil {
+					return err
+				}
+				docTerms = append(docTerms, term)
+			}
+
+			value, err := json.Marshal(doc.rec)
+			if err != nil {
+				return err
+			}
+			if err := c.bucket(tx, contentDocsKey).Put([]byte(doc.rec.Path), value); err != nil {
+				return err
+			}
+			if err := c.bucket(tx, contentDocTermsKey).Put([]byte(doc.rec.Path), []byte(strings.Join(docTerms, "\n"))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	c.pending, c.terms = c.pending[:0], 0
+	return err
+}
+
+// remove drops a document along with all of its terms
+func (c *contentIndexer) remove(tx *bbolt.Tx, relPath string) error {
+	docTerms := c.bucket(tx, contentDocTermsKey)
+	if v := docTerms.Get([]byte(relPath)); len(v) > 0 {
+		terms := c.bucket(tx, contentTermsKey)
+		for _, term := range strings.Split(string(v), "\n") {
+			if err := terms.Delete([]byte(term + termPathSeparator + relPath)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := docTerms.Delete([]byte(relPath)); err != nil {
+		return err
+	}
+	return c.bucket(tx, contentDocsKey).Delete([]byte(relPath))
+}
+
+func (c *contentIndexer) bucket(tx *bbolt.Tx, key string) *bbolt.Bucket {
+	return tx.Bucket(contentBucket).Bucket(c.name).Bucket([]byte(key))
+}
+
+// selects applies the include & exclude globs. Globs with a slash match the whole
+// relative path, others the file name, both ignoring case.
+func (c *contentIndexer) selects(relPath string) bool {
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			subject := path.Base(relPath)
+			if strings.Contains(pattern, "/") {
+				subject = relPath
+			}
+			if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(subject)); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if matches(c.cfg.Exclude) {
+		return false
+	}
+	if len(c.cfg.Include) > 0 {
+		return matches(c.cfg.Include)
+	}
+	return slices.Contains(textExtensions, strings.ToLower(path.Ext(relPath)))
+}
+
+func (c *contentIndexer) read(relPath string) ([]byte, error) {
+	rc, err := c.backend.OpenRange(relPath, 0, c.cfg.MaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	return io.ReadAll(rc)
+}
+
+// IsText sniffs for binary content: NUL bytes or invalid UTF-8 near the start
+func IsText(data []byte) bool {
+	head := data[:min(len(data), binarySniffLength)]
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	// The sniffed window may end in the middle of a character
+	for i := 0; i < 3 && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1]
+	}
+	return utf8.Valid(head)
+}
@@ -0,0 +1,151 @@
+package search
+
+import (
+	"cmp"
+	"mime"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Category groups files by what they hold, from their extension
+type Category string
+
+const (
+	CategoryImage    Category = "image"
+	CategoryVideo    Category = "video"
+	CategoryAudio    Category = "audio"
+	CategoryDocument Category = "document"
+	CategoryArchive  Category = "archive"
+)
+
+// Extensions the MIME table gets wrong or doesn't know on every platform
+var categoryExtensions = map[string]Category{
+	".heic": CategoryImage, ".heif": CategoryImage, ".avif": CategoryImage, ".raw": CategoryImage,
+	".cr2": CategoryImage, ".nef": CategoryImage, ".arw": CategoryImage, ".dng": CategoryImage,
+	".mkv": CategoryVideo, ".m4v": CategoryVideo, ".mov": CategoryVideo, ".wmv": CategoryVideo,
+	".flac": CategoryAudio, ".m4a": CategoryAudio, ".opus": CategoryAudio, ".ogg": CategoryAudio, ".aac": CategoryAudio,
+	".pdf": CategoryDocument, ".doc": CategoryDocument, ".docx": CategoryDocument, ".odt": CategoryDocument,
+	".rtf": CategoryDocument, ".xls": CategoryDocument, ".xlsx": CategoryDocument, ".ods": CategoryDocument,
+	".ppt": CategoryDocument, ".pptx": CategoryDocument, ".odp": CategoryDocument, ".epub": CategoryDocument,
+	".txt": CategoryDocument, ".md": CategoryDocument, ".rst": CategoryDocument, ".csv": CategoryDocument,
+	".tsv": CategoryDocument, ".pages": CategoryDocument, ".numbers": CategoryDocument, ".key": CategoryDocument,
+	".zip": CategoryArchive, ".tar": CategoryArchive, ".gz": CategoryArchive, ".tgz": CategoryArchive,
+	".bz2": CategoryArchive, ".xz": CategoryArchive, ".zst": CategoryArchive, ".7z": CategoryArchive,
+	".rar": CategoryArchive, ".iso": CategoryArchive,
+}
+
+// ParseCategory maps a requested category onto a known one
+func ParseCategory(s string) (Category, bool) {
+	switch c := Category(strings.ToLower(s)); c {
+	case CategoryImage, CategoryVideo, CategoryAudio, CategoryDocument, CategoryArchive:
+		return c, true
+	}
+	return "", false
+}
+
+// CategoryOf guesses the category of a file name, empty when it fits none
+func CategoryOf(name string) Category {
+	ext := strings.ToLower(path.Ext(name))
+	if c, ok := categoryExtensions[ext]; ok {
+		return c
+	}
+
+	mediaType, _, _ := strings.Cut(mime.TypeByExtension(ext), ";")
+	switch major, _, _ := strings.Cut(mediaType, "/"); major {
+	case "image":
+		return CategoryImage
+	case "video":
+		return CategoryVideo
+	case "audio":
+		return CategoryAudio
+	}
+	return ""
+}
+
+// Filter narrows results down by their attributes. Zero values don't filter.
+type Filter struct {
+	MinSize        int64
+	MaxSize        int64
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	Extensions     []string // Lowercase, with the leading dot
+	Category       Category
+	DirsOnly       bool
+}
+
+// IsZero reports whether the filter lets everything through
+func (f Filter) IsZero() bool {
+	return f.MinSize == 0 && f.MaxSize == 0 && f.ModifiedAfter.IsZero() && f.ModifiedBefore.IsZero() &&
+		len(f.Extensions) == 0 && f.Category == "" && !f.DirsOnly
+}
+
+// Match reports whether a record passes the filter. Folders have no size, type or
+// extension, so those filters leave them out.
+func (f Filter) Match(rec Record) bool {
+	if f.DirsOnly && !rec.IsDir {
+		return false
+	}
+	if !f.ModifiedAfter.IsZero() && rec.ModTime.Before(f.ModifiedAfter) {
+		return false
+	}
+	if !f.ModifiedBefore.IsZero() && !rec.ModTime.Before(f.ModifiedBefore) {
+		return false
+	}
+	if f.MinSize == 0 && f.MaxSize == 0 && len(f.Extensions) == 0 && f.Category == "" {
+		return true
+	}
+
+	if rec.IsDir {
+		return false
+	}
+	if rec.Size < f.MinSize || (f.MaxSize > 0 && rec.Size > f.MaxSize) {
+		return false
+	}
+	if len(f.Extensions) > 0 && !slices.Contains(f.Extensions, strings.ToLower(path.Ext(rec.Path))) {
+		return false
+	}
+	return f.Category == "" || CategoryOf(rec.Path) == f.Category
+}
+
+// SortField orders results, by relative path unless told otherwise
+type SortField string
+
+const (
+	SortPath    SortField = "path"
+	SortName    SortField = "name"
+	SortSize    SortField = "size"
+	SortModTime SortField = "mtime"
+)
+
+// ParseSortField maps a requested sort field onto a supported one
+func ParseSortField(s string) (SortField, bool) {
+	switch f := SortField(strings.ToLower(s)); f {
+	case "":
+		return SortPath, true
+	case SortPath, SortName, SortSize, SortModTime:
+		return f, true
+	}
+	return "", false
+}
+
+// compareRecords orders two records by field, ties broken by path
+func compareRecords(a, b Record, field SortField, desc bool) int {
+	var c int
+	switch field {
+	case SortName:
+		c = cmp.Compare(strings.ToLower(a.Name()), strings.ToLower(b.Name()))
+	case SortSize:
+		c = cmp.Compare(a.Size, b.Size)
+	case SortModTime:
+		c = a.ModTime.Compare(b.ModTime)
+	}
+	if c == 0 {
+		c = strings.Compare(a.Path, b.Path)
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
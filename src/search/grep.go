@@ -0,0 +1,164 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// Reasons a grep stopped before covering the whole subtree
+const (
+	StopResults = "results"
+	StopFiles   = "files"
+	StopTime    = "time"
+)
+
+var errStopGrep = errors.New("grep limit reached")
+
+// Grep scans the files of a subtree line by line, without using the index
+type Grep struct {
+	Pattern     string
+	Regex       bool // Pattern is a regular expression instead of a plain substring
+	IgnoreCase  bool
+	MaxFiles    int   // Files read before giving up
+	MaxResults  int   // Matching lines reported before giving up
+	MaxFileSize int64 // Larger files are skipped
+}
+
+// GrepMatch is one matching line
+type GrepMatch struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// GrepSummary tells how far a grep got
+type GrepSummary struct {
+	Files     int    `json:"files"`
+	Matches   int    `json:"matches"`
+	Truncated bool   `json:"truncated"`
+	Reason    string `json:"reason,omitempty"` // One of the Stop reasons when truncated
+}
+
+// Run walks root in backend, calling emit for every matching line. It stops at the
+// configured limits, when ctx is done (a deadline is reported as StopTime) or when
+// emit fails.
+func (g Grep) Run(ctx context.Context, backend storage.Backend, root string, emit func(GrepMatch) error) (GrepSummary, error) {
+	var summary GrepSummary
+
+	match, err := g.compile()
+	if err != nil {
+		return summary, err
+	}
+
+	stop := func(reason string) error {
+		summary.Truncated, summary.Reason = true, reason
+		return errStopGrep
+	}
+
+	err = fs.WalkDir(backend, root, func(name string, de fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// Unreadable folders are skipped, but the subtree itself has to exist
+			if name == root {
+				return err
+			}
+			return fs.SkipDir
+		}
+		if de.IsDir() || !de.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := de.Info()
+		if err != nil || info.Size() > g.MaxFileSize {
+			return nil
+		}
+		if summary.Files >= g.MaxFiles {
+			return stop(StopFiles)
+		}
+		summary.Files++
+
+		return g.scan(ctx, backend, name, match, func(m GrepMatch) error {
+			if summary.Matches >= g.MaxResults {
+				return stop(StopResults)
+			}
+			summary.Matches++
+			return emit(m)
+		})
+	})
+
+	switch {
+	case errors.Is(err, errStopGrep):
+		return summary, nil
+	case errors.Is(err, context.DeadlineExceeded):
+		summary.Truncated, summary.Reason = true, StopTime
+		return summary, nil
+	}
+	return summary, err
+}
+
+// scan reads one file, skipping it when it turns out to be binary
+func (g Grep) scan(ctx context.Context, backend storage.Backend, name string, match func(string) bool, emit func(GrepMatch) error) error {
+	file, err := backend.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	reader := bufio.NewReader(file)
+	if head, _ := reader.Peek(binarySniffLength); !IsText(head) {
+		return nil
+	}
+
+	for lineNo := 1; ; lineNo++ {
+		if lineNo%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		line, readErr := reader.ReadString('\n')
+		if line != "" && match(line) {
+			if err := emit(GrepMatch{File: name, Line: lineNo, Text: snippet(line)}); err != nil {
+				return err
+			}
+		}
+		if readErr != nil {
+			// io.EOF, or a file failing halfway, which doesn't end the whole grep
+			return nil
+		}
+	}
+}
+
+func (g Grep) compile() (func(line string) bool, error) {
+	if g.Pattern == "" || len(g.Pattern) > maxPatternLength {
+		return nil, ErrInvalidPattern
+	}
+
+	if g.Regex {
+		pattern := g.Pattern
+		if g.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidPattern, err)
+		}
+		return func(line string) bool { return re.MatchString(strings.TrimRight(line, "\r\n")) }, nil
+	}
+
+	if g.IgnoreCase {
+		needle := strings.ToLower(g.Pattern)
+		return func(line string) bool { return strings.Contains(strings.ToLower(line), needle) }, nil
+	}
+	return func(line string) bool { return strings.Contains(line, g.Pattern) }, nil
+}
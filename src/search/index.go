@@ -0,0 +1,326 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/rs/zerolog"
+	"go.etcd.io/bbolt"
+)
+
+// Records are written in batches so a walk doesn't hold the database for long
+const buildBatchSize = 5000
+
+var (
+	namesBucket  = []byte("names")       // Path name -> relative path -> record
+	buildBucket  = []byte("names.build") // Same layout, for walks in progress
+	statusBucket = []byte("status")      // Path name -> Status
+
+	ErrUnavailable = errors.New("search index is unavailable")
+)
+
+// Index is the file name index of all configured paths, kept in the local database
+// so queries never have to walk the shares themselves
+type Index struct {
+	db *bbolt.DB
+}
+
+// Record is an indexed file or folder
+type Record struct {
+	Path    string    `json:"-"`
+	Size    int64     `json:"s"`
+	ModTime time.Time `json:"m"`
+	IsDir   bool      `json:"d,omitempty"`
+}
+
+// Status describes the last build of one configured path
+type Status struct {
+	Building bool      `json:"building"`
+	BuiltAt  time.Time `json:"builtAt"`
+	Files    int       `json:"files"`
+	Folders  int       `json:"folders"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func New(db *bbolt.DB) *Index {
+	return &Index{db: db}
+}
+
+// Run builds the index for all mounts right away and then again every interval,
+// until ctx is done. A zero interval builds once.
+func (ix *Index) Run(ctx context.Context, registry *storage.Registry, interval time.Duration, logger *zerolog.Logger) {
+	for {
+		startTime := time.Now()
+		if err := ix.Build(ctx, registry, logger); err != nil && ctx.Err() == nil {
+			logger.Error().Msg("failed to build search index: " + err.Error())
+		} else if ctx.Err() == nil {
+			logger.Info().Msgf("search index built in %s", time.Since(startTime).Round(time.Millisecond))
+		}
+
+		if interval <= 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Build walks every mount and replaces its part of the index. Paths are walked one
+// after another, queries keep seeing the previous build of a path meanwhile.
+func (ix *Index) Build(ctx context.Context, registry *storage.Registry, logger *zerolog.Logger) error {
+	if ix.db == nil {
+		return ErrUnavailable
+	}
+
+	mounts := registry.List()
+	if err := ix.prune(mounts); err != nil {
+		return err
+	}
+
+	for _, mount := range mounts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name := mount.Config.Name
+		if err := ix.buildMount(ctx, mount); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warn().Msg("failed to index path " + name + ": " + err.Error())
+			_ = ix.setStatus(name, func(s *Status) { s.Building, s.Error = false, err.Error() })
+		}
+	}
+	return nil
+}
+
+func (ix *Index) buildMount(ctx context.Context, mount *storage.Mount) error {
+	name := []byte(mount.Config.Name)
+	if err := ix.setStatus(mount.Config.Name, func(s *Status) { s.Building = true }); err != nil {
+		return err
+	}
+
+	// Start from an empty build bucket, a previous walk may have been interrupted
+	err := ix.db.Update(func(tx *bbolt.Tx) error {
+		build, err := tx.CreateBucketIfNotExists(buildBucket)
+		if err != nil {
+			return err
+		}
+		if build.Bucket(name) != nil {
+			if err := build.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		_, err = build.CreateBucket(name)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Text contents are indexed along the way for paths that opt in
+	content, err := ix.newContentIndexer(mount)
+	if err != nil {
+		return err
+	}
+
+	var (
+		batch   []Record
+		files   int
+		folders int
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := ix.db.Update(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(buildBucket).Bucket(name)
+			for _, rec := range batch {
+				value, err := json.Marshal(rec)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put([]byte(rec.Path), value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	backend := mount.Backend
+	err = fs.WalkDir(backend, ".", func(name string, de fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// Unreadable folders are left out, the rest of the tree is still indexed
+			if name == "." {
+				return err
+			}
+			return fs.SkipDir
+		}
+		if name == "." {
+			return nil
+		}
+
+		// Symlinks are indexed as what they point to, as long as the backend allows it
+		var info fs.FileInfo
+		if de.Type()&fs.ModeSymlink != 0 {
+			info, err = backend.Stat(name)
+		} else {
+			info, err = de.Info()
+		}
+		if err != nil {
+			return nil
+		}
+
+		rec := Record{Path: name, ModTime: info.ModTime(), IsDir: info.IsDir()}
+		if rec.IsDir {
+			folders++
+		} else {
+			rec.Size = info.Size()
+			files++
+		}
+		batch = append(batch, rec)
+
+		if content != nil {
+			if err := content.visit(ctx, rec); err != nil {
+				return err
+			}
+		}
+		if len(batch) >= buildBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil && content != nil {
+		err = content.finish()
+	}
+	if err != nil {
+		return err
+	}
+
+	// Swap the finished build in for the previous one
+	return ix.db.Update(func(tx *bbolt.Tx) error {
+		names, err := tx.CreateBucketIfNotExists(namesBucket)
+		if err != nil {
+			return err
+		}
+		if names.Bucket(name) != nil {
+			if err := names.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		if err := tx.MoveBucket(name, tx.Bucket(buildBucket), names); err != nil {
+			return err
+		}
+		return putStatus(tx, mount.Config.Name, Status{BuiltAt: time.Now(), Files: files, Folders: folders})
+	})
+}
+
+// prune drops the index of paths that are no longer configured or enabled
+func (ix *Index) prune(mounts []*storage.Mount) error {
+	keep := map[string]bool{}
+	for _, mount := range mounts {
+		keep[mount.Config.Name] = true
+	}
+
+	return ix.db.Update(func(tx *bbolt.Tx) error {
+		for _, bucketName := range [][]byte{namesBucket, buildBucket, statusBucket, contentBucket} {
+			bucket := tx.Bucket(bucketName)
+			if bucket == nil {
+				continue
+			}
+
+			var stale [][]byte
+			err := bucket.ForEach(func(k, _ []byte) error {
+				if !keep[string(k)] {
+					stale = append(stale, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range stale {
+				if bucket.Bucket(k) != nil {
+					err = bucket.DeleteBucket(k)
+				} else {
+					err = bucket.Delete(k)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status returns the build status of every indexed path
+func (ix *Index) Status() (map[string]Status, error) {
+	statuses := map[string]Status{}
+	if ix.db == nil {
+		return statuses, ErrUnavailable
+	}
+
+	err := ix.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(statusBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var s Status
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			statuses[string(k)] = s
+			return nil
+		})
+	})
+	return statuses, err
+}
+
+func (ix *Index) setStatus(pathName string, update func(s *Status)) error {
+	return ix.db.Update(func(tx *bbolt.Tx) error {
+		var s Status
+		if bucket := tx.Bucket(statusBucket); bucket != nil {
+			if v := bucket.Get([]byte(pathName)); v != nil {
+				_ = json.Unmarshal(v, &s)
+			}
+		}
+		update(&s)
+		return putStatus(tx, pathName, s)
+	})
+}
+
+func putStatus(tx *bbolt.Tx, pathName string, s Status) error {
+	bucket, err := tx.CreateBucketIfNotExists(statusBucket)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(pathName), value)
+}
+
+// Name returns the base name of an indexed path
+func (r Record) Name() string {
+	return path.Base(r.Path)
+}
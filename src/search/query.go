@@ -0,0 +1,178 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// Longest accepted pattern, regexes are compiled per query
+const maxPatternLength = 256
+
+var ErrInvalidPattern = errors.New("invalid search pattern")
+
+// Mode selects how a query's text is matched against names
+type Mode string
+
+const (
+	ModeSubstring Mode = "substring"
+	ModeGlob      Mode = "glob"
+	ModeRegex     Mode = "regex"
+)
+
+// ParseMode maps a requested mode onto a supported one, substring by default
+func ParseMode(s string) (Mode, bool) {
+	switch Mode(strings.ToLower(s)) {
+	case "", ModeSubstring:
+		return ModeSubstring, true
+	case ModeGlob:
+		return ModeGlob, true
+	case ModeRegex:
+		return ModeRegex, true
+	}
+	return "", false
+}
+
+// Query finds names across the given paths. Substring & glob matching ignore case,
+// patterns containing a slash match the whole relative path instead of the name.
+// Text may be left empty when a filter is given.
+type Query struct {
+	Text   string
+	Mode   Mode
+	Filter Filter
+	Sort   SortField // Path order (per path, in Paths order) when empty
+	Desc   bool
+	Paths  []string // Path names to search, in result order
+	Limit  int
+}
+
+// Result is a match along with the configured path it was found in
+type Result struct {
+	PathName string
+	Record
+}
+
+// Search scans the index for matches. truncated reports whether more than
+// q.Limit results exist.
+func (ix *Index) Search(ctx context.Context, q Query) (results []Result, truncated bool, err error) {
+	if ix.db == nil {
+		return nil, false, ErrUnavailable
+	}
+
+	match := func(string) bool { return true }
+	if q.Text != "" || q.Filter.IsZero() {
+		if match, err = compile(q.Text, q.Mode); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Sorted queries have to see every match, only the best ones are kept around
+	sorted := (q.Sort != "" && q.Sort != SortPath) || q.Desc
+	keep := func() {
+		slices.SortFunc(results, func(a, b Result) int { return compareResults(a, b, q) })
+		results = results[:min(len(results), q.Limit+1)]
+	}
+
+	err = ix.db.View(func(tx *bbolt.Tx) error {
+		names := tx.Bucket(namesBucket)
+		if names == nil {
+			return nil
+		}
+
+		for _, pathName := range q.Paths {
+			bucket := names.Bucket([]byte(pathName))
+			if bucket == nil {
+				continue
+			}
+
+			cursor := bucket.Cursor()
+			scanned := 0
+			for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+				if scanned++; scanned%10000 == 0 {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+				}
+				if !match(string(k)) {
+					continue
+				}
+
+				rec := Record{Path: string(k)}
+				if err := json.Unmarshal(v, &rec); err != nil {
+					return err
+				}
+				if !q.Filter.Match(rec) {
+					continue
+				}
+				// Only a further result that passes the filter means there are more
+				if !sorted && len(results) >= q.Limit {
+					truncated = true
+					return nil
+				}
+				results = append(results, Result{PathName: pathName, Record: rec})
+				if sorted && len(results) > 2*q.Limit {
+					keep()
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil || !sorted {
+		return results, truncated, err
+	}
+
+	keep()
+	if len(results) > q.Limit {
+		return results[:q.Limit], true, nil
+	}
+	return results, false, nil
+}
+
+// compareResults orders results of a sorted query, the same relative path in
+// several paths in the order of the paths
+func compareResults(a, b Result, q Query) int {
+	if c := compareRecords(a.Record, b.Record, q.Sort, q.Desc); c != 0 {
+		return c
+	}
+	return slices.Index(q.Paths, a.PathName) - slices.Index(q.Paths, b.PathName)
+}
+
+// compile turns query text into a matcher over relative paths
+func compile(text string, mode Mode) (func(relPath string) bool, error) {
+	if text == "" || len(text) > maxPatternLength {
+		return nil, ErrInvalidPattern
+	}
+
+	// Patterns with a slash are meant for the whole path, others for the name only
+	subject := path.Base
+	if strings.Contains(text, "/") {
+		subject = func(relPath string) string { return relPath }
+	}
+
+	switch mode {
+	case ModeGlob:
+		pattern := strings.ToLower(text)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, ErrInvalidPattern
+		}
+		return func(relPath string) bool {
+			ok, _ := path.Match(pattern, strings.ToLower(subject(relPath)))
+			return ok
+		}, nil
+	case ModeRegex:
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidPattern, err)
+		}
+		return func(relPath string) bool { return re.MatchString(subject(relPath)) }, nil
+	default:
+		needle := strings.ToLower(text)
+		return func(relPath string) bool { return strings.Contains(strings.ToLower(subject(relPath)), needle) }, nil
+	}
+}
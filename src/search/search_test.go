@@ -0,0 +1,331 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/rs/zerolog"
+	"go.etcd.io/bbolt"
+)
+
+func TestSearch(t *testing.T) {
+	ix := newTestIndex(t)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{name: "substring ignores case", query: Query{Text: "REPORT", Mode: ModeSubstring}, want: []string{"docs/Report-2024.pdf", "logs/report.log"}},
+		{name: "glob", query: Query{Text: "*.log", Mode: ModeGlob}, want: []string{"logs/app.log", "logs/report.log"}},
+		{name: "glob with slash matches paths", query: Query{Text: "logs/*", Mode: ModeGlob}, want: []string{"logs/app.log", "logs/report.log"}},
+		{name: "regex", query: Query{Text: `^[a-z]+\.log$`, Mode: ModeRegex}, want: []string{"logs/app.log", "logs/report.log"}},
+		{name: "folders", query: Query{Text: "docs", Mode: ModeSubstring}, want: []string{"docs"}},
+		{name: "single path", query: Query{Text: "notes", Mode: ModeSubstring, Paths: []string{"other"}}, want: []string{"notes.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			if q.Paths == nil {
+				q.Paths = []string{"share", "other"}
+			}
+			q.Limit = 10
+
+			results, truncated, err := ix.Search(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.Path)
+			}
+			if !slices.Equal(got, tt.want) || truncated {
+				t.Fatalf("Search(%+v) = %v (truncated %v), want %v", tt.query, got, truncated, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	ix := newTestIndex(t)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{name: "extension without text", query: Query{Filter: Filter{Extensions: []string{".log"}}}, want: []string{"logs/app.log", "logs/report.log"}},
+		{name: "size", query: Query{Text: "o", Filter: Filter{MinSize: 4}}, want: []string{"docs/todo.md", "logs/report.log", "notes.txt"}},
+		{name: "category", query: Query{Filter: Filter{Category: CategoryDocument}}, want: []string{"docs/Report-2024.pdf", "docs/todo.md", "notes.txt"}},
+		{name: "folders only", query: Query{Filter: Filter{DirsOnly: true}}, want: []string{"docs", "logs"}},
+		{name: "modified after", query: Query{Filter: Filter{ModifiedAfter: time.Now()}}, want: nil},
+		{name: "sorted by size", query: Query{Text: "o", Filter: Filter{MinSize: 1}, Sort: SortSize, Desc: true}, want: []string{"logs/report.log", "notes.txt", "docs/todo.md", "logs/app.log", "docs/Report-2024.pdf"}},
+		{name: "sorted by name", query: Query{Text: ".", Sort: SortName}, want: []string{"logs/app.log", "notes.txt", "docs/Report-2024.pdf", "logs/report.log", "docs/todo.md"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			q.Paths, q.Limit = []string{"share", "other"}, 10
+
+			results, _, err := ix.Search(context.Background(), q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.Path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Search(%+v) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// Sorted queries still only return the best matches
+	results, truncated, err := ix.Search(context.Background(), Query{Text: "o", Filter: Filter{MinSize: 1}, Sort: SortSize, Paths: []string{"share"}, Limit: 1})
+	if err != nil || len(results) != 1 || results[0].Path != "docs/Report-2024.pdf" || !truncated {
+		t.Fatalf("Search(smallest) = %+v (truncated %v), %v", results, truncated, err)
+	}
+}
+
+func TestSearchLimits(t *testing.T) {
+	ix := newTestIndex(t)
+
+	results, truncated, err := ix.Search(context.Background(), Query{Text: "o", Mode: ModeSubstring, Paths: []string{"share"}, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !truncated {
+		t.Fatalf("got %d results (truncated %v), want 2 & truncated", len(results), truncated)
+	}
+
+	// Records after the last result that the filter rejects don't count as more
+	results, truncated, err = ix.Search(context.Background(), Query{Filter: Filter{Extensions: []string{".pdf"}}, Paths: []string{"share"}, Limit: 1})
+	if err != nil || len(results) != 1 || truncated {
+		t.Fatalf("Search(pdf) = %+v (truncated %v), %v, want 1 result & not truncated", results, truncated, err)
+	}
+
+	for _, q := range []Query{
+		{Text: "", Mode: ModeSubstring},
+		{Text: "[", Mode: ModeGlob},
+		{Text: "(", Mode: ModeRegex},
+	} {
+		q.Paths, q.Limit = []string{"share"}, 10
+		if _, _, err := ix.Search(context.Background(), q); !errors.Is(err, ErrInvalidPattern) {
+			t.Fatalf("Search(%q, %s) error = %v, want ErrInvalidPattern", q.Text, q.Mode, err)
+		}
+	}
+}
+
+func TestBuildPrunesRemovedPaths(t *testing.T) {
+	ix := newTestIndex(t)
+	logger := zerolog.Nop()
+
+	// Rebuild with only one of the two paths still configured
+	registry := storage.NewRegistryFrom(&storage.Mount{
+		Config:  models.PathConfig{Name: "other"},
+		Backend: storage.FromFS(fstest.MapFS{"notes.txt": {Data: []byte("notes")}}),
+	})
+	if err := ix.Build(context.Background(), registry, &logger); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := ix.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := statuses["share"]; ok || statuses["other"].Files != 1 {
+		t.Fatalf("statuses = %+v, want only other with 1 file", statuses)
+	}
+
+	results, _, err := ix.Search(context.Background(), Query{Text: "log", Mode: ModeSubstring, Paths: []string{"share"}, Limit: 10})
+	if err != nil || len(results) != 0 {
+		t.Fatalf("Search(removed path) = %v, %v, want no results", results, err)
+	}
+}
+
+func TestSearchContent(t *testing.T) {
+	ix, files := newContentTestIndex(t)
+
+	search := func(text string) []ContentResult {
+		t.Helper()
+		results, _, err := ix.SearchContent(context.Background(), ContentQuery{Text: text, Paths: []string{"docs"}, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	results := search("Deploy STAGING")
+	if len(results) != 1 || results[0].Path != "guide.md" || !slices.Equal(results[0].Lines, []int{2, 3}) {
+		t.Fatalf("SearchContent(deploy staging) = %+v, want guide.md lines 2 & 3", results)
+	}
+	for _, text := range []string{"binary", "excluded", "huge"} {
+		if results := search(text); len(results) != 0 {
+			t.Fatalf("SearchContent(%q) = %+v, want no results", text, results)
+		}
+	}
+
+	lines, err := ReadLines(storage.FromFS(files), "guide.md", results[0].Lines)
+	if err != nil || len(lines) != 2 || lines[0].Text != "Deploy with make deploy." {
+		t.Fatalf("ReadLines() = %+v, %v", lines, err)
+	}
+
+	if _, _, err := ix.SearchContent(context.Background(), ContentQuery{Text: "- !", Paths: []string{"docs"}, Limit: 10}); !errors.Is(err, ErrInvalidPattern) {
+		t.Fatalf("SearchContent(no words) error = %v, want ErrInvalidPattern", err)
+	}
+}
+
+func TestSearchContentUpdates(t *testing.T) {
+	ix, files := newContentTestIndex(t)
+	registry := contentTestRegistry(files)
+	logger := zerolog.Nop()
+
+	// Changed files are re-read, removed ones forgotten
+	files["guide.md"] = &fstest.MapFile{Data: []byte("Rewritten from scratch"), ModTime: time.Now()}
+	delete(files, "notes.txt")
+	if err := ix.Build(context.Background(), registry, &logger); err != nil {
+		t.Fatal(err)
+	}
+
+	for text, want := range map[string]int{"staging": 0, "rewritten": 1, "groceries": 0} {
+		results, _, err := ix.SearchContent(context.Background(), ContentQuery{Text: text, Paths: []string{"docs"}, Limit: 10})
+		if err != nil || len(results) != want {
+			t.Fatalf("SearchContent(%q) = %+v, %v, want %d results", text, results, err, want)
+		}
+	}
+}
+
+func TestGrep(t *testing.T) {
+	backend := storage.FromFS(fstest.MapFS{
+		"a.log":     {Data: []byte("ok\nERROR one\nok\nerror two\n")},
+		"b.log":     {Data: []byte("error three")},
+		"bin.dat":   {Data: []byte("error\x00")},
+		"sub/c.log": {Data: []byte("Error four")},
+	})
+	grep := Grep{Pattern: "error", IgnoreCase: true, MaxFiles: 10, MaxResults: 10, MaxFileSize: 1 << 10}
+
+	collect := func(g Grep, root string) ([]GrepMatch, GrepSummary) {
+		t.Helper()
+		var matches []GrepMatch
+		summary, err := g.Run(context.Background(), backend, root, func(m GrepMatch) error {
+			matches = append(matches, m)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return matches, summary
+	}
+
+	matches, summary := collect(grep, ".")
+	if len(matches) != 4 || matches[1] != (GrepMatch{File: "a.log", Line: 4, Text: "error two"}) || summary.Truncated {
+		t.Fatalf("Run() = %+v, %+v, want 4 matches from text files", matches, summary)
+	}
+
+	if matches, _ := collect(Grep{Pattern: `^E\w+`, Regex: true, MaxFiles: 10, MaxResults: 10, MaxFileSize: 1 << 10}, "sub"); len(matches) != 1 {
+		t.Fatalf("Run(regex, sub) = %+v, want 1 match", matches)
+	}
+
+	limited := grep
+	limited.MaxResults = 2
+	if matches, summary := collect(limited, "."); len(matches) != 2 || summary.Reason != StopResults {
+		t.Fatalf("Run(2 results) = %+v, %+v, want 2 matches & stopped on results", matches, summary)
+	}
+	limited = grep
+	limited.MaxFiles = 1
+	if _, summary := collect(limited, "."); summary.Files != 1 || summary.Reason != StopFiles {
+		t.Fatalf("Run(1 file) summary = %+v, want stopped on files", summary)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	if summary, err := grep.Run(ctx, backend, ".", func(GrepMatch) error { return nil }); err != nil || summary.Reason != StopTime {
+		t.Fatalf("Run(expired) = %+v, %v, want stopped on time", summary, err)
+	}
+}
+
+// Test helpers
+
+func newTestIndex(t *testing.T) *Index {
+	t.Helper()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	registry := storage.NewRegistryFrom(
+		&storage.Mount{
+			Config: models.PathConfig{Name: "share"},
+			Backend: storage.FromFS(fstest.MapFS{
+				"docs/Report-2024.pdf": {Data: []byte("pdf")},
+				"docs/todo.md":         {Data: []byte("todo")},
+				"logs/app.log":         {Data: []byte("app")},
+				"logs/report.log":      {Data: []byte("report")},
+			}),
+		},
+		&storage.Mount{
+			Config:  models.PathConfig{Name: "other"},
+			Backend: storage.FromFS(fstest.MapFS{"notes.txt": {Data: []byte("notes")}}),
+		},
+	)
+
+	ix := New(db)
+	logger := zerolog.Nop()
+	if err := ix.Build(context.Background(), registry, &logger); err != nil {
+		t.Fatal(err)
+	}
+	return ix
+}
+
+func newContentTestIndex(t *testing.T) (*Index, fstest.MapFS) {
+	t.Helper()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	files := fstest.MapFS{
+		"guide.md":           {Data: []byte("# Guide\nDeploy with make deploy.\nStaging deploys run nightly.\n")},
+		"notes.txt":          {Data: []byte("groceries")},
+		"image.txt":          {Data: []byte("binary\x00data")},
+		"vendor/excluded.md": {Data: []byte("excluded")},
+		"huge.log":           {Data: []byte(strings.Repeat("huge ", 100))},
+	}
+
+	ix := New(db)
+	logger := zerolog.Nop()
+	if err := ix.Build(context.Background(), contentTestRegistry(files), &logger); err != nil {
+		t.Fatal(err)
+	}
+	return ix, files
+}
+
+func contentTestRegistry(files fstest.MapFS) *storage.Registry {
+	return storage.NewRegistryFrom(&storage.Mount{
+		Config: models.PathConfig{Name: "docs", ContentIndex: models.ContentIndexConfig{
+			Enable:      true,
+			MaxFileSize: 256,
+			Exclude:     []string{"vendor/*"},
+		}},
+		Backend: storage.FromFS(files),
+	})
+}
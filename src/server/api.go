@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/patppuccin/viewr/src/archive"
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/resolver"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiPath struct {
+	Name string `json:"name"`
+}
+
+type apiBatchRequest struct {
+	Paths  []string `json:"paths"`
+	Format string   `json:"format"`
+}
+
+type apiListing struct {
+	Name    string             `json:"name"`
+	Path    string             `json:"path"`
+	Entries []models.FileEntry `json:"entries"`
+}
+
+func handleAPIPaths(w http.ResponseWriter, r *http.Request) {
+	paths := []apiPath{}
+	for _, mount := range getStorage(r).List() {
+		paths = append(paths, apiPath{Name: mount.Config.Name})
+	}
+
+	writeJSON(w, http.StatusOK, paths)
+}
+
+func handleAPIList(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveAPIRoute(r)
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+
+	entries, err := listDir(mount, relPath)
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiListing{
+		Name:    mount.Config.Name,
+		Path:    relPath,
+		Entries: entries,
+	})
+}
+
+func handleAPIBatch(w http.ResponseWriter, r *http.Request) {
+	pathName, err := routeParam(r, "name")
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+
+	var req apiBatchRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	format, ok := archive.ParseFormat(req.Format)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "unsupported archive format")
+		return
+	}
+
+	mount, entries, err := collectBatch(r, pathName, req.Paths)
+	switch {
+	case errors.Is(err, errEmptySelection):
+		writeJSONError(w, http.StatusBadRequest, "no paths selected")
+		return
+	case errors.Is(err, archive.ErrLimitExceeded):
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "selection exceeds the archive limits")
+		return
+	case err != nil:
+		writePathError(w, r, err)
+		return
+	}
+
+	streamArchive(w, r, mount, format, mount.Config.Name+"-selection", "", entries, true)
+}
+
+// API helpers
+
+// resolveAPIRoute resolves the "name" route param & the "path" query param
+func resolveAPIRoute(r *http.Request) (*storage.Mount, string, error) {
+	pathName, err := routeParam(r, "name")
+	if err != nil {
+		return nil, "", err
+	}
+	return resolvePath(r, pathName, r.URL.Query().Get("path"))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: message})
+}
+
+func writePathError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, resolver.ErrInvalidPath):
+		writeJSONError(w, http.StatusBadRequest, "invalid path")
+	case errors.Is(err, resolver.ErrOutsideRoot):
+		writeJSONError(w, http.StatusForbidden, "path is outside of the configured root")
+	case errors.Is(err, errPathNotFound), errors.Is(err, fs.ErrNotExist):
+		writeJSONError(w, http.StatusNotFound, "path not found")
+	case errors.Is(err, fs.ErrInvalid):
+		writeJSONError(w, http.StatusBadRequest, "invalid path")
+	case errors.Is(err, errNotDir):
+		writeJSONError(w, http.StatusBadRequest, "path is not a directory")
+	default:
+		getServerCtx(r).Logger.Error().Msg("failed to read " + r.URL.Path + ": " + err.Error())
+		writeJSONError(w, http.StatusInternalServerError, "failed to read path")
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"html/template"
+	"net/http"
+	"path"
+	"slices"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/search"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/patppuccin/viewr/src/tree"
+)
+
+// Ways to view a data file, the tree first
+const (
+	dataViewTree   = "tree"
+	dataViewPretty = "pretty"
+	dataViewRaw    = "raw"
+)
+
+type dataPage struct {
+	PathName  string
+	Path      string
+	Name      string
+	Size      int64
+	Format    string
+	View      string
+	Views     []string
+	Root      *tree.Node
+	Truncated bool   // The tree stops at the size, node or depth limits
+	Invalid   string // Why the file couldn't be parsed
+	Code      template.HTML
+	TooLarge  bool // Over MaxSize
+	Binary    bool
+	MaxSize   int64
+	MaxNodes  int
+	MaxDepth  int
+}
+
+// handleData shows a JSON, YAML, TOML or XML file as a collapsible tree of its
+// values, pretty-printed or as is, going by the "view" param. Large files are
+// parsed up to a limit for the tree.
+func handleData(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	pathCfg := mount.Config
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		http.Redirect(w, r, browseURL(pathCfg.Name, relPath), http.StatusFound)
+		return
+	}
+	format, ok := tree.Format(relPath)
+	if !ok {
+		http.Redirect(w, r, previewURL(pathCfg.Name, relPath, 0), http.StatusFound)
+		return
+	}
+
+	page := dataPage{
+		PathName: pathCfg.Name,
+		Path:     relPath,
+		Name:     path.Base(relPath),
+		Size:     info.Size(),
+		Format:   format,
+		View:     dataViewTree,
+		Views:    dataViews(format),
+	}
+	if view := r.URL.Query().Get("view"); view != "" {
+		if !slices.Contains(page.Views, view) {
+			renderError(w, r, http.StatusBadRequest, "The view is invalid.")
+			return
+		}
+		page.View = view
+	}
+
+	if page.View == dataViewTree {
+		err = readTree(mount, relPath, &page)
+	} else {
+		err = readDataText(r, mount, relPath, &page)
+	}
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	renderPage(w, r, http.StatusOK, "data.html", pageData{
+		Title:  pathCrumbTitle(pathCfg.Name, relPath),
+		Crumbs: pathCrumbs(pathCfg.Name, relPath),
+		Data:   page,
+	})
+}
+
+// Data helpers
+
+func isData(name string) bool {
+	_, ok := tree.Format(name)
+	return ok
+}
+
+// dataViews lists the views of a format, TOML having no pretty-printed one
+func dataViews(format string) []string {
+	if format == tree.FormatTOML {
+		return []string{dataViewTree, dataViewRaw}
+	}
+	return []string{dataViewTree, dataViewPretty, dataViewRaw}
+}
+
+// readTree parses a file into the page's tree as it's read, up to the limits
+func readTree(mount *storage.Mount, relPath string, page *dataPage) error {
+	rc, err := mount.Backend.OpenRange(relPath, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	br := bufio.NewReader(rc)
+	if head, _ := br.Peek(br.Size()); !search.IsText(head) {
+		page.Binary = true
+		return nil
+	}
+
+	limits := tree.Limits{
+		MaxBytes: constants.DataTreeMaxSize,
+		MaxNodes: constants.DataTreeMaxNodes,
+		MaxDepth: constants.DataTreeMaxDepth,
+	}
+	page.MaxSize, page.MaxNodes, page.MaxDepth = limits.MaxBytes, limits.MaxNodes, limits.MaxDepth
+	page.Root, page.Truncated, err = tree.Parse(page.Format, br, limits)
+	switch {
+	case errors.Is(err, tree.ErrTooLarge):
+		page.TooLarge = true
+	case err != nil:
+		page.Invalid = err.Error()
+	}
+	return nil
+}
+
+// readDataText highlights a file as is or pretty-printed, falling back to as is
+// when it can't be parsed
+func readDataText(r *http.Request, mount *storage.Mount, relPath string, page *dataPage) error {
+	page.MaxSize = getServerCtx(r).Config.Preview.MaxSize
+	if page.Size > page.MaxSize {
+		page.TooLarge = true
+		return nil
+	}
+	data, err := readText(mount.Backend, relPath, page.MaxSize+1)
+	if err != nil {
+		return err
+	}
+	// Files may have grown since they were stat'ed
+	if page.TooLarge = int64(len(data)) > page.MaxSize; page.TooLarge {
+		return nil
+	}
+	if page.Binary = !search.IsText(data); page.Binary {
+		return nil
+	}
+
+	if page.View == dataViewPretty {
+		pretty, err := tree.Pretty(page.Format, data)
+		if err != nil {
+			page.Invalid = err.Error()
+		} else {
+			data = pretty
+		}
+	}
+	page.Code, _, err = highlight(relPath, data)
+	return err
+}
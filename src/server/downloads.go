@@ -0,0 +1,134 @@
+package server
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/patppuccin/viewr/src/archive"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// handleArchive streams a folder as a ZIP or tar.gz archive, built on the fly
+func handleArchive(w http.ResponseWriter, r *http.Request) {
+	serverCtx := getServerCtx(r)
+
+	format, ok := archive.ParseFormat(r.URL.Query().Get("format"))
+	if !ok {
+		renderError(w, r, http.StatusBadRequest, "The requested archive format is not supported.")
+		return
+	}
+
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if info, err := mount.Backend.Stat(storage.Name(relPath)); err != nil || !info.IsDir() {
+		renderPathError(w, r, errNotDir)
+		return
+	}
+
+	// Collect up front so limit violations can still be reported with a status code
+	limits := archive.Limits{
+		MaxBytes: serverCtx.Config.Archive.MaxBytes,
+		MaxFiles: serverCtx.Config.Archive.MaxFiles,
+	}
+	entries, err := archive.Collect(r.Context(), mount.Backend, []string{relPath}, limits)
+	if errors.Is(err, archive.ErrLimitExceeded) {
+		renderError(w, r, http.StatusRequestEntityTooLarge, "The folder is too large to download as an archive.")
+		return
+	}
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	archiveName := mount.Config.Name
+	if relPath != "" {
+		archiveName = path.Base(relPath)
+	}
+	streamArchive(w, r, mount, format, archiveName, parentPath(relPath), entries, false)
+}
+
+// handleBatch streams a selection of files & folders from one configured path as
+// a single archive with a checksum manifest. The selection is posted as a form
+// with repeated "paths" fields and an optional "format".
+func handleBatch(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderError(w, r, http.StatusBadRequest, "The submitted selection could not be read.")
+		return
+	}
+
+	pathName, err := routeParam(r, "pathName")
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	format, ok := archive.ParseFormat(r.PostForm.Get("format"))
+	if !ok {
+		renderError(w, r, http.StatusBadRequest, "The requested archive format is not supported.")
+		return
+	}
+
+	mount, entries, err := collectBatch(r, pathName, r.PostForm["paths"])
+	switch {
+	case errors.Is(err, errEmptySelection):
+		renderError(w, r, http.StatusBadRequest, "Select at least one file or folder to download.")
+		return
+	case errors.Is(err, archive.ErrLimitExceeded):
+		renderError(w, r, http.StatusRequestEntityTooLarge, "The selection is too large to download as an archive.")
+		return
+	case err != nil:
+		renderPathError(w, r, err)
+		return
+	}
+
+	streamArchive(w, r, mount, format, mount.Config.Name+"-selection", "", entries, true)
+}
+
+// Download helpers
+
+var errEmptySelection = errors.New("no paths selected")
+
+func collectBatch(r *http.Request, pathName string, relPaths []string) (*storage.Mount, []archive.Entry, error) {
+	serverCtx := getServerCtx(r)
+
+	if len(relPaths) == 0 {
+		return nil, nil, errEmptySelection
+	}
+
+	mount, ok := getStorage(r).Get(pathName)
+	if !ok {
+		return nil, nil, errPathNotFound
+	}
+
+	limits := archive.Limits{
+		MaxBytes: serverCtx.Config.Archive.MaxBytes,
+		MaxFiles: serverCtx.Config.Archive.MaxFiles,
+	}
+	entries, err := archive.Collect(r.Context(), mount.Backend, relPaths, limits)
+	return mount, entries, err
+}
+
+func streamArchive(w http.ResponseWriter, r *http.Request, mount *storage.Mount, format archive.Format, name, prefix string, entries []archive.Entry, withManifest bool) {
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + format.Extension()}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	write := archive.Write
+	if withManifest {
+		write = archive.WriteWithManifest
+	}
+	if err := write(r.Context(), w, mount.Backend, format, prefix, entries); err != nil {
+		if r.Context().Err() == nil {
+			getServerCtx(r).Logger.Error().Msg("failed to stream archive " + name + ": " + err.Error())
+		}
+
+		// Headers are gone already, abort the connection so clients see a truncated download
+		panic(http.ErrAbortHandler)
+	}
+}
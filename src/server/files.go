@@ -0,0 +1,51 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/patppuccin/viewr/src/helpers"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// handleRaw streams a single file. http.ServeContent takes care of HEAD, Range
+// (including multi-range 206 responses) and the If-* conditional headers.
+func handleRaw(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	file, info, err := storage.OpenReadSeeker(mount.Backend, storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if info.IsDir() {
+		http.Redirect(w, r, browseURL(mount.Config.Name, relPath), http.StatusFound)
+		return
+	}
+
+	// Symlinked files report the target's name, so name the download after the link
+	fileName := path.Base(relPath)
+
+	// Validators must be set before ServeContent evaluates the conditional headers
+	w.Header().Set("ETag", helpers.FileETag(info.Size(), info.ModTime()))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Shared files are untrusted content, never let them script against this origin
+	w.Header().Set("Content-Security-Policy", "sandbox")
+
+	if _, ok := r.URL.Query()["download"]; ok {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	}
+
+	http.ServeContent(w, r, fileName, info.ModTime(), file)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// Extensions of the images browsers show on their own
+var galleryExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif", ".bmp", ".svg"}
+
+// Slideshow intervals offered besides the configured one, in seconds
+var slideshowIntervals = []int{3, 5, 10, 30}
+
+type galleryPage struct {
+	PathName  string
+	Path      string
+	Images    []models.FileEntry
+	Thumbs    bool
+	Interval  int   // Seconds between slides
+	Intervals []int // Seconds, to pick from
+}
+
+// handleGallery shows the images of a folder as a grid opening into a lightbox,
+// with a slideshow & the EXIF data of each image
+func handleGallery(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	pathCfg := mount.Config
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if !info.IsDir() && !storage.IsArchive(relPath) {
+		http.Redirect(w, r, browseURL(pathCfg.Name, relPath), http.StatusFound)
+		return
+	}
+	entries, err := readListing(mount.Backend, relPath)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	var images []models.FileEntry
+	for _, entry := range entries {
+		if !entry.IsDir && isGalleryImage(entry.Name) {
+			images = append(images, entry)
+		}
+	}
+
+	interval := slideshowInterval(r, getServerCtx(r).Config.Gallery.SlideshowInterval)
+	intervals := slideshowIntervals
+	if !slices.Contains(intervals, interval) {
+		intervals = append(slices.Clone(intervals), interval)
+		slices.Sort(intervals)
+	}
+
+	renderPage(w, r, http.StatusOK, "gallery.html", pageData{
+		Title:  pathCrumbTitle(pathCfg.Name, relPath),
+		Crumbs: pathCrumbs(pathCfg.Name, relPath),
+		Data: galleryPage{
+			PathName:  pathCfg.Name,
+			Path:      relPath,
+			Images:    images,
+			Thumbs:    getThumbs(r) != nil,
+			Interval:  interval,
+			Intervals: intervals,
+		},
+	})
+}
+
+// Gallery helpers
+
+func isGalleryImage(name string) bool {
+	return slices.Contains(galleryExtensions, strings.ToLower(path.Ext(name)))
+}
+
+// mostlyImages reports whether enough of a folder's files are images for its
+// gallery to be offered
+func mostlyImages(entries []models.FileEntry) bool {
+	var files, images int
+	for _, entry := range entries {
+		if entry.IsDir || entry.IsArchive {
+			continue
+		}
+		files++
+		if isGalleryImage(entry.Name) {
+			images++
+		}
+	}
+	return images > 0 && float64(images) >= float64(files)*constants.GalleryImageRatio
+}
+
+// slideshowInterval reads the "interval" param in seconds, falling back to the
+// configured interval
+func slideshowInterval(r *http.Request, configured time.Duration) int {
+	if secs, err := strconv.Atoi(r.URL.Query().Get("interval")); err == nil && secs > 0 && secs <= 3600 {
+		return secs
+	}
+	return max(1, int(configured.Round(time.Second)/time.Second))
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/search"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// grepDone is the last record of a grep stream
+type grepDone struct {
+	Done bool `json:"done"`
+	search.GrepSummary
+}
+
+// handleAPIGrep walks a subtree on demand & streams matching lines as they're
+// found, as newline-delimited JSON or as server-sent events
+func handleAPIGrep(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveAPIRoute(r)
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+	if _, err := mount.Backend.Stat(storage.Name(relPath)); err != nil {
+		writePathError(w, r, err)
+		return
+	}
+
+	params := r.URL.Query()
+	grep := search.Grep{
+		Pattern:     params.Get("pattern"),
+		Regex:       isTruthy(params.Get("regex")),
+		IgnoreCase:  isTruthy(params.Get("ignoreCase")),
+		MaxFiles:    constants.GrepMaxFiles,
+		MaxResults:  constants.DefaultGrepLimit,
+		MaxFileSize: constants.GrepMaxFileSize,
+	}
+	if val := params.Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		grep.MaxResults = min(n, constants.MaxGrepLimit)
+	}
+
+	sse := params.Get("format") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	stream := &grepStream{w: w, rc: http.NewResponseController(w), sse: sse}
+
+	ctx, cancel := context.WithTimeout(r.Context(), constants.GrepTimeout)
+	defer cancel()
+
+	summary, err := grep.Run(ctx, mount.Backend, storage.Name(relPath), func(m search.GrepMatch) error {
+		return stream.send("match", m)
+	})
+	switch {
+	case errors.Is(err, search.ErrInvalidPattern) && !stream.started:
+		writeJSONError(w, http.StatusBadRequest, "invalid search pattern")
+		return
+	case r.Context().Err() != nil:
+		// The client went away, there's nobody left to tell
+		return
+	case err != nil && !stream.started:
+		writePathError(w, r, err)
+		return
+	case err != nil:
+		getServerCtx(r).Logger.Error().Msg("grep failed: " + err.Error())
+		return
+	}
+
+	_ = stream.send("done", grepDone{Done: true, GrepSummary: summary})
+}
+
+// Grep helpers
+
+// grepStream writes records as they come, starting the response on the first one
+type grepStream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	sse     bool
+	started bool
+}
+
+func (s *grepStream) send(event string, v any) error {
+	if !s.started {
+		header := s.w.Header()
+		if s.sse {
+			header.Set("Content-Type", "text/event-stream")
+		} else {
+			header.Set("Content-Type", "application/x-ndjson")
+		}
+		header.Set("Cache-Control", "no-cache")
+		header.Set("X-Accel-Buffering", "no") // Keep reverse proxies from holding it back
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.sse {
+		_, err = s.w.Write([]byte("event: " + event + "\ndata: " + string(data) + "\n\n"))
+	} else {
+		_, err = s.w.Write(append(data, '\n'))
+	}
+	if err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+func isTruthy(val string) bool {
+	ok, _ := strconv.ParseBool(val)
+	return ok
+}
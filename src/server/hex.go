@@ -0,0 +1,237 @@
+package server
+
+import (
+	"math"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/meta"
+	"github.com/patppuccin/viewr/src/search"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+const hexRowSize = 16 // Bytes per row of the hex viewer
+
+type hexPage struct {
+	PathName    string
+	Path        string
+	Name        string
+	Size        int64
+	Type        *meta.FileType // Probable type by the file's signature, on the first page only
+	Offset      int64          // Of the page's first byte
+	End         int64          // Past the page's last byte
+	Target      int64          // Byte jumped to, -1 for none
+	Rows        []hexRow
+	Entropy     float64 // Bits per byte of the page, 0 to 8
+	EntropyHint string
+	FirstURL    string
+	PrevURL     string
+	NextURL     string
+	LastURL     string
+}
+
+type hexRow struct {
+	Offset string
+	Cells  []hexCell
+}
+
+type hexCell struct {
+	Hex    string
+	Char   string
+	Class  string // zero, text, space, control or high, to color bytes by
+	Target bool
+}
+
+// handleHex shows a page of any file as hex & ASCII, reading only that window of
+// it. The "offset" param, decimal or 0x-prefixed hex, jumps to a byte. The page
+// tells the file's probable type by its signature & how random its bytes look.
+// Files opened through openURL ("open" param) that are text go to their preview.
+func handleHex(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	pathCfg := mount.Config
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		http.Redirect(w, r, browseURL(pathCfg.Name, relPath), http.StatusFound)
+		return
+	}
+	target, ok := parseOffset(r.URL.Query().Get("offset"))
+	if !ok {
+		renderError(w, r, http.StatusBadRequest, "The offset is invalid.")
+		return
+	}
+
+	size := info.Size()
+	page := hexPage{
+		PathName: pathCfg.Name,
+		Path:     relPath,
+		Name:     path.Base(relPath),
+		Size:     size,
+		Target:   -1,
+	}
+
+	// Jumps show the page holding the byte, or the last page past the end
+	lastPage := max(0, size-1) / constants.HexPageSize * constants.HexPageSize
+	start := min(max(0, target)/constants.HexPageSize*constants.HexPageSize, lastPage)
+	if target >= 0 && target < size {
+		page.Target = target
+	}
+
+	// The file's type can't change between pages, so only the first one reads
+	// enough to tell it
+	var window []byte
+	if start == 0 {
+		head, err := readRange(mount.Backend, relPath, 0, max(constants.HexPageSize, meta.MagicSize))
+		if err != nil {
+			renderPathError(w, r, err)
+			return
+		}
+		if r.URL.Query().Has("open") && search.IsText(head) {
+			http.Redirect(w, r, previewURL(pathCfg.Name, relPath, 0), http.StatusFound)
+			return
+		}
+		if fileType, ok := meta.Identify(head); ok {
+			page.Type = &fileType
+		}
+		window = head[:min(int64(len(head)), constants.HexPageSize)]
+	} else if window, err = readRange(mount.Backend, relPath, start, constants.HexPageSize); err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	page.Offset, page.End = start, start+int64(len(window))
+	page.Rows = hexRows(window, start, size, page.Target)
+	page.Entropy = entropy(window)
+	page.EntropyHint = entropyHint(page.Entropy, len(window))
+
+	link := hexURL(pathCfg.Name, relPath)
+	if start > 0 {
+		page.FirstURL = link
+		page.PrevURL = link + "?offset=" + hexOffset(max(0, start-constants.HexPageSize), 0)
+	}
+	if page.End < size {
+		page.NextURL = link + "?offset=" + hexOffset(page.End, 0)
+		page.LastURL = link + "?offset=" + hexOffset(lastPage, 0)
+	}
+
+	renderPage(w, r, http.StatusOK, "hex.html", pageData{
+		Title:  pathCrumbTitle(pathCfg.Name, relPath),
+		Crumbs: pathCrumbs(pathCfg.Name, relPath),
+		Data:   page,
+	})
+}
+
+// Hex helpers
+
+// opensInline reports whether browsers show a file themselves, going by its name
+func opensInline(name string) bool {
+	mediaType := mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+	for _, prefix := range []string{"image/", "video/", "audio/", "text/", "application/pdf"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOffset reads a decimal or 0x-prefixed hex offset, -1 when there's none
+func parseOffset(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return -1, true
+	}
+	base := 10
+	if lower := strings.ToLower(s); strings.HasPrefix(lower, "0x") {
+		s, base = s[2:], 16
+	}
+	n, err := strconv.ParseInt(s, base, 64)
+	return n, err == nil && n >= 0
+}
+
+// hexOffset writes an offset as 0x-prefixed hex, padded to width digits
+func hexOffset(offset int64, width int) string {
+	digits := strconv.FormatInt(offset, 16)
+	if len(digits) < width {
+		digits = strings.Repeat("0", width-len(digits)) + digits
+	}
+	return "0x" + digits
+}
+
+// hexRows lays a window of a file out in rows, with offsets as wide as the file's
+// last one needs
+func hexRows(window []byte, start, size, target int64) []hexRow {
+	width := max(8, len(strconv.FormatInt(max(0, size-1), 16)))
+	var rows []hexRow
+	for i := 0; i < len(window); i += hexRowSize {
+		row := hexRow{Offset: strings.TrimPrefix(hexOffset(start+int64(i), width), "0x")}
+		for j, b := range window[i:min(len(window), i+hexRowSize)] {
+			cell := hexCell{
+				Hex:    strconv.FormatUint(uint64(b)|0x100, 16)[1:],
+				Char:   ".",
+				Target: start+int64(i+j) == target,
+			}
+			switch {
+			case b == 0:
+				cell.Class = "zero"
+			case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+				cell.Class = "space"
+			case b > ' ' && b < 0x7f:
+				cell.Class, cell.Char = "text", string(rune(b))
+			case b < ' ' || b == 0x7f:
+				cell.Class = "control"
+			default:
+				cell.Class = "high"
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// entropy is the Shannon entropy of data in bits per byte
+func entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	bits := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(data))
+			bits -= p * math.Log2(p)
+		}
+	}
+	return bits
+}
+
+// entropyHint tells what kind of data an entropy is typical of. Short windows
+// can't reach high entropies, so they get no hint.
+func entropyHint(bits float64, n int) string {
+	switch {
+	case n < 256:
+		return ""
+	case bits < 1:
+		return "very low, like padding"
+	case bits < 6:
+		return "low, like text or sparse data"
+	case bits < 7.5:
+		return "medium, like code or packed data"
+	}
+	return "high, likely compressed or encrypted"
+}
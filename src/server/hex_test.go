@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int64
+		wantOK bool
+	}{
+		{in: "", want: -1, wantOK: true},
+		{in: "4096", want: 4096, wantOK: true},
+		{in: " 0x1F40 ", want: 0x1f40, wantOK: true},
+		{in: "010", want: 10, wantOK: true},
+		{in: "-16"},
+		{in: "0xzz"},
+	}
+	for _, tt := range tests {
+		got, ok := parseOffset(tt.in)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("parseOffset(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want float64
+	}{
+		{name: "empty", want: 0},
+		{name: "zeroes", data: make([]byte, 512), want: 0},
+		{name: "two values", data: bytes.Repeat([]byte("ab"), 256), want: 1},
+		{name: "every value", data: all, want: 8},
+	}
+	for _, tt := range tests {
+		if got := entropy(tt.data); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("entropy(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/resolver"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+var (
+	errPathNotFound = errors.New("path not found")
+	errNotDir       = errors.New("path is not a directory")
+)
+
+// Separators smuggled through percent-encoding, as seen in chi's raw route params
+var encodedSeparators = []string{"%2f", "%5c"}
+
+// routeParam returns a decoded chi URL param. chi matches against the raw path
+// whenever the request carries one, so params may still be percent-encoded and
+// encoded separators must be refused before decoding turns them into real ones.
+func routeParam(r *http.Request, key string) (string, error) {
+	value := chi.URLParam(r, key)
+	if r.URL.RawPath == "" {
+		return value, nil
+	}
+
+	lower := strings.ToLower(value)
+	for _, seq := range encodedSeparators {
+		if strings.Contains(lower, seq) {
+			return "", resolver.ErrInvalidPath
+		}
+	}
+	return url.PathUnescape(value)
+}
+
+// resolvePath looks up the mount of a path name and validates the client-supplied
+// relative path. Unknown & disabled roots surface as errPathNotFound.
+func resolvePath(r *http.Request, pathName, relPath string) (*storage.Mount, string, error) {
+	mount, ok := getStorage(r).Get(pathName)
+	if !ok {
+		return nil, "", errPathNotFound
+	}
+
+	cleanPath, err := resolver.Clean(relPath)
+	if err != nil {
+		return nil, "", err
+	}
+	return mount, cleanPath, nil
+}
+
+// resolveRoute resolves the "pathName" & wildcard params of a route
+func resolveRoute(r *http.Request) (*storage.Mount, string, error) {
+	pathName, err := routeParam(r, "pathName")
+	if err != nil {
+		return nil, "", err
+	}
+	relPath, err := routeParam(r, "*")
+	if err != nil {
+		return nil, "", err
+	}
+	return resolvePath(r, pathName, relPath)
+}
+
+// listDir reads the entries of a directory, or the members of an archive, under
+// a mount
+func listDir(mount *storage.Mount, relPath string) ([]models.FileEntry, error) {
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() && !storage.IsArchive(relPath) {
+		return nil, errNotDir
+	}
+	return readListing(mount.Backend, relPath)
+}
+
+func readListing(backend storage.Backend, relPath string) ([]models.FileEntry, error) {
+	dirEntries, err := backend.ReadDir(storage.Name(relPath))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.FileEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		entryPath := path.Join(relPath, de.Name())
+		isSymlink := de.Type()&fs.ModeSymlink != 0
+
+		// Symlinks are followed through the backend, which refuses the ones leading
+		// outside of the root, those are hidden. Entries that vanished are skipped.
+		var info fs.FileInfo
+		if isSymlink {
+			info, err = backend.Stat(entryPath)
+		} else {
+			info, err = de.Info()
+		}
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, newFileEntry(entryPath, info, isSymlink))
+	}
+
+	// Folders first, then case-insensitive name order
+	slices.SortFunc(entries, func(a, b models.FileEntry) int {
+		if a.IsDir != b.IsDir {
+			if a.IsDir {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	return entries, nil
+}
+
+func newFileEntry(relPath string, info fs.FileInfo, isSymlink bool) models.FileEntry {
+	// Symlinks report the target's info, so take the name from the link path
+	name := path.Base(relPath)
+	entry := models.FileEntry{
+		Name:      name,
+		Path:      relPath,
+		Size:      info.Size(),
+		Mode:      info.Mode().String(),
+		ModTime:   info.ModTime(),
+		IsDir:     info.IsDir(),
+		IsSymlink: isSymlink,
+		Kind:      fileKind(name, info.IsDir()),
+	}
+	if entry.IsDir {
+		entry.Size = 0
+	} else {
+		entry.MimeType = mimeType(name)
+		entry.IsArchive = storage.IsArchive(name)
+	}
+	return entry
+}
+
+func mimeType(name string) string {
+	if mt := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); mt != "" {
+		return mt
+	}
+	return "application/octet-stream"
+}
+
+func fileKind(name string, isDir bool) string {
+	if isDir {
+		return "Folder"
+	}
+	ext := strings.TrimPrefix(filepath.Ext(name), ".")
+	if ext == "" {
+		return "File"
+	}
+	return strings.ToUpper(ext) + " File"
+}
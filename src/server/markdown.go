@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Names shown rendered above a folder listing, first match wins
+var readmeNames = []string{"readme.md", "readme.markdown", "index.md"}
+
+// Raw HTML in Markdown is let through by the renderer & sanitized afterwards, so
+// harmless markup like <details> keeps working
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// isMarkdown tells Markdown files apart by extension
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
+
+// renderMarkdown renders a Markdown document found at relPath in a configured path.
+// Relative links & images are resolved from the document's folder & stay within
+// the path, links leaving it are dropped.
+func renderMarkdown(source []byte, pathName, relPath string) (template.HTML, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	var buf bytes.Buffer
+	if err := md.Convert(source, &buf); err != nil {
+		return "", err
+	}
+	lr := &linkRewriter{pathName: pathName, dir: parentPath(relPath)}
+	return template.HTML(lr.rewriteHTML(markdownPolicy.SanitizeBytes(buf.Bytes()))), nil
+}
+
+// linkRewriter points relative link & image URLs at viewr's own routes, those of
+// Markdown & raw HTML alike
+type linkRewriter struct {
+	pathName string
+	dir      string
+}
+
+// rewriteHTML rewrites the href of links & the src of images in sanitized HTML,
+// leaving everything else as it is
+func (lr *linkRewriter) rewriteHTML(rendered []byte) []byte {
+	var out bytes.Buffer
+	z := xhtml.NewTokenizer(bytes.NewReader(rendered))
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			// Sanitized output only ends at EOF
+			return out.Bytes()
+		}
+		raw := z.Raw()
+		if tt != xhtml.StartTagToken && tt != xhtml.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+
+		tok := z.Token()
+		key, isImage := "href", false
+		switch tok.DataAtom {
+		case atom.A:
+		case atom.Img:
+			key, isImage = "src", true
+		default:
+			out.Write(raw)
+			continue
+		}
+
+		attrs := tok.Attr[:0]
+		for _, attr := range tok.Attr {
+			if attr.Key == key {
+				var ok bool
+				if attr.Val, ok = lr.rewrite(attr.Val, isImage); !ok {
+					continue
+				}
+			}
+			attrs = append(attrs, attr)
+		}
+		tok.Attr = attrs
+		out.WriteString(tok.String())
+	}
+}
+
+// rewrite resolves a relative URL from the document's folder, failing for URLs
+// leaving the configured path
+func (lr *linkRewriter) rewrite(dest string, isImage bool) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", false
+	}
+	// Absolute URLs, other hosts & in-page anchors are left alone
+	if u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return dest, true
+	}
+
+	// Leading slashes mean the root of the configured path
+	target := path.Join(lr.dir, u.Path)
+	if strings.HasPrefix(u.Path, "/") {
+		target = path.Clean(strings.TrimLeft(u.Path, "/"))
+	}
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	if target == "." {
+		target = ""
+	}
+
+	link := browseURL(lr.pathName, target)
+	if isImage {
+		link = rawURL(lr.pathName, target)
+	}
+	if u.Fragment != "" {
+		link += "#" + u.EscapedFragment()
+	}
+	return link, true
+}
+
+// Markdown helpers
+
+// findReadme picks the entry to show rendered above a listing
+func findReadme(entries []models.FileEntry) (models.FileEntry, bool) {
+	for _, name := range readmeNames {
+		for _, entry := range entries {
+			if !entry.IsDir && strings.EqualFold(entry.Name, name) {
+				return entry, true
+			}
+		}
+	}
+	return models.FileEntry{}, false
+}
+
+// readText reads a file, up to maxSize bytes of it
+func readText(backend storage.Backend, relPath string, maxSize int64) ([]byte, error) {
+	return readRange(backend, relPath, 0, maxSize)
+}
+
+// readRange reads up to n bytes of a file from offset
+func readRange(backend storage.Backend, relPath string, offset, n int64) ([]byte, error) {
+	rc, err := backend.OpenRange(relPath, offset, n)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	return io.ReadAll(io.LimitReader(rc, n))
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	source := strings.Join([]string{
+		"# Guide",
+		"[setup](setup.md#install) [root](/index.md) [up](../other/) [home](/) [escape](../../../etc/passwd)",
+		"[site](https://example.com) [anchor](#guide) ![logo](img/logo.png)",
+		`<script>alert(1)</script><a href="javascript:alert(1)" onclick="x()">bad</a>`,
+		`<p><img src="pic.png" alt="pic"> <a href="doc.md#top">doc</a> <img src="/../../secret.png"> <a href="https://example.org/a?b=1&amp;c=2">out</a></p>`,
+		"- [x] done",
+	}, "\n\n")
+
+	out, err := renderMarkdown([]byte(source), "Docs Share", "guides/intro/README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(out)
+
+	for _, want := range []string{
+		`<h1 id="guide">Guide</h1>`,
+		`href="/browse/Docs%20Share/guides/intro/setup.md#install"`,
+		`href="/browse/Docs%20Share/index.md"`,
+		`href="/browse/Docs%20Share/guides/other"`,
+		`href="/browse/Docs%20Share"`,
+		`href="https://example.com"`,
+		`href="#guide"`,
+		`src="/raw/Docs%20Share/guides/intro/img/logo.png"`,
+		`<img src="/raw/Docs%20Share/guides/intro/pic.png" alt="pic">`,
+		`href="/browse/Docs%20Share/guides/intro/doc.md#top"`,
+		`href="https://example.org/a?b=1&amp;c=2"`,
+		`type="checkbox"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("rendered Markdown is missing %s:\n%s", want, html)
+		}
+	}
+	for _, unwanted := range []string{"<script", "javascript:", "onclick", "etc/passwd", "secret.png"} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("rendered Markdown contains %s:\n%s", unwanted, html)
+		}
+	}
+}
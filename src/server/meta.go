@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/patppuccin/viewr/src/meta"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+type apiMeta struct {
+	Name     string         `json:"name"`
+	Path     string         `json:"path"`
+	Size     int64          `json:"size"`
+	ModTime  time.Time      `json:"mtime"`
+	MimeType string         `json:"mimeType,omitempty"`
+	Format   string         `json:"format,omitempty"`
+	EXIF     *meta.EXIF     `json:"exif,omitempty"`
+	Tags     *meta.Tags     `json:"tags,omitempty"`
+	Media    *meta.Media    `json:"media,omitempty"`
+	Document *meta.Document `json:"document,omitempty"`
+}
+
+// handleAPIMeta returns what's known about a file beyond its listing entry: EXIF
+// data of images, tags & stream info of audio & video, and PDF document info.
+// Files of other formats get just the basics.
+func handleAPIMeta(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveAPIRoute(r)
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+
+	if info, err := mount.Backend.Stat(storage.Name(relPath)); err != nil {
+		writePathError(w, r, err)
+		return
+	} else if info.IsDir() {
+		writeJSONError(w, http.StatusBadRequest, "path is a directory")
+		return
+	}
+
+	file, info, err := storage.OpenReaderAt(mount.Backend, storage.Name(relPath))
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	res := apiMeta{
+		Name:     path.Base(relPath),
+		Path:     relPath,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		MimeType: mimeType(relPath),
+	}
+
+	md, err := meta.Read(file, info.Size(), relPath)
+	switch {
+	case errors.Is(err, meta.ErrUnsupported):
+	case err != nil:
+		writePathError(w, r, err)
+		return
+	default:
+		res.Format, res.EXIF, res.Tags, res.Media, res.Document = md.Format, md.EXIF, md.Tags, md.Media, md.Document
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/patppuccin/viewr/src/constants"
 	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/patppuccin/viewr/src/thumbs"
 )
 
 func loadServerContext(serverCtx *models.AppContext) func(http.Handler) http.Handler {
@@ -20,6 +22,35 @@ func loadServerContext(serverCtx *models.AppContext) func(http.Handler) http.Han
 	}
 }
 
+// preventCSRF rejects cross-origin state-changing requests from browsers, based on
+// Sec-Fetch-Site & Origin headers. Non-browser clients (scripts, curl) send neither
+// and pass through, so API consumers need no token handling.
+func preventCSRF() func(http.Handler) http.Handler {
+	protection := http.NewCrossOriginProtection()
+	protection.SetDenyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+	}))
+	return protection.Handler
+}
+
+func loadStorage(registry *storage.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), constants.StorageCtxKey, registry)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func loadThumbs(thumbCache *thumbs.Cache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), constants.ThumbsCtxKey, thumbCache)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func logRequest() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -59,3 +90,23 @@ func logRequest() func(http.Handler) http.Handler {
 		})
 	}
 }
+
+func getServerCtx(r *http.Request) *models.AppContext {
+	serverCtx, _ := r.Context().Value(constants.AppCtxKey).(*models.AppContext)
+	return serverCtx
+}
+
+func getStorage(r *http.Request) *storage.Registry {
+	registry, _ := r.Context().Value(constants.StorageCtxKey).(*storage.Registry)
+	if registry == nil {
+		return storage.NewRegistryFrom()
+	}
+	return registry
+}
+
+// getThumbs returns the thumbnail cache, nil when thumbnails are disabled or
+// unavailable
+func getThumbs(r *http.Request) *thumbs.Cache {
+	thumbCache, _ := r.Context().Value(constants.ThumbsCtxKey).(*thumbs.Cache)
+	return thumbCache
+}
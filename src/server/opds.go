@@ -0,0 +1,289 @@
+package server
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/meta"
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// OPDS feed types, the catalog's navigation & the books of a folder
+const (
+	opdsNavigation  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	opdsAcquisition = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+)
+
+// E-book formats offered in the catalog, by extension
+var ebookTypes = map[string]string{
+	".epub": "application/epub+zip",
+	".pdf":  "application/pdf",
+	".mobi": "application/x-mobipocket-ebook",
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	DC      string      `xml:"xmlns:dc,attr"`
+	OPDS    string      `xml:"xmlns:opds,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Authors   []atomAuthor `xml:"author"`
+	Language  string       `xml:"dc:language,omitempty"`
+	Publisher string       `xml:"dc:publisher,omitempty"`
+	Issued    string       `xml:"dc:issued,omitempty"`
+	Summary   *atomText    `xml:"summary"`
+	Content   *atomText    `xml:"content"`
+	Links     []atomLink   `xml:"link"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+// handleOPDSRoot is the start of the OPDS catalog, leading to each configured path
+func handleOPDSRoot(w http.ResponseWriter, r *http.Request) {
+	feed := newAtomFeed(r, "/opds", constants.AppFullName, opdsNavigation)
+	for _, mount := range getStorage(r).List() {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      absoluteURL(r, opdsURL(mount.Config.Name, "")),
+			Title:   mount.Config.Name,
+			Updated: feed.Updated,
+			Content: &atomText{Type: "text", Value: "Books of " + mount.Config.Name},
+			Links:   []atomLink{{Rel: "subsection", Href: opdsURL(mount.Config.Name, ""), Type: opdsNavigation}},
+		})
+	}
+	writeAtom(w, feed, opdsNavigation)
+}
+
+// handleOPDS lists a folder in the OPDS catalog: its subfolders to navigate to and
+// its e-books, linked to their raw files & covers. Large folders come in pages.
+func handleOPDS(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	pathCfg := mount.Config
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if !info.IsDir() {
+		http.Error(w, "not a folder", http.StatusBadRequest)
+		return
+	}
+	entries, err := readListing(mount.Backend, relPath)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	var items []models.FileEntry
+	books := false
+	for _, entry := range entries {
+		if entry.IsDir || isEbook(entry.Name) {
+			items = append(items, entry)
+			books = books || !entry.IsDir
+		}
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pages := max(1, (len(items)+constants.OPDSPageSize-1)/constants.OPDSPageSize)
+	page = min(max(page, 1), pages)
+	items = items[(page-1)*constants.OPDSPageSize : min(len(items), page*constants.OPDSPageSize)]
+
+	kind := opdsNavigation
+	if books {
+		kind = opdsAcquisition
+	}
+	self := opdsURL(pathCfg.Name, relPath)
+	feed := newAtomFeed(r, self, folderTitle(pathCfg.Name, relPath), kind)
+	up := "/opds"
+	if relPath != "" {
+		up = opdsURL(pathCfg.Name, parentPath(relPath))
+	}
+	feed.Links = append(feed.Links, atomLink{Rel: "up", Href: up, Type: opdsNavigation})
+	if page > 1 {
+		feed.Links = append(feed.Links, atomLink{Rel: "previous", Href: self + "?page=" + strconv.Itoa(page-1), Type: kind})
+	}
+	if page < pages {
+		feed.Links = append(feed.Links, atomLink{Rel: "next", Href: self + "?page=" + strconv.Itoa(page+1), Type: kind})
+	}
+
+	for _, entry := range items {
+		if entry.IsDir {
+			feed.Entries = append(feed.Entries, atomEntry{
+				ID:      absoluteURL(r, opdsURL(pathCfg.Name, entry.Path)),
+				Title:   entry.Name,
+				Updated: entry.ModTime.UTC().Format(time.RFC3339),
+				Links:   []atomLink{{Rel: "subsection", Href: opdsURL(pathCfg.Name, entry.Path), Type: opdsNavigation}},
+			})
+			continue
+		}
+		feed.Entries = append(feed.Entries, bookEntry(r, mount, entry))
+	}
+	writeAtom(w, feed, kind)
+}
+
+// handleCover serves the cover image of an EPUB
+func handleCover(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	file, info, err := storage.OpenReaderAt(mount.Backend, storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	cover, coverType, err := meta.EPUBCover(file, info.Size())
+	switch {
+	case errors.Is(err, meta.ErrNoCover):
+		http.Error(w, "no cover image", http.StatusNotFound)
+		return
+	case errors.Is(err, meta.ErrUnsupported):
+		http.Error(w, "not an EPUB", http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		renderPathError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", coverType)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime(), bytes.NewReader(cover))
+}
+
+// OPDS helpers
+
+func isEbook(name string) bool {
+	_, ok := ebookTypes[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// hasEbooks reports whether a folder holds e-books to offer its catalog for
+func hasEbooks(entries []models.FileEntry) bool {
+	return slices.ContainsFunc(entries, func(entry models.FileEntry) bool {
+		return !entry.IsDir && isEbook(entry.Name)
+	})
+}
+
+// bookEntry describes an e-book for the catalog, from its EPUB metadata when it
+// has some & by its file name otherwise
+func bookEntry(r *http.Request, mount *storage.Mount, entry models.FileEntry) atomEntry {
+	pathName := mount.Config.Name
+	item := atomEntry{
+		ID:      absoluteURL(r, rawURL(pathName, entry.Path)),
+		Title:   strings.TrimSuffix(entry.Name, path.Ext(entry.Name)),
+		Updated: entry.ModTime.UTC().Format(time.RFC3339),
+		Links: []atomLink{{
+			Rel:    "http://opds-spec.org/acquisition",
+			Href:   rawURL(pathName, entry.Path),
+			Type:   ebookTypes[strings.ToLower(path.Ext(entry.Name))],
+			Length: entry.Size,
+		}},
+	}
+	if strings.ToLower(path.Ext(entry.Name)) != ".epub" {
+		return item
+	}
+
+	file, info, err := storage.OpenReaderAt(mount.Backend, storage.Name(entry.Path))
+	if err != nil {
+		return item
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	book, err := meta.ReadEPUB(file, info.Size())
+	if err != nil {
+		return item
+	}
+
+	if book.Title != "" {
+		item.Title = book.Title
+	}
+	if book.Author != "" {
+		item.Authors = []atomAuthor{{Name: book.Author}}
+	}
+	item.Language, item.Publisher, item.Issued = book.Language, book.Publisher, book.Date
+	if book.Description != "" {
+		item.Summary = &atomText{Type: "text", Value: book.Description}
+	}
+	if book.CoverType != "" {
+		cover := coverURL(pathName, entry.Path)
+		item.Links = append(item.Links,
+			atomLink{Rel: "http://opds-spec.org/image", Href: cover, Type: book.CoverType},
+			atomLink{Rel: "http://opds-spec.org/image/thumbnail", Href: cover, Type: book.CoverType},
+		)
+	}
+	return item
+}
+
+func newAtomFeed(r *http.Request, self, title, kind string) atomFeed {
+	return atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/terms/",
+		OPDS:    "http://opds-spec.org/2010/catalog",
+		ID:      absoluteURL(r, self),
+		Title:   title,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: constants.AppFullName},
+		Links: []atomLink{
+			{Rel: "self", Href: self, Type: kind},
+			{Rel: "start", Href: "/opds", Type: opdsNavigation},
+		},
+	}
+}
+
+func writeAtom(w http.ResponseWriter, feed atomFeed, kind string) {
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", kind+";charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(out)
+}
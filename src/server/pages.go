@@ -0,0 +1,168 @@
+package server
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/resolver"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// Listing views & the cookie remembering the last one used
+const (
+	viewList   = "list"
+	viewGrid   = "grid"
+	viewCookie = "viewr_view"
+)
+
+type indexPage struct {
+	Paths []models.PathConfig
+}
+
+type browsePage struct {
+	PathName  string
+	Path      string
+	IsArchive bool // Listing the members of an archive file
+	Entries   []models.FileEntry
+	Readme    template.HTML // Rendered README of the folder, if it has one
+	View      string        // list or grid
+	Thumbs    bool          // Thumbnails are available for the grid
+	Gallery   bool          // Mostly images, so the gallery is offered
+	Audio     bool          // Has audio files, so a playlist & feed are offered
+	Books     bool          // Has e-books, so the OPDS catalog is offered
+}
+
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	var paths []models.PathConfig
+	for _, mount := range getStorage(r).List() {
+		paths = append(paths, mount.Config)
+	}
+
+	renderPage(w, r, http.StatusOK, "index.html", pageData{
+		Data: indexPage{Paths: paths},
+	})
+}
+
+func handleBrowse(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	pathCfg := mount.Config
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	// Archives list their members, other files open in their viewer or are served as they are
+	isArchive := !info.IsDir() && storage.IsArchive(relPath)
+	if !info.IsDir() && !isArchive {
+		http.Redirect(w, r, openURL(pathCfg.Name, relPath), http.StatusFound)
+		return
+	}
+	entries, err := readListing(mount.Backend, relPath)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	// A README that can't be shown leaves just the listing
+	var readme template.HTML
+	if entry, ok := findReadme(entries); ok && entry.Size <= getServerCtx(r).Config.Preview.MaxSize {
+		if source, err := readText(mount.Backend, entry.Path, entry.Size); err == nil {
+			readme, _ = renderMarkdown(source, pathCfg.Name, entry.Path)
+		}
+	}
+
+	renderPage(w, r, http.StatusOK, "browse.html", pageData{
+		Title:  pathCrumbTitle(pathCfg.Name, relPath),
+		Crumbs: pathCrumbs(pathCfg.Name, relPath),
+		Data: browsePage{
+			PathName:  pathCfg.Name,
+			Path:      relPath,
+			IsArchive: isArchive,
+			Entries:   entries,
+			Readme:    readme,
+			View:      listingView(w, r),
+			Thumbs:    getThumbs(r) != nil,
+			Gallery:   mostlyImages(entries),
+			Audio:     hasAudio(entries),
+			Books:     !isArchive && hasEbooks(entries),
+		},
+	})
+}
+
+// Page helpers
+
+// listingView picks list or grid from the "view" param, remembering the choice in
+// a cookie for the folders visited next
+func listingView(w http.ResponseWriter, r *http.Request) string {
+	view := r.URL.Query().Get("view")
+	if view == viewList || view == viewGrid {
+		http.SetCookie(w, &http.Cookie{
+			Name:     viewCookie,
+			Value:    view,
+			Path:     "/",
+			MaxAge:   365 * 24 * 60 * 60,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		return view
+	}
+	if cookie, err := r.Cookie(viewCookie); err == nil && cookie.Value == viewGrid {
+		return viewGrid
+	}
+	return viewList
+}
+
+func renderPathError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, resolver.ErrInvalidPath):
+		renderError(w, r, http.StatusBadRequest, "The requested path is invalid.")
+	case errors.Is(err, resolver.ErrOutsideRoot):
+		renderError(w, r, http.StatusForbidden, "The requested path is outside of the configured folder.")
+	case errors.Is(err, errPathNotFound), errors.Is(err, fs.ErrNotExist):
+		renderError(w, r, http.StatusNotFound, "The requested path does not exist.")
+	case errors.Is(err, fs.ErrInvalid):
+		renderError(w, r, http.StatusBadRequest, "The requested path is invalid.")
+	case errors.Is(err, errNotDir):
+		renderError(w, r, http.StatusNotFound, "The requested path is not a folder.")
+	default:
+		getServerCtx(r).Logger.Error().Msg("failed to read " + r.URL.Path + ": " + err.Error())
+		renderError(w, r, http.StatusInternalServerError, "The requested path could not be read.")
+	}
+}
+
+func pathCrumbs(pathName, relPath string) []crumb {
+	crumbs := []crumb{{Label: "Home", URL: "/"}, {Label: pathName}}
+	if relPath == "" {
+		return crumbs
+	}
+	crumbs[1].URL = browseURL(pathName, "")
+
+	// Link every segment except the current (last) one
+	segments := strings.Split(relPath, "/")
+	for i, segment := range segments {
+		c := crumb{Label: segment}
+		if i < len(segments)-1 {
+			c.URL = browseURL(pathName, strings.Join(segments[:i+1], "/"))
+		}
+		crumbs = append(crumbs, c)
+	}
+	return crumbs
+}
+
+func pathCrumbTitle(pathName, relPath string) string {
+	if relPath == "" {
+		return pathName
+	}
+	return path.Base(relPath) + " - " + pathName
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// Formats browsers play natively, by extension
+var playableTypes = map[string]string{
+	".mp4": "video/mp4", ".m4v": "video/mp4", ".webm": "video/webm", ".ogv": "video/ogg", ".mov": "video/quicktime",
+	".mkv": "video/x-matroska",
+	".mp3": "audio/mpeg", ".m4a": "audio/mp4", ".m4b": "audio/mp4", ".aac": "audio/aac", ".ogg": "audio/ogg",
+	".oga": "audio/ogg", ".opus": "audio/ogg", ".flac": "audio/flac", ".wav": "audio/wav",
+}
+
+var subtitleExtensions = []string{".vtt", ".srt"}
+
+// Language tags like en, pt-BR or zho in subtitle names such as movie.en.srt
+var subtitleLang = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{2,4})?$`)
+
+// SRT cue timings, 00:01:02,345 --> 00:01:04,000
+var srtTiming = regexp.MustCompile(`(\d{1,2}:\d{2}:\d{2}),(\d{3})`)
+
+type playerPage struct {
+	PathName  string
+	Path      string
+	Name      string
+	Size      int64
+	Kind      string // video or audio
+	Type      string // Content type of the source
+	Subtitles []subtitleTrack
+	Prev      string // Neighbouring media files of the folder, if any
+	Next      string
+}
+
+type subtitleTrack struct {
+	Label string
+	Lang  string
+	URL   string
+}
+
+// handlePlay shows a video or audio file in the browser's player, streamed from the
+// raw route, with the folder's other media a click away
+func handlePlay(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	pathCfg := mount.Config
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if info.IsDir() || !canPlay(relPath) {
+		http.Redirect(w, r, browseURL(pathCfg.Name, relPath), http.StatusFound)
+		return
+	}
+
+	contentType := playableTypes[strings.ToLower(path.Ext(relPath))]
+	kind, _, _ := strings.Cut(contentType, "/")
+	page := playerPage{
+		PathName: pathCfg.Name,
+		Path:     relPath,
+		Name:     path.Base(relPath),
+		Size:     info.Size(),
+		Kind:     kind,
+		Type:     contentType,
+	}
+
+	// Neighbours & sidecar subtitles come from the folder, a listing that fails
+	// just leaves them out
+	if entries, err := readListing(mount.Backend, parentPath(relPath)); err == nil {
+		var media []string
+		stem := strings.TrimSuffix(page.Name, path.Ext(page.Name))
+		for _, entry := range entries {
+			if entry.IsDir {
+				continue
+			}
+			if canPlay(entry.Name) {
+				media = append(media, entry.Path)
+			}
+			if track, ok := subtitleFor(stem, entry.Name); ok {
+				track.URL = subtitleURL(pathCfg.Name, entry.Path)
+				page.Subtitles = append(page.Subtitles, track)
+			}
+		}
+		for i, p := range media {
+			if p != relPath {
+				continue
+			}
+			if i > 0 {
+				page.Prev = media[i-1]
+			}
+			if i < len(media)-1 {
+				page.Next = media[i+1]
+			}
+		}
+	}
+
+	renderPage(w, r, http.StatusOK, "player.html", pageData{
+		Title:  pathCrumbTitle(pathCfg.Name, relPath),
+		Crumbs: pathCrumbs(pathCfg.Name, relPath),
+		Data:   page,
+	})
+}
+
+// handleSubtitles serves WebVTT subtitles as they are, and SRT ones converted to
+// WebVTT, which is all browsers take
+func handleSubtitles(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	ext := strings.ToLower(path.Ext(relPath))
+	if !slices.Contains(subtitleExtensions, ext) {
+		http.Error(w, "not a subtitle file", http.StatusUnsupportedMediaType)
+		return
+	}
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if info.IsDir() || info.Size() > constants.MaxSubtitleSize {
+		http.Error(w, "not a subtitle file", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := readText(mount.Backend, relPath, constants.MaxSubtitleSize)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if ext == ".srt" {
+		data = srtToVTT(data)
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	_, _ = w.Write(data)
+}
+
+// Player helpers
+
+func canPlay(name string) bool {
+	_, ok := playableTypes[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// subtitleFor tells whether name is a subtitle of the media file named stem, like
+// movie.srt or movie.en.vtt for movie.mp4
+func subtitleFor(stem, name string) (subtitleTrack, bool) {
+	ext := path.Ext(name)
+	if !slices.Contains(subtitleExtensions, strings.ToLower(ext)) {
+		return subtitleTrack{}, false
+	}
+	base := strings.TrimSuffix(name, ext)
+	if base == stem {
+		return subtitleTrack{Label: "Subtitles"}, true
+	}
+	lang, ok := strings.CutPrefix(base, stem+".")
+	if !ok || lang == "" {
+		return subtitleTrack{}, false
+	}
+	track := subtitleTrack{Label: lang}
+	if subtitleLang.MatchString(lang) {
+		track.Lang = lang
+	}
+	return track, true
+}
+
+// srtToVTT converts SubRip subtitles to WebVTT: a header, dots in the timings &
+// UTF-8 text, older SRT files are often Latin-1
+func srtToVTT(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		runes := make([]rune, len(data))
+		for i, c := range data {
+			runes[i] = rune(c)
+		}
+		data = []byte(string(runes))
+	}
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n\n")
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.Contains(line, []byte("-->")) {
+			line = srtTiming.ReplaceAll(line, []byte("$1.$2"))
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,33 @@
+package server
+
+import "testing"
+
+func TestSRTToVTT(t *testing.T) {
+	srt := "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,500\r\nCaf\xe9, 12,5%\r\n\r\n2\r\n01:02:03,004 --> 01:02:05,000 X1:10\r\nBye\r\n"
+	want := "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nCafé, 12,5%\n\n2\n01:02:03.004 --> 01:02:05.000 X1:10\nBye\n\n"
+	if got := string(srtToVTT([]byte(srt))); got != want {
+		t.Fatalf("srtToVTT() = %q, want %q", got, want)
+	}
+}
+
+func TestSubtitleFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   subtitleTrack
+		wantOK bool
+	}{
+		{name: "movie.srt", want: subtitleTrack{Label: "Subtitles"}, wantOK: true},
+		{name: "movie.en.VTT", want: subtitleTrack{Label: "en", Lang: "en"}, wantOK: true},
+		{name: "movie.pt-BR.srt", want: subtitleTrack{Label: "pt-BR", Lang: "pt-BR"}, wantOK: true},
+		{name: "movie.Director's cut.srt", want: subtitleTrack{Label: "Director's cut"}, wantOK: true},
+		{name: "movie.mp4"},
+		{name: "movie2.srt"},
+		{name: "other.en.srt"},
+	}
+	for _, tt := range tests {
+		got, ok := subtitleFor("movie", tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("subtitleFor(%q) = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bytes"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/meta"
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// audioTrack is an audio file of a folder along with what its tags tell
+type audioTrack struct {
+	Entry    models.FileEntry
+	Title    string
+	Artist   string
+	Disc     int
+	Track    int
+	Duration float64 // Seconds, 0 when unknown
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Author        string    `xml:"itunes:author,omitempty"`
+	Type          string    `xml:"itunes:type"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title     string       `xml:"title"`
+	Enclosure rssEnclosure `xml:"enclosure"`
+	GUID      rssGUID      `xml:"guid"`
+	PubDate   string       `xml:"pubDate"`
+	Author    string       `xml:"itunes:author,omitempty"`
+	Duration  int          `xml:"itunes:duration,omitempty"` // Seconds
+	Episode   int          `xml:"itunes:episode"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// handlePlaylist lists the audio files of a folder as an M3U8 playlist, in track
+// order, linking to their raw files so any media player can stream them
+func handlePlaylist(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, tracks, ok := folderTracks(w, r)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("#EXTM3U\n")
+	buf.WriteString("#PLAYLIST:" + m3uText(folderTitle(mount.Config.Name, relPath)) + "\n")
+	for _, track := range tracks {
+		duration := -1
+		if track.Duration > 0 {
+			duration = int(track.Duration + 0.5)
+		}
+		title := track.Title
+		if track.Artist != "" {
+			title = track.Artist + " - " + title
+		}
+		buf.WriteString("#EXTINF:" + strconv.Itoa(duration) + "," + m3uText(title) + "\n")
+		buf.WriteString(absoluteURL(r, rawURL(mount.Config.Name, track.Entry.Path)) + "\n")
+	}
+
+	w.Header().Set("Content-Type", "audio/x-mpegurl; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": folderTitle(mount.Config.Name, relPath) + ".m3u8"}))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	_, _ = buf.WriteTo(w)
+}
+
+// handleFeed lists the audio files of a folder as a podcast RSS feed. Episodes are
+// numbered in track order & the feed is marked serial, so podcast apps keep it.
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, tracks, ok := folderTracks(w, r)
+	if !ok {
+		return
+	}
+
+	channel := rssChannel{
+		Title:         folderTitle(mount.Config.Name, relPath),
+		Link:          absoluteURL(r, browseURL(mount.Config.Name, relPath)),
+		Description:   "Audio files of " + path.Join(mount.Config.Name, relPath),
+		LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+		Type:          "serial",
+	}
+	for i, track := range tracks {
+		link := absoluteURL(r, rawURL(mount.Config.Name, track.Entry.Path))
+		channel.Items = append(channel.Items, rssItem{
+			Title:     track.Title,
+			Enclosure: rssEnclosure{URL: link, Length: track.Entry.Size, Type: playableTypes[strings.ToLower(path.Ext(track.Entry.Name))]},
+			GUID:      rssGUID{Value: link},
+			PubDate:   track.Entry.ModTime.UTC().Format(time.RFC1123Z),
+			Author:    track.Artist,
+			Duration:  int(track.Duration + 0.5),
+			Episode:   i + 1,
+		})
+		if channel.Author == "" {
+			channel.Author = track.Artist
+		}
+	}
+
+	out, err := xml.MarshalIndent(rssFeed{
+		Version: "2.0",
+		ITunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Channel: channel,
+	}, "", "  ")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(out)
+}
+
+// Playlist helpers
+
+func isAudio(name string) bool {
+	return strings.HasPrefix(playableTypes[strings.ToLower(path.Ext(name))], "audio/")
+}
+
+// hasAudio reports whether a folder holds audio files to offer a playlist of
+func hasAudio(entries []models.FileEntry) bool {
+	return slices.ContainsFunc(entries, func(entry models.FileEntry) bool {
+		return !entry.IsDir && isAudio(entry.Name)
+	})
+}
+
+// folderTracks resolves the folder of a playlist or feed request & reads the tags
+// of its audio files, writing the error response when that fails
+func folderTracks(w http.ResponseWriter, r *http.Request) (*storage.Mount, string, []audioTrack, bool) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return nil, "", nil, false
+	}
+
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return nil, "", nil, false
+	}
+	if !info.IsDir() && !storage.IsArchive(relPath) {
+		http.Error(w, "not a folder", http.StatusBadRequest)
+		return nil, "", nil, false
+	}
+	entries, err := readListing(mount.Backend, relPath)
+	if err != nil {
+		renderPathError(w, r, err)
+		return nil, "", nil, false
+	}
+
+	var tracks []audioTrack
+	for _, entry := range entries {
+		if entry.IsDir || !isAudio(entry.Name) {
+			continue
+		}
+		if len(tracks) == constants.MaxPlaylistTracks {
+			break
+		}
+		tracks = append(tracks, readTrack(mount.Backend, entry))
+	}
+	sortTracks(tracks)
+	return mount, relPath, tracks, true
+}
+
+// readTrack reads the tags of an audio file, a file without them is titled by
+// its name
+func readTrack(backend storage.Backend, entry models.FileEntry) audioTrack {
+	track := audioTrack{Entry: entry}
+	if file, info, err := storage.OpenReaderAt(backend, storage.Name(entry.Path)); err == nil {
+		if md, err := meta.Read(file, info.Size(), entry.Name); err == nil {
+			if md.Tags != nil {
+				track.Title, track.Artist = md.Tags.Title, md.Tags.Artist
+				track.Disc, track.Track = md.Tags.Disc, md.Tags.Track
+			}
+			if md.Media != nil {
+				track.Duration = md.Media.Duration
+			}
+		}
+		_ = file.Close()
+	}
+	if track.Title == "" {
+		track.Title = strings.TrimSuffix(entry.Name, path.Ext(entry.Name))
+	}
+	return track
+}
+
+// sortTracks orders tracks by disc & track number, the untagged ones after them
+// by file name. Listings come sorted by name, which the stable sort keeps.
+func sortTracks(tracks []audioTrack) {
+	slices.SortStableFunc(tracks, func(a, b audioTrack) int {
+		switch {
+		case a.Track == 0 && b.Track == 0:
+			return 0
+		case a.Track == 0:
+			return 1
+		case b.Track == 0:
+			return -1
+		case a.Disc != b.Disc:
+			return a.Disc - b.Disc
+		}
+		return a.Track - b.Track
+	})
+}
+
+// folderTitle names a folder's playlist after the folder, or the path at its root
+func folderTitle(pathName, relPath string) string {
+	if relPath == "" {
+		return pathName
+	}
+	return path.Base(relPath)
+}
+
+// absoluteURL turns a site path into a full URL, for clients outside the browser.
+// Proxies in front are trusted to report the original scheme & host.
+func absoluteURL(r *http.Request, p string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host + p
+}
+
+// m3uText keeps playlist text on its line
+func m3uText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
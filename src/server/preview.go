@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/patppuccin/viewr/src/search"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+// Highlighting styles for the light & dark themes
+const (
+	highlightStyleLight = "github"
+	highlightStyleDark  = "github-dark"
+)
+
+type previewPage struct {
+	PathName string
+	Path     string
+	Name     string
+	Size     int64
+	Language string
+	Code     template.HTML
+	Markdown template.HTML // Rendered Markdown, shown instead of the source
+	Source   bool          // The source of a Markdown file was asked for
+	TooLarge bool          // Over the configured max preview size
+	Binary   bool
+	MaxSize  int64
+}
+
+var highlighter = html.New(
+	html.WithClasses(true),
+	html.WithLineNumbers(true),
+	html.WithLinkableLineNumbers(true, "L"),
+	html.TabWidth(4),
+)
+
+// handlePreview renders a text file with line numbers & syntax highlighting, and
+// Markdown as HTML unless its source is asked for. Files over the max preview
+// size, or that aren't text, offer a download instead.
+func handlePreview(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	pathCfg := mount.Config
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		http.Redirect(w, r, browseURL(pathCfg.Name, relPath), http.StatusFound)
+		return
+	}
+
+	maxSize := getServerCtx(r).Config.Preview.MaxSize
+	page := previewPage{
+		PathName: pathCfg.Name,
+		Path:     relPath,
+		Name:     path.Base(relPath),
+		Size:     info.Size(),
+		MaxSize:  maxSize,
+		TooLarge: info.Size() > maxSize,
+		Source:   r.URL.Query().Has("source"),
+	}
+
+	var data []byte
+	if !page.TooLarge {
+		data, err = readText(mount.Backend, relPath, maxSize+1)
+		if err != nil {
+			renderPathError(w, r, err)
+			return
+		}
+		// Files may have grown since they were stat'ed
+		page.TooLarge = int64(len(data)) > maxSize
+		page.Binary = !page.TooLarge && !search.IsText(data)
+	}
+
+	switch {
+	case page.TooLarge, page.Binary:
+	case isMarkdown(relPath) && !page.Source:
+		page.Markdown, err = renderMarkdown(data, pathCfg.Name, relPath)
+	default:
+		page.Code, page.Language, err = highlight(relPath, data)
+	}
+	if err != nil {
+		getServerCtx(r).Logger.Error().Msg("failed to render " + r.URL.Path + ": " + err.Error())
+		renderError(w, r, http.StatusInternalServerError, "The file could not be rendered.")
+		return
+	}
+
+	renderPage(w, r, http.StatusOK, "preview.html", pageData{
+		Title:  pathCrumbTitle(pathCfg.Name, relPath),
+		Crumbs: pathCrumbs(pathCfg.Name, relPath),
+		Data:   page,
+	})
+}
+
+// handleHighlightCSS serves the stylesheet of the highlighting classes, switching
+// style along with the color scheme
+func handleHighlightCSS(w http.ResponseWriter, r *http.Request) {
+	css, err := highlightCSS()
+	if err != nil {
+		getServerCtx(r).Logger.Error().Msg("failed to generate highlighting styles: " + err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	_, _ = w.Write(css)
+}
+
+// Preview helpers
+
+// highlight renders text as HTML with line numbers, picking the language by file
+// name or by the content itself
+func highlight(relPath string, data []byte) (template.HTML, string, error) {
+	lexer := lexers.Match(path.Base(relPath))
+	if lexer == nil {
+		lexer = lexers.Analyse(string(data))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, string(data))
+	if err != nil {
+		return "", "", err
+	}
+	var buf bytes.Buffer
+	if err := highlighter.Format(&buf, styles.Get(highlightStyleLight), iterator); err != nil {
+		return "", "", err
+	}
+	return template.HTML(buf.String()), lexer.Config().Name, nil
+}
+
+var highlightCSS = sync.OnceValues(func() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := highlighter.WriteCSS(&buf, styles.Get(highlightStyleLight)); err != nil {
+		return nil, err
+	}
+	buf.WriteString("@media (prefers-color-scheme: dark) {\n")
+	if err := highlighter.WriteCSS(&buf, styles.Get(highlightStyleDark)); err != nil {
+		return nil, err
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes(), nil
+})
+
+// canPreview guesses from a file name whether it's text worth previewing
+func canPreview(name string) bool {
+	if mediaType := mime.TypeByExtension(strings.ToLower(path.Ext(name))); strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	return lexers.Match(name) != nil
+}
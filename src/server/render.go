@@ -0,0 +1,291 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/helpers"
+	"github.com/patppuccin/viewr/src/include"
+	"github.com/patppuccin/viewr/src/thumbs"
+)
+
+// Page templates, keyed by file name (e.g. "browse.html")
+var pageTemplates map[string]*template.Template
+
+type crumb struct {
+	Label string
+	URL   string
+}
+
+type pageData struct {
+	Title  string
+	Crumbs []crumb
+	Data   any
+}
+
+type errorPage struct {
+	Status     int
+	StatusText string
+	Message    string
+}
+
+var templateFuncs = template.FuncMap{
+	"appName":     func() string { return constants.AppFullName },
+	"titleSuffix": func() string { return constants.SEOPageTitleSuffix },
+	"description": func() string { return constants.SEOPageDescription },
+	"robots":      func() string { return constants.SEORobotsDirective },
+	"faviconURL":  func() string { return constants.AssetFaviconURL },
+	"browseURL":   browseURL,
+	"rawURL":      rawURL,
+	"downloadURL": downloadURL,
+	"previewURL":  previewURL,
+	"openURL":     openURL,
+	"tableURL":    tableURL,
+	"dataURL":     dataURL,
+	"hexURL":      hexURL,
+	"playURL":     playURL,
+	"playlistURL": playlistURL,
+	"feedURL":     feedURL,
+	"opdsURL":     opdsURL,
+	"thumbURL":    thumbURL,
+	"galleryURL":  galleryURL,
+	"metaURL":     metaURL,
+	"hasThumb":    thumbs.Supported,
+	"archiveURL":  archiveURL,
+	"batchURL":    batchURL,
+	"canPreview":  canPreview,
+	"canPlay":     canPlay,
+	"isTable":     isTable,
+	"isData":      isData,
+	"parentPath":  parentPath,
+	"formatSize":  formatSize,
+	"formatTime":  formatTime,
+}
+
+func loadTemplates() error {
+	pages, err := fs.Glob(include.Templates, "templates/*.html")
+	if err != nil {
+		return helpers.SafeErr("failed to locate embedded templates", err)
+	}
+
+	pageTemplates = make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		name := path.Base(page)
+		if name == "base.html" {
+			continue
+		}
+		tmpl, err := template.New(name).Funcs(templateFuncs).ParseFS(include.Templates, "templates/base.html", page)
+		if err != nil {
+			return helpers.SafeErr("failed to parse template "+name, err)
+		}
+		pageTemplates[name] = tmpl
+	}
+
+	return nil
+}
+
+func renderPage(w http.ResponseWriter, r *http.Request, status int, page string, data pageData) {
+	tmpl, ok := pageTemplates[page]
+	if !ok {
+		http.Error(w, "template not found: "+page, http.StatusInternalServerError)
+		return
+	}
+
+	// Render into a buffer first so template errors don't leave half-written pages
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "base", data); err != nil {
+		if serverCtx := getServerCtx(r); serverCtx != nil {
+			serverCtx.Logger.Error().Msg("failed to render " + page + ": " + err.Error())
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	renderPage(w, r, status, "error.html", pageData{
+		Title: http.StatusText(status),
+		Data: errorPage{
+			Status:     status,
+			StatusText: http.StatusText(status),
+			Message:    message,
+		},
+	})
+}
+
+// Template helpers
+
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func browseURL(pathName, p string) string {
+	u := "/browse/" + url.PathEscape(pathName)
+	if p = strings.Trim(p, "/"); p != "" {
+		u += "/" + escapePath(p)
+	}
+	return u
+}
+
+func rawURL(pathName, p string) string {
+	return "/raw/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/"))
+}
+
+func downloadURL(pathName, p string) string {
+	return rawURL(pathName, p) + "?download"
+}
+
+// previewURL links to the preview of a text file, at a line when line > 0
+func previewURL(pathName, p string, line int) string {
+	u := "/preview/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/"))
+	if line > 0 {
+		u += "#L" + strconv.Itoa(line)
+	}
+	return u
+}
+
+func tableURL(pathName, p string) string {
+	return "/table/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/"))
+}
+
+func dataURL(pathName, p string) string {
+	return "/data/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/"))
+}
+
+func hexURL(pathName, p string) string {
+	return "/hex/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/"))
+}
+
+// openURL links to the page showing a file best: its player, table, tree or
+// preview, the raw file for what browsers show themselves & hex for the rest.
+// The hex viewer sends files that turn out to be text on to their preview.
+func openURL(pathName, p string) string {
+	switch {
+	case canPlay(p):
+		return playURL(pathName, p)
+	case isTable(p):
+		return tableURL(pathName, p)
+	case isData(p):
+		return dataURL(pathName, p)
+	case canPreview(p):
+		return previewURL(pathName, p, 0)
+	case opensInline(p):
+		return rawURL(pathName, p)
+	}
+	return hexURL(pathName, p) + "?open"
+}
+
+func playURL(pathName, p string) string {
+	return "/play/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/"))
+}
+
+func subtitleURL(pathName, p string) string {
+	return "/subtitles/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/"))
+}
+
+func playlistURL(pathName, p string) string {
+	u := "/playlist/" + url.PathEscape(pathName)
+	if p = strings.Trim(p, "/"); p != "" {
+		u += "/" + escapePath(p)
+	}
+	return u
+}
+
+func feedURL(pathName, p string) string {
+	u := "/feed/" + url.PathEscape(pathName)
+	if p = strings.Trim(p, "/"); p != "" {
+		u += "/" + escapePath(p)
+	}
+	return u
+}
+
+func opdsURL(pathName, p string) string {
+	u := "/opds/" + url.PathEscape(pathName)
+	if p = strings.Trim(p, "/"); p != "" {
+		u += "/" + escapePath(p)
+	}
+	return u
+}
+
+func coverURL(pathName, p string) string {
+	return "/cover/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/"))
+}
+
+// thumbURL carries the image's modification time, so browsers can keep the
+// thumbnail until the image changes
+func thumbURL(pathName, p string, size int, modTime time.Time) string {
+	u := "/thumb/" + url.PathEscape(pathName) + "/" + escapePath(strings.Trim(p, "/")) + "?size=" + strconv.Itoa(size)
+	if !modTime.IsZero() {
+		u += "&v=" + strconv.FormatInt(modTime.UnixNano(), 36)
+	}
+	return u
+}
+
+func galleryURL(pathName, p string) string {
+	u := "/gallery/" + url.PathEscape(pathName)
+	if p = strings.Trim(p, "/"); p != "" {
+		u += "/" + escapePath(p)
+	}
+	return u
+}
+
+func metaURL(pathName, p string) string {
+	return "/api/v1/paths/" + url.PathEscape(pathName) + "/meta?path=" + url.QueryEscape(strings.Trim(p, "/"))
+}
+
+func archiveURL(pathName, p, format string) string {
+	u := "/archive/" + url.PathEscape(pathName)
+	if p = strings.Trim(p, "/"); p != "" {
+		u += "/" + escapePath(p)
+	}
+	return u + "?format=" + url.QueryEscape(format)
+}
+
+func batchURL(pathName string) string {
+	return "/batch/" + url.PathEscape(pathName)
+}
+
+func parentPath(p string) string {
+	parent := path.Dir(strings.Trim(p, "/"))
+	if parent == "." {
+		return ""
+	}
+	return parent
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return strconv.FormatInt(size, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	value := float64(size) / float64(div)
+	return strconv.FormatFloat(value, 'f', 1, 64) + " " + string("KMGTPE"[exp]) + "iB"
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
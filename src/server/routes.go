@@ -9,15 +9,19 @@ import (
 	"github.com/patppuccin/viewr/src/helpers"
 	"github.com/patppuccin/viewr/src/include"
 	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/patppuccin/viewr/src/thumbs"
 )
 
-func setupRoutes(serverCtx *models.AppContext) (http.Handler, error) {
+func setupRoutes(serverCtx *models.AppContext, registry *storage.Registry, thumbCache *thumbs.Cache) (http.Handler, error) {
 
 	// Initialize router
 	r := chi.NewRouter()
 
 	// Add custom middlewares
 	r.Use(loadServerContext(serverCtx))
+	r.Use(loadStorage(registry))
+	r.Use(loadThumbs(thumbCache))
 	r.Use(logRequest())
 
 	// Add chi middlewares
@@ -26,17 +30,24 @@ func setupRoutes(serverCtx *models.AppContext) (http.Handler, error) {
 	r.Use(middleware.RealIP)
 	r.Use(middleware.StripSlashes)
 	r.Use(middleware.RedirectSlashes)
-	r.Use(middleware.Compress(5,
+	r.Use(preventCSRF())
+
+	// Compression for pages, API & assets only; raw file responses must stay
+	// uncompressed so that Range offsets & Content-Length match the file on disk
+	compress := middleware.Compress(5,
 		"text/html",
 		"text/css",
 		"application/javascript",
 		"application/json",
 		"application/wasm",
 		"application/xml",
+		"application/rss+xml",
+		"application/atom+xml",
+		"audio/x-mpegurl",
 		"text/plain",
 		"text/javascript",
 		"image/svg+xml",
-	))
+	)
 
 	// Mount Assets as a Static Route (using embedded assets)
 	assetsRootFS, err := fs.Sub(include.Assets, "assets")
@@ -56,9 +67,69 @@ func setupRoutes(serverCtx *models.AppContext) (http.Handler, error) {
 	})
 
 	// Strip "/assets/" prefix for proper path resolution
-	r.Handle("/assets/*", http.StripPrefix("/assets/", assetsHandler))
+	r.With(compress).Handle("/assets/*", http.StripPrefix("/assets/", assetsHandler))
+	r.With(compress).Get("/assets/styles/highlight.css", handleHighlightCSS)
+
+	// Mount Page Route Handlers
+	if err := loadTemplates(); err != nil {
+		return r, err
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(compress)
+		r.Get("/", handleIndex)
+		r.Get("/search", handleSearch)
+		r.Get("/browse/{pathName}", handleBrowse)
+		r.Get("/browse/{pathName}/*", handleBrowse)
+		r.Get("/preview/{pathName}/*", handlePreview)
+		r.Get("/table/{pathName}/*", handleTable)
+		r.Get("/data/{pathName}/*", handleData)
+		r.Get("/hex/{pathName}/*", handleHex)
+		r.Get("/play/{pathName}/*", handlePlay)
+		r.Get("/subtitles/{pathName}/*", handleSubtitles)
+		r.Get("/playlist/{pathName}", handlePlaylist)
+		r.Get("/playlist/{pathName}/*", handlePlaylist)
+		r.Get("/feed/{pathName}", handleFeed)
+		r.Get("/feed/{pathName}/*", handleFeed)
+		r.Get("/opds", handleOPDSRoot)
+		r.Get("/opds/{pathName}", handleOPDS)
+		r.Get("/opds/{pathName}/*", handleOPDS)
+		r.Get("/gallery/{pathName}", handleGallery)
+		r.Get("/gallery/{pathName}/*", handleGallery)
+	})
+
+	// Mount Media Route Handlers (uncompressed)
+	r.Get("/raw/{pathName}/*", handleRaw)
+	r.Head("/raw/{pathName}/*", handleRaw)
+	r.Get("/thumb/{pathName}/*", handleThumb)
+	r.Get("/cover/{pathName}/*", handleCover)
+	r.Get("/archive/{pathName}", handleArchive)
+	r.Get("/archive/{pathName}/*", handleArchive)
+	r.Post("/batch/{pathName}", handleBatch)
 
-	// TODO: Mount Page & Media Route Handlers
+	// Mount JSON API Route Handlers
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(compress)
+			r.Get("/paths", handleAPIPaths)
+			r.Get("/paths/{name}/list", handleAPIList)
+			r.Get("/paths/{name}/meta", handleAPIMeta)
+			r.Get("/paths/{name}/table", handleAPITable)
+			r.Post("/paths/{name}/batch", handleAPIBatch)
+			r.Get("/search", handleAPISearch)
+		})
+
+		// Streamed, so left uncompressed to reach the client line by line
+		r.Get("/paths/{name}/grep", handleAPIGrep)
+
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			writeJSONError(w, http.StatusNotFound, "endpoint not found")
+		})
+	})
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		renderError(w, r, http.StatusNotFound, "The requested page does not exist.")
+	})
 
 	// Return router
 	return r, nil
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/patppuccin/viewr/src/thumbs"
+	"github.com/rs/zerolog"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	logger := zerolog.Nop()
+	thumbCache, err := thumbs.New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCtx := &models.AppContext{
+		Config: &models.AppConfig{
+			Archive: models.ArchiveConfig{MaxBytes: 1 << 20, MaxFiles: 100},
+			Preview: models.PreviewConfig{MaxSize: 64},
+			Gallery: models.GalleryConfig{SlideshowInterval: 5 * time.Second},
+		},
+		Logger: &logger,
+	}
+	registry := storage.NewRegistryFrom(&storage.Mount{
+		Config: models.PathConfig{Name: "Test Share"},
+		Backend: storage.WithArchives(storage.FromFS(fstest.MapFS{
+			"docs/readme.md":      {Data: []byte("# Readme\n")},
+			"docs/notes.txt":      {Data: []byte("0123456789")},
+			"docs/100%2e.txt":     {Data: []byte("percent")},
+			"docs/report.csv":     {Data: []byte("id;name\n1;apple\n2;Banana\n3;cherry\n")},
+			"docs/config.json":    {Data: []byte(`{"server":{"port":8080},"tags":["a b"]}`)},
+			"media/clip.webm":     {Data: []byte("webm")},
+			"media/photo.png":     {Data: testPNG(t, 300, 200), ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			"releases/bundle.zip": {Data: testZip(t, map[string]string{"bin/tool": "0123456789"})},
+			"releases/LICENSE":    {Data: []byte("Permission is hereby granted, free of charge\n")},
+			"releases/tool.bin":   {Data: []byte("\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00")},
+			"media/shows/e01.mp4": {Data: []byte("mp4")},
+			"media/shows/e02.mkv": {Data: []byte("mkv")},
+			"media/books/guide.epub": {Data: testZip(t, map[string]string{
+				"META-INF/container.xml": `<container><rootfiles><rootfile full-path="book.opf"/></rootfiles></container>`,
+				"book.opf":               `<package version="3.0"><metadata><title>Field Guide</title><creator>Ada</creator></metadata><manifest><item id="c" href="cover.png" media-type="image/png" properties="cover-image"/></manifest></package>`,
+				"cover.png":              "\x89PNG",
+			})},
+			"media/books/manual.pdf": {Data: []byte("%PDF-1.7")},
+			"media/album/a.mp3":      {Data: testMP3("Second", "2/3")},
+			"media/album/b.mp3":      {Data: testMP3("First", "1/3")},
+			"media/album/c.ogg":      {Data: []byte("ogg")},
+			"media/shows/e02.en.srt": {Data: []byte("1\r\n00:00:01,000 --> 00:00:02,500\r\nHi\r\n")},
+		})),
+	})
+
+	router, err := setupRoutes(serverCtx, registry, thumbCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRoutes(t *testing.T) {
+	srv := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		header     map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "index lists paths", path: "/", wantStatus: http.StatusOK, wantBody: "Test Share"},
+		{name: "browse folder", path: "/browse/Test%20Share/docs", wantStatus: http.StatusOK, wantBody: "notes.txt"},
+		{name: "browse shows readme", path: "/browse/Test%20Share/docs", wantStatus: http.StatusOK, wantBody: `<article class="markdown readme"><h1 id="readme">Readme</h1>`},
+		{name: "browse file opens preview", path: "/browse/Test%20Share/docs/notes.txt", wantStatus: http.StatusOK, wantBody: `<span class="ln" id="L1">`},
+		{name: "preview markdown", path: "/preview/Test%20Share/docs/readme.md", wantStatus: http.StatusOK, wantBody: `<h1 id="readme">Readme</h1>`},
+		{name: "browse grid", path: "/browse/Test%20Share/media?view=grid", wantStatus: http.StatusOK, wantBody: `src="/thumb/Test%20Share/media/photo.png?size=256&amp;v=cy3vy63hrojk"`},
+		{name: "thumbnail", path: "/thumb/Test%20Share/media/photo.png?size=128", wantStatus: http.StatusOK, wantBody: "\xff\xd8"},
+		{name: "thumbnail of text", path: "/thumb/Test%20Share/docs/notes.txt", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "thumbnail odd size", path: "/thumb/Test%20Share/media/photo.png?size=100", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "browse offers gallery", path: "/browse/Test%20Share/media", wantStatus: http.StatusOK, wantBody: `href="/gallery/Test%20Share/media"`},
+		{name: "gallery", path: "/gallery/Test%20Share/media", wantStatus: http.StatusOK, wantBody: `data-meta="/api/v1/paths/Test%20Share/meta?path=media%2Fphoto.png"`},
+		{name: "gallery interval", path: "/gallery/Test%20Share/media?interval=7", wantStatus: http.StatusOK, wantBody: `<option value="7" selected>7s</option>`},
+		{name: "gallery of file", path: "/gallery/Test%20Share/docs/notes.txt", wantStatus: http.StatusOK, wantBody: `<span class="ln" id="L1">`},
+		{name: "api meta", path: "/api/v1/paths/Test%20Share/meta?path=media/photo.png", wantStatus: http.StatusOK, wantBody: `"format":"png"`},
+		{name: "browse media opens player", path: "/browse/Test%20Share/media/shows/e01.mp4", wantStatus: http.StatusOK, wantBody: `<source src="/raw/Test%20Share/media/shows/e01.mp4" type="video/mp4">`},
+		{name: "player next", path: "/play/Test%20Share/media/shows/e01.mp4", wantStatus: http.StatusOK, wantBody: `href="/play/Test%20Share/media/shows/e02.mkv" rel="next"`},
+		{name: "player subtitles", path: "/play/Test%20Share/media/shows/e02.mkv", wantStatus: http.StatusOK, wantBody: `<track kind="subtitles" src="/subtitles/Test%20Share/media/shows/e02.en.srt" label="en" srclang="en">`},
+		{name: "player of text", path: "/play/Test%20Share/docs/notes.txt", wantStatus: http.StatusOK, wantBody: `<span class="ln" id="L1">`},
+		{name: "subtitles from srt", path: "/subtitles/Test%20Share/media/shows/e02.en.srt", wantStatus: http.StatusOK, wantBody: "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.500\nHi\n"},
+		{name: "subtitles of text", path: "/subtitles/Test%20Share/docs/notes.txt", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "browse offers playlist", path: "/browse/Test%20Share/media/album", wantStatus: http.StatusOK, wantBody: `href="/feed/Test%20Share/media/album"`},
+		{name: "playlist", path: "/playlist/Test%20Share/media/album", wantStatus: http.StatusOK, wantBody: "#EXTINF:-1,Band - First\nhttp://127.0.0.1"},
+		{name: "playlist order", path: "/playlist/Test%20Share/media/album", wantStatus: http.StatusOK, wantBody: "/raw/Test%20Share/media/album/a.mp3\n#EXTINF:-1,c\n"},
+		{name: "feed", path: "/feed/Test%20Share/media/album", wantStatus: http.StatusOK, wantBody: "<title>Second</title>\n      <enclosure url=\"http://127.0.0.1"},
+		{name: "feed of file", path: "/feed/Test%20Share/media/album/a.mp3", wantStatus: http.StatusBadRequest},
+		{name: "browse offers opds", path: "/browse/Test%20Share/media/books", wantStatus: http.StatusOK, wantBody: `href="/opds/Test%20Share/media/books"`},
+		{name: "opds root", path: "/opds", wantStatus: http.StatusOK, wantBody: `<link rel="subsection" href="/opds/Test%20Share" type="application/atom+xml;profile=opds-catalog;kind=navigation">`},
+		{name: "opds folder", path: "/opds/Test%20Share/media", wantStatus: http.StatusOK, wantBody: `<link rel="subsection" href="/opds/Test%20Share/media/books"`},
+		{name: "opds books", path: "/opds/Test%20Share/media/books", wantStatus: http.StatusOK, wantBody: "<title>Field Guide</title>"},
+		{name: "opds cover link", path: "/opds/Test%20Share/media/books", wantStatus: http.StatusOK, wantBody: `<link rel="http://opds-spec.org/image" href="/cover/Test%20Share/media/books/guide.epub" type="image/png">`},
+		{name: "opds acquisition", path: "/opds/Test%20Share/media/books", wantStatus: http.StatusOK, wantBody: `<link rel="http://opds-spec.org/acquisition" href="/raw/Test%20Share/media/books/manual.pdf" type="application/pdf" length="8">`},
+		{name: "epub cover", path: "/cover/Test%20Share/media/books/guide.epub", wantStatus: http.StatusOK, wantBody: "\x89PNG"},
+		{name: "cover of pdf", path: "/cover/Test%20Share/media/books/manual.pdf", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "browse table opens table", path: "/browse/Test%20Share/docs/report.csv", wantStatus: http.StatusOK, wantBody: `<option value="semicolon" selected>`},
+		{name: "table sort link", path: "/table/Test%20Share/docs/report.csv?sort=1", wantStatus: http.StatusOK, wantBody: `<a href="?order=desc&amp;sort=1" title="Sort this page">name ▲</a>`},
+		{name: "table invalid limit", path: "/table/Test%20Share/docs/report.csv?limit=lots", wantStatus: http.StatusBadRequest},
+		{name: "browse data opens tree", path: "/browse/Test%20Share/docs/config.json", wantStatus: http.StatusOK, wantBody: `<span class="key">port</span><span class="value number">8080</span><button type="button" class="copy-path" data-path=".server.port"`},
+		{name: "data pretty", path: "/data/Test%20Share/docs/config.json?view=pretty", wantStatus: http.StatusOK, wantBody: `<a class="lnlinks" href="#L8">8</a>`},
+		{name: "data invalid view", path: "/data/Test%20Share/docs/config.json?view=table", wantStatus: http.StatusBadRequest},
+		{name: "browse binary opens hex", path: "/browse/Test%20Share/releases/tool.bin", wantStatus: http.StatusOK, wantBody: `probably ELF executable`},
+		{name: "browse extensionless text opens preview", path: "/browse/Test%20Share/releases/LICENSE", wantStatus: http.StatusOK, wantBody: `<span class="ln" id="L1">`},
+		{name: "hex of text", path: "/hex/Test%20Share/releases/LICENSE", wantStatus: http.StatusOK, wantBody: `probably Text`},
+		{name: "hex jump", path: "/hex/Test%20Share/releases/tool.bin?offset=0x11", wantStatus: http.StatusOK, wantBody: `<span class="control">02</span><span class="zero target">00</span><span class="text">3e</span>`},
+		{name: "hex invalid offset", path: "/hex/Test%20Share/releases/tool.bin?offset=-1", wantStatus: http.StatusBadRequest},
+		{name: "api table page", path: "/api/v1/paths/Test%20Share/table?path=docs/report.csv&limit=2&page=2", wantStatus: http.StatusOK, wantBody: `"header":["id","name"],"rows":[["3","cherry"]],"page":2,"limit":2,"hasMore":false,"totalRows":3`},
+		{name: "api table sorted", path: "/api/v1/paths/Test%20Share/table?path=docs/report.csv&sort=1&order=desc", wantStatus: http.StatusOK, wantBody: `"rows":[["3","cherry"],["2","Banana"],["1","apple"]]`},
+		{name: "api table of binary", path: "/api/v1/paths/Test%20Share/table?path=media/photo.png", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "api meta folder", path: "/api/v1/paths/Test%20Share/meta?path=media", wantStatus: http.StatusBadRequest},
+		{name: "browse unknown path", path: "/browse/Other", wantStatus: http.StatusNotFound},
+		{name: "browse missing folder", path: "/browse/Test%20Share/nope", wantStatus: http.StatusNotFound},
+		{name: "browse encoded traversal", path: "/browse/Test%20Share/..%2F..%2Fetc", wantStatus: http.StatusBadRequest},
+		{name: "raw encoded separator", path: "/raw/Test%20Share/docs%2Fnotes.txt", wantStatus: http.StatusBadRequest},
+		{name: "raw name with percent sign", path: "/raw/Test%20Share/docs/100%252e.txt", wantStatus: http.StatusOK, wantBody: "percent"},
+		{name: "raw file", path: "/raw/Test%20Share/docs/notes.txt", wantStatus: http.StatusOK, wantBody: "0123456789"},
+		{name: "raw range", path: "/raw/Test%20Share/docs/notes.txt", header: map[string]string{"Range": "bytes=2-4"}, wantStatus: http.StatusPartialContent, wantBody: "234"},
+		{name: "preview text", path: "/preview/Test%20Share/docs/readme.md?source", wantStatus: http.StatusOK, wantBody: `<span class="ln" id="L1">`},
+		{name: "preview too large", path: "/preview/Test%20Share/releases/bundle.zip", wantStatus: http.StatusOK, wantBody: "preview limit"},
+		{name: "preview folder", path: "/preview/Test%20Share/docs", wantStatus: http.StatusOK, wantBody: "notes.txt"},
+		{name: "highlight styles", path: "/assets/styles/highlight.css", wantStatus: http.StatusOK, wantBody: "prefers-color-scheme: dark"},
+		{name: "browse archive", path: "/browse/Test%20Share/releases/bundle.zip", wantStatus: http.StatusOK, wantBody: "bin/"},
+		{name: "browse archive folder", path: "/browse/Test%20Share/releases/bundle.zip/bin", wantStatus: http.StatusOK, wantBody: "tool"},
+		{name: "raw archive member", path: "/raw/Test%20Share/releases/bundle.zip/bin/tool", header: map[string]string{"Range": "bytes=7-"}, wantStatus: http.StatusPartialContent, wantBody: "789"},
+		{name: "raw missing member", path: "/raw/Test%20Share/releases/bundle.zip/nope", wantStatus: http.StatusNotFound},
+		{name: "api paths", path: "/api/v1/paths", wantStatus: http.StatusOK, wantBody: `"name":"Test Share"`},
+		{name: "api list", path: "/api/v1/paths/Test%20Share/list?path=docs", wantStatus: http.StatusOK, wantBody: `"path":"docs/readme.md"`},
+		{name: "api list traversal", path: "/api/v1/paths/Test%20Share/list?path=../docs", wantStatus: http.StatusForbidden},
+		{name: "search page", path: "/search", wantStatus: http.StatusOK, wantBody: "All paths"},
+		{name: "search invalid filter", path: "/api/v1/search?q=notes&minSize=lots", wantStatus: http.StatusBadRequest},
+		{name: "search without index", path: "/api/v1/search?q=notes", wantStatus: http.StatusServiceUnavailable},
+		{name: "api grep ndjson", path: "/api/v1/paths/Test%20Share/grep?path=docs&pattern=readme&ignoreCase=1", wantStatus: http.StatusOK, wantBody: `{"file":"docs/readme.md","line":1,"text":"# Readme"}`},
+		{name: "api grep sse", path: "/api/v1/paths/Test%20Share/grep?pattern=345&format=sse", wantStatus: http.StatusOK, wantBody: "event: done\ndata: {\"done\":true,"},
+		{name: "api grep sse matches", path: "/api/v1/paths/Test%20Share/grep?pattern=345&format=sse", wantStatus: http.StatusOK, wantBody: `"matches":1,"truncated":false}`},
+		{name: "api grep invalid regex", path: "/api/v1/paths/Test%20Share/grep?pattern=(&regex=1", wantStatus: http.StatusBadRequest},
+		{name: "api grep missing folder", path: "/api/v1/paths/Test%20Share/grep?path=nope&pattern=x", wantStatus: http.StatusNotFound},
+		{name: "api list absolute", path: "/api/v1/paths/Test%20Share/list?path=/etc", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("GET %s status = %d, want %d", tt.path, resp.StatusCode, tt.wantStatus)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Fatalf("GET %s body does not contain %q:\n%s", tt.path, tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestThumbCaching(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Versioned thumbnails are kept for good, others are revalidated
+	for query, want := range map[string]string{
+		"size=256&v=cy3vy63hrojk": "public, max-age=31536000, immutable",
+		"size=256":                "private, no-cache",
+	} {
+		resp, err := http.Get(srv.URL + "/thumb/Test%20Share/media/photo.png?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if got := resp.Header.Get("Cache-Control"); resp.StatusCode != http.StatusOK || got != want {
+			t.Fatalf("GET thumb?%s = %d, Cache-Control %q, want %q", query, resp.StatusCode, got, want)
+		}
+		if resp.Header.Get("ETag") == "" {
+			t.Fatalf("GET thumb?%s has no ETag", query)
+		}
+	}
+}
+
+func TestAPIListEntries(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/api/v1/paths/Test%20Share/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var listing apiListing
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Entries) != 3 || !listing.Entries[0].IsDir || listing.Entries[0].Name != "docs" {
+		t.Fatalf("entries = %+v, want folders docs, media & releases", listing.Entries)
+	}
+}
+
+// Test helpers
+
+func testZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testMP3 is an ID3v2.3 tag with a title, artist & track, & no audio
+func testMP3(title, track string) []byte {
+	var frames []byte
+	for _, frame := range [][2]string{{"TIT2", title}, {"TPE1", "Band"}, {"TRCK", track}} {
+		size := len(frame[1]) + 1
+		frames = append(frames, frame[0]...)
+		frames = append(frames, byte(size>>24), byte(size>>16), byte(size>>8), byte(size), 0, 0, 0)
+		frames = append(frames, frame[1]...)
+	}
+	size := len(frames)
+	header := []byte{'I', 'D', '3', 3, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, frames...)
+}
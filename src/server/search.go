@@ -0,0 +1,412 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/search"
+	"github.com/patppuccin/viewr/src/storage"
+)
+
+var (
+	errInvalidQuery  = errors.New("invalid search query")
+	errInvalidFilter = errors.New("invalid search filter")
+	errUnknownPath   = errors.New("unknown path name")
+)
+
+type searchPage struct {
+	Query     string
+	Mode      string
+	Scope     string
+	PathName  string
+	Filters   searchFilters
+	Paths     []string
+	Results   []searchResult
+	Truncated bool
+	Building  bool // Some paths haven't finished their first build yet
+	Error     string
+}
+
+// searchFilters echoes the filter params back into the search form
+type searchFilters struct {
+	MinSize  string
+	MaxSize  string
+	After    string
+	Before   string
+	Ext      string
+	Type     string
+	DirsOnly bool
+	Sort     string
+	Order    string
+	Active   bool // Any filter is set, so the search runs even without a query
+}
+
+type searchResult struct {
+	PathName string `json:"pathName"`
+	models.FileEntry
+	Matches []search.Line `json:"matches,omitempty"` // Matching lines, for content searches
+}
+
+type apiSearchResults struct {
+	Query     string                   `json:"query"`
+	Mode      string                   `json:"mode"`
+	Scope     string                   `json:"scope"`
+	Results   []searchResult           `json:"results"`
+	Truncated bool                     `json:"truncated"`
+	Status    map[string]search.Status `json:"status"`
+}
+
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	page := searchPage{
+		Query:    r.URL.Query().Get("q"),
+		Mode:     r.URL.Query().Get("mode"),
+		Scope:    r.URL.Query().Get("scope"),
+		PathName: r.URL.Query().Get("path"),
+		Filters:  readSearchFilters(r),
+	}
+	for _, mount := range getStorage(r).List() {
+		page.Paths = append(page.Paths, mount.Config.Name)
+	}
+
+	index := search.New(getServerCtx(r).DBConn)
+	if statuses, err := index.Status(); err == nil {
+		page.Building = isBuilding(page.Paths, statuses)
+	}
+
+	status := http.StatusOK
+	if page.Query != "" || page.Filters.Active {
+		results, truncated, err := runSearch(r, index)
+		switch {
+		case errors.Is(err, search.ErrInvalidPattern), errors.Is(err, errInvalidQuery):
+			status, page.Error = http.StatusBadRequest, "The search query is invalid."
+		case errors.Is(err, errInvalidFilter):
+			status, page.Error = http.StatusBadRequest, "The search filters are invalid."
+		case errors.Is(err, errUnknownPath):
+			status, page.Error = http.StatusNotFound, "The selected path does not exist."
+		case errors.Is(err, search.ErrUnavailable):
+			status, page.Error = http.StatusServiceUnavailable, "Search is not available right now."
+		case err != nil:
+			getServerCtx(r).Logger.Error().Msg("search failed: " + err.Error())
+			status, page.Error = http.StatusInternalServerError, "The search could not be completed."
+		}
+		page.Results, page.Truncated = results, truncated
+	}
+
+	renderPage(w, r, status, "search.html", pageData{
+		Title:  "Search",
+		Crumbs: []crumb{{Label: "Home", URL: "/"}, {Label: "Search"}},
+		Data:   page,
+	})
+}
+
+func handleAPISearch(w http.ResponseWriter, r *http.Request) {
+	index := search.New(getServerCtx(r).DBConn)
+
+	results, truncated, err := runSearch(r, index)
+	switch {
+	case errors.Is(err, search.ErrInvalidPattern), errors.Is(err, errInvalidQuery):
+		writeJSONError(w, http.StatusBadRequest, "invalid search query")
+		return
+	case errors.Is(err, errInvalidFilter):
+		writeJSONError(w, http.StatusBadRequest, "invalid search filter")
+		return
+	case errors.Is(err, errUnknownPath):
+		writeJSONError(w, http.StatusNotFound, "path not found")
+		return
+	case errors.Is(err, search.ErrUnavailable):
+		writeJSONError(w, http.StatusServiceUnavailable, "search is unavailable")
+		return
+	case err != nil:
+		getServerCtx(r).Logger.Error().Msg("search failed: " + err.Error())
+		writeJSONError(w, http.StatusInternalServerError, "search failed")
+		return
+	}
+
+	statuses, _ := index.Status()
+	mode, _ := search.ParseMode(r.URL.Query().Get("mode"))
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = scopeNames
+	}
+	if results == nil {
+		results = []searchResult{}
+	}
+	writeJSON(w, http.StatusOK, apiSearchResults{
+		Query:     r.URL.Query().Get("q"),
+		Mode:      string(mode),
+		Scope:     scope,
+		Results:   results,
+		Truncated: truncated,
+		Status:    statuses,
+	})
+}
+
+// Search helpers
+
+// Search scopes: file & folder names, or the text inside indexed documents
+const (
+	scopeNames   = "names"
+	scopeContent = "content"
+)
+
+// Matching lines shown per file in content search results
+const maxMatchesPerResult = 3
+
+// runSearch runs the query described by the request's "q", "scope", "mode",
+// "path" & "limit" params, narrowed down by the filter & sort params (see
+// parseSearchFilter), against the enabled paths
+func runSearch(r *http.Request, index *search.Index) ([]searchResult, bool, error) {
+	if getServerCtx(r).Config.Search.Disable {
+		return nil, false, search.ErrUnavailable
+	}
+	params := r.URL.Query()
+
+	mode, ok := search.ParseMode(params.Get("mode"))
+	if !ok {
+		return nil, false, errInvalidQuery
+	}
+	scope := params.Get("scope")
+	if scope != "" && scope != scopeNames && scope != scopeContent {
+		return nil, false, errInvalidQuery
+	}
+	filter, sort, desc, err := parseSearchFilter(params, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+
+	limit := constants.DefaultSearchLimit
+	if val := params.Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			return nil, false, errInvalidQuery
+		}
+		limit = min(n, constants.MaxSearchLimit)
+	}
+
+	// Only enabled paths are searched, whatever the index still holds
+	registry := getStorage(r)
+	var pathNames []string
+	if pathName := params.Get("path"); pathName != "" {
+		if _, ok := registry.Get(pathName); !ok {
+			return nil, false, errUnknownPath
+		}
+		pathNames = []string{pathName}
+	} else {
+		for _, mount := range registry.List() {
+			pathNames = append(pathNames, mount.Config.Name)
+		}
+	}
+
+	if scope == scopeContent {
+		return runContentSearch(r, index, search.ContentQuery{
+			Text:   params.Get("q"),
+			Filter: filter,
+			Sort:   sort,
+			Desc:   desc,
+			Paths:  pathNames,
+			Limit:  limit,
+		})
+	}
+
+	records, truncated, err := index.Search(r.Context(), search.Query{
+		Text:   params.Get("q"),
+		Mode:   mode,
+		Filter: filter,
+		Sort:   sort,
+		Desc:   desc,
+		Paths:  pathNames,
+		Limit:  limit,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	results := make([]searchResult, 0, len(records))
+	for _, rec := range records {
+		results = append(results, searchResult{PathName: rec.PathName, FileEntry: recordEntry(rec.Record)})
+	}
+	return results, truncated, nil
+}
+
+// runContentSearch looks words up in the content index & reads back the first few
+// matching lines of every result
+func runContentSearch(r *http.Request, index *search.Index, q search.ContentQuery) ([]searchResult, bool, error) {
+	docs, truncated, err := index.SearchContent(r.Context(), q)
+	if err != nil {
+		return nil, false, err
+	}
+
+	registry := getStorage(r)
+	results := make([]searchResult, 0, len(docs))
+	for _, doc := range docs {
+		result := searchResult{PathName: doc.PathName, FileEntry: recordEntry(doc.Record)}
+		if mount, ok := registry.Get(doc.PathName); ok {
+			// Files changed since the last build may have moved lines around, the
+			// result still links to the file
+			lines := doc.Lines[:min(len(doc.Lines), maxMatchesPerResult)]
+			result.Matches, _ = search.ReadLines(mount.Backend, doc.Path, lines)
+		}
+		results = append(results, result)
+	}
+	return results, truncated, nil
+}
+
+// parseSearchFilter reads the "minSize" & "maxSize" (bytes, or like 500M & 1.5GB),
+// "after" & "before" (dates, RFC 3339 times, or ages like 7d & 12h), "ext" (comma
+// separated), "type" (category), "dirs", "sort" & "order" params
+func parseSearchFilter(params url.Values, now time.Time) (filter search.Filter, sort search.SortField, desc bool, err error) {
+	invalid := func(param string) error {
+		return errors.Join(errInvalidFilter, errors.New("invalid "+param))
+	}
+
+	for param, size := range map[string]*int64{"minSize": &filter.MinSize, "maxSize": &filter.MaxSize} {
+		if val := params.Get(param); val != "" {
+			if *size, err = parseSize(val); err != nil {
+				return filter, "", false, invalid(param)
+			}
+		}
+	}
+	for param, bound := range map[string]*time.Time{"after": &filter.ModifiedAfter, "before": &filter.ModifiedBefore} {
+		if val := params.Get(param); val != "" {
+			if *bound, err = parseTimeBound(val, now); err != nil {
+				return filter, "", false, invalid(param)
+			}
+		}
+	}
+
+	for _, val := range params["ext"] {
+		for _, ext := range strings.Split(val, ",") {
+			if ext = strings.ToLower(strings.TrimSpace(ext)); ext != "" {
+				filter.Extensions = append(filter.Extensions, "."+strings.TrimPrefix(ext, "."))
+			}
+		}
+	}
+	if val := params.Get("type"); val != "" {
+		var ok bool
+		if filter.Category, ok = search.ParseCategory(val); !ok {
+			return filter, "", false, invalid("type")
+		}
+	}
+	filter.DirsOnly = isTruthy(params.Get("dirs"))
+
+	sort, ok := search.ParseSortField(params.Get("sort"))
+	if !ok {
+		return filter, "", false, invalid("sort")
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return filter, "", false, invalid("order")
+	}
+	return filter, sort, desc, nil
+}
+
+// parseSize reads a byte count with an optional binary unit, "1G" & "1 GB" alike
+func parseSize(val string) (int64, error) {
+	val = strings.ToUpper(strings.TrimSpace(val))
+	val = strings.TrimSuffix(strings.TrimSuffix(val, "B"), "I")
+
+	multiplier := int64(1)
+	if i := strings.IndexAny(val, "KMGT"); i >= 0 && i == len(val)-1 {
+		multiplier = 1 << (10 * (strings.IndexByte("KMGT", val[i]) + 1))
+		val = strings.TrimSpace(val[:i])
+	}
+
+	n, err := strconv.ParseFloat(val, 64)
+	if err != nil || n < 0 || n*float64(multiplier) > math.MaxInt64 {
+		return 0, errInvalidFilter
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// parseTimeBound reads a date, an RFC 3339 time or an age before now
+func parseTimeBound(val string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, val, now.Location()); err == nil {
+		return t, nil
+	}
+
+	// Ages in days & weeks on top of what time.ParseDuration knows
+	days := 0
+	if n, ok := strings.CutSuffix(val, "d"); ok {
+		days, val = 1, n
+	} else if n, ok := strings.CutSuffix(val, "w"); ok {
+		days, val = 7, n
+	}
+	if days > 0 {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return time.Time{}, errInvalidFilter
+		}
+		return now.AddDate(0, 0, -n*days), nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil || d < 0 {
+		return time.Time{}, errInvalidFilter
+	}
+	return now.Add(-d), nil
+}
+
+// readSearchFilters collects the raw filter params for the search form
+func readSearchFilters(r *http.Request) searchFilters {
+	params := r.URL.Query()
+	f := searchFilters{
+		MinSize:  params.Get("minSize"),
+		MaxSize:  params.Get("maxSize"),
+		After:    params.Get("after"),
+		Before:   params.Get("before"),
+		Ext:      params.Get("ext"),
+		Type:     params.Get("type"),
+		DirsOnly: isTruthy(params.Get("dirs")),
+		Sort:     params.Get("sort"),
+		Order:    params.Get("order"),
+	}
+	f.Active = f.MinSize != "" || f.MaxSize != "" || f.After != "" || f.Before != "" || f.Ext != "" ||
+		f.Type != "" || f.DirsOnly
+	return f
+}
+
+// recordEntry presents an index record like a listing entry
+func recordEntry(rec search.Record) models.FileEntry {
+	name := rec.Name()
+	mode := fs.FileMode(0o444)
+	if rec.IsDir {
+		mode = fs.ModeDir | 0o555
+	}
+
+	entry := models.FileEntry{
+		Name:    name,
+		Path:    rec.Path,
+		Size:    rec.Size,
+		Mode:    mode.String(),
+		ModTime: rec.ModTime,
+		IsDir:   rec.IsDir,
+		Kind:    fileKind(name, rec.IsDir),
+	}
+	if !entry.IsDir {
+		entry.MimeType = mimeType(name)
+		entry.IsArchive = storage.IsArchive(name)
+	}
+	return entry
+}
+
+func isBuilding(pathNames []string, statuses map[string]search.Status) bool {
+	for _, pathName := range pathNames {
+		if s, ok := statuses[pathName]; !ok || (s.Building && s.BuiltAt.IsZero()) {
+			return true
+		}
+	}
+	return false
+}
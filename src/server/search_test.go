@@ -0,0 +1,42 @@
+package server
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/patppuccin/viewr/src/search"
+)
+
+func TestParseSearchFilter(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	params, _ := url.ParseQuery("minSize=1GB&maxSize=1.5t&after=7d&before=2024-05-09&ext=log,.TXT&type=Video&dirs=1&sort=mtime&order=desc")
+	filter, sort, desc, err := parseSearchFilter(params, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := search.Filter{
+		MinSize:        1 << 30,
+		MaxSize:        3 << 39,
+		ModifiedAfter:  time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC),
+		ModifiedBefore: time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC),
+		Category:       search.CategoryVideo,
+		DirsOnly:       true,
+	}
+	if filter.MinSize != want.MinSize || filter.MaxSize != want.MaxSize ||
+		!filter.ModifiedAfter.Equal(want.ModifiedAfter) || !filter.ModifiedBefore.Equal(want.ModifiedBefore) ||
+		!slices.Equal(filter.Extensions, []string{".log", ".txt"}) || filter.Category != want.Category || !filter.DirsOnly ||
+		sort != search.SortModTime || !desc {
+		t.Fatalf("parseSearchFilter() = %+v, %s, %v", filter, sort, desc)
+	}
+
+	for _, query := range []string{"minSize=lots", "maxSize=-1", "after=yesterday", "before=-3d", "type=spreadsheet", "sort=owner", "order=up"} {
+		params, _ := url.ParseQuery(query)
+		if _, _, _, err := parseSearchFilter(params, now); !errors.Is(err, errInvalidFilter) {
+			t.Fatalf("parseSearchFilter(%s) error = %v, want errInvalidFilter", query, err)
+		}
+	}
+}
@@ -5,15 +5,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/patppuccin/viewr/src/config"
 	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/db"
 	"github.com/patppuccin/viewr/src/helpers"
 	"github.com/patppuccin/viewr/src/models"
 	"github.com/patppuccin/viewr/src/out"
+	"github.com/patppuccin/viewr/src/search"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/patppuccin/viewr/src/thumbs"
 )
 
 func Run(ctx context.Context, logLevel, address string, port int, logToConsole bool) error {
@@ -47,14 +52,60 @@ func Run(ctx context.Context, logLevel, address string, port int, logToConsole b
 	logger.Info().Msgf("initializing %s v%s", constants.AppFullName, constants.AppVersion)
 	logger.Info().Msgf("configuration source: %s", config.GlobalConfigSrc)
 
+	// Prep for Server Context Step 2: Open the local database (search works without it)
+	dbConn, err := db.Open()
+	if err != nil {
+		logger.Warn().Msg("search is unavailable: " + err.Error())
+	}
+
+	// Prep for Server Context Step 3: Open the thumbnail cache (listings work without it)
+	var thumbCache *thumbs.Cache
+	if thumbCfg := config.GlobalConfig.Thumbnails; !thumbCfg.Disable {
+		if thumbCache, err = openThumbs(thumbCfg); err != nil {
+			logger.Warn().Msg("thumbnails are unavailable: " + err.Error())
+		}
+	}
+
 	// Assemble server context
 	serverCtx := &models.AppContext{
 		Config: config.GlobalConfig,
+		DBConn: dbConn,
 		Logger: logger,
 	}
 
+	// Open storage backends for all enabled paths
+	registry, err := storage.NewRegistry(config.GlobalConfig.Paths)
+	if err != nil {
+		logger.Warn().Msg("some paths are unavailable: " + err.Error())
+	}
+	defer func() {
+		if err := registry.Close(); err != nil {
+			logger.Error().Msg("error closing storage backends: " + err.Error())
+		}
+	}()
+
+	// Build the search index in the background, queries use the previous build meanwhile
+	indexCtx, stopIndex := context.WithCancel(ctx)
+	indexDone := make(chan struct{})
+	go func() {
+		defer close(indexDone)
+		if dbConn != nil && !config.GlobalConfig.Search.Disable {
+			search.New(dbConn).Run(indexCtx, registry, config.GlobalConfig.Search.RefreshInterval, logger)
+		}
+	}()
+	defer func() {
+		stopIndex()
+		<-indexDone
+		if dbConn == nil {
+			return
+		}
+		if err := dbConn.Close(); err != nil {
+			logger.Error().Msg("error closing database connection " + err.Error())
+		}
+	}()
+
 	// Setup router
-	router, err := setupRoutes(serverCtx)
+	router, err := setupRoutes(serverCtx, registry, thumbCache)
 	if err != nil {
 		return helpers.SafeErr("error setting up routes", err)
 	}
@@ -88,10 +139,26 @@ func Run(ctx context.Context, logLevel, address string, port int, logToConsole b
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Error().Msg("error during shutdown: " + err.Error())
 	}
-	// if err := dbConn.Close(); err != nil {
-	// 	logger.Error().Msg("error closing database connection " + err.Error())
-	// }
 
 	logger.Info().Msgf("%s server shut down after %s", constants.AppAbbrName, time.Since(startTime).String())
 	return nil
 }
+
+// openThumbs opens the thumbnail cache, in data/thumbs beside the executable
+// unless configured otherwise
+func openThumbs(thumbCfg models.ThumbnailConfig) (*thumbs.Cache, error) {
+	cacheDir := thumbCfg.CacheDir
+	if cacheDir == "" {
+		rootPath, err := helpers.GetRootPath()
+		if err != nil {
+			return nil, helpers.SafeErr("failed to resolve root path", err)
+		}
+		cacheDir = filepath.Join(rootPath, "data", "thumbs")
+	}
+
+	thumbCache, err := thumbs.New(cacheDir, thumbCfg.MaxCacheSize)
+	if err != nil {
+		return nil, helpers.SafeErr("failed to open thumbnail cache", err)
+	}
+	return thumbCache, nil
+}
@@ -0,0 +1,348 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/search"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/patppuccin/viewr/src/table"
+)
+
+// Extensions of the delimited files shown as tables
+var tableExtensions = []string{".csv", ".tsv", ".tab"}
+
+// Rows per page to pick from
+var tableLimits = []int{50, 100, 500, 1000}
+
+// Where pages of recently viewed tables start, shared by all paths
+var tableIndex = table.NewIndex(constants.TableIndexFiles)
+
+var errNotText = errors.New("file is not text")
+
+type tablePage struct {
+	PathName   string
+	Path       string
+	Name       string
+	Size       int64
+	Delimiter  string   // Name of the delimiter, see table.Delimiters
+	Delimiters []string // To pick from
+	Limits     []int    // Rows per page to pick from
+	Columns    []tableColumn
+	Rows       []tableRow
+	Page       int // 1-based
+	Pages      int // 0 until the end of the file was reached once
+	Limit      int // Rows per page
+	FirstRow   int // 1-based numbers of the page's first & last rows
+	LastRow    int
+	HasMore    bool
+	TotalRows  int // -1 while unknown
+	Progress   int // Percent of the file before the page
+	PrevURL    string
+	NextURL    string
+	LastURL    string // Once the page count is known
+	Binary     bool
+}
+
+type tableRow struct {
+	Number int // 1-based, in file order
+	Cells  []string
+}
+
+type tableColumn struct {
+	Name    string
+	SortURL string // Sorts the page by the column, or the other way when it is
+	Sorted  string // asc or desc when the page is sorted by the column
+}
+
+type apiTable struct {
+	Name      string     `json:"name"`
+	Path      string     `json:"path"`
+	Size      int64      `json:"size"`
+	Delimiter string     `json:"delimiter"`
+	Header    []string   `json:"header"`
+	Rows      [][]string `json:"rows"`
+	Page      int        `json:"page"`
+	Limit     int        `json:"limit"`
+	HasMore   bool       `json:"hasMore"`
+	TotalRows *int       `json:"totalRows"` // null until the end was reached once
+	Offset    int64      `json:"offset"`    // Where in the file the page starts
+}
+
+// tableQuery is what the "delimiter", "page", "limit", "sort" & "order" params ask
+// for, the delimiter empty to detect it
+type tableQuery struct {
+	Delimiter string
+	Page      int // 1-based
+	Limit     int
+	Sort      int // -1 for file order
+	Desc      bool
+}
+
+// handleTable shows a CSV or TSV file as a table, a page at a time so large files
+// stay quick to open. The rows of a page can be sorted by a column.
+func handleTable(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+
+	pathCfg := mount.Config
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		renderPathError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		http.Redirect(w, r, browseURL(pathCfg.Name, relPath), http.StatusFound)
+		return
+	}
+	query, ok := parseTableQuery(r)
+	if !ok {
+		renderError(w, r, http.StatusBadRequest, "The table parameters are invalid.")
+		return
+	}
+
+	page := tablePage{
+		PathName:   pathCfg.Name,
+		Path:       relPath,
+		Name:       path.Base(relPath),
+		Size:       info.Size(),
+		Delimiters: slices.Sorted(maps.Keys(table.Delimiters)),
+		Limits:     tableLimits,
+		Limit:      query.Limit,
+	}
+	delimiter, res, err := readTable(mount, relPath, info, query)
+	switch {
+	case errors.Is(err, errNotText):
+		page.Binary = true
+	case err != nil:
+		renderPathError(w, r, err)
+		return
+	default:
+		page.Delimiter = delimiter
+		page.HasMore, page.TotalRows = res.HasMore, res.TotalRows
+		page.Page = res.Page + 1
+		page.FirstRow = res.Page*query.Limit + 1
+		page.LastRow = page.FirstRow + len(res.Rows) - 1
+		for i, cells := range res.Rows {
+			page.Rows = append(page.Rows, tableRow{Number: page.FirstRow + i, Cells: cells})
+		}
+		if query.Sort >= 0 {
+			compare := table.Compare(query.Sort, query.Desc)
+			slices.SortStableFunc(page.Rows, func(a, b tableRow) int {
+				return compare(a.Cells, b.Cells)
+			})
+		}
+		if res.TotalRows >= 0 {
+			page.Pages = max(1, (res.TotalRows+query.Limit-1)/query.Limit)
+		}
+		if info.Size() > 0 {
+			page.Progress = int(res.Offset * 100 / info.Size())
+		}
+
+		// Links keep the page's params, sorting resets to the file order when
+		// moving to another page
+		link := query
+		link.Page = page.Page
+		for i, name := range res.Header {
+			column := tableColumn{Name: name}
+			link.Sort, link.Desc = i, false
+			if i == query.Sort {
+				column.Sorted = "asc"
+				if query.Desc {
+					column.Sorted = "desc"
+				}
+				link.Desc = !query.Desc
+			}
+			column.SortURL = link.encode()
+			page.Columns = append(page.Columns, column)
+		}
+		link.Sort, link.Desc = -1, false
+		if page.Page > 1 {
+			link.Page = page.Page - 1
+			page.PrevURL = link.encode()
+		}
+		if page.HasMore {
+			link.Page = page.Page + 1
+			page.NextURL = link.encode()
+		}
+		if page.Pages > page.Page {
+			link.Page = page.Pages
+			page.LastURL = link.encode()
+		}
+	}
+
+	renderPage(w, r, http.StatusOK, "table.html", pageData{
+		Title:  pathCrumbTitle(pathCfg.Name, relPath),
+		Crumbs: pathCrumbs(pathCfg.Name, relPath),
+		Data:   page,
+	})
+}
+
+// handleAPITable returns a page of the rows of a CSV or TSV file, see tableQuery
+// for the params
+func handleAPITable(w http.ResponseWriter, r *http.Request) {
+	mount, relPath, err := resolveAPIRoute(r)
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		writeJSONError(w, http.StatusBadRequest, "path is a directory")
+		return
+	}
+	query, ok := parseTableQuery(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "invalid table parameters")
+		return
+	}
+
+	delimiter, res, err := readTable(mount, relPath, info, query)
+	switch {
+	case errors.Is(err, errNotText):
+		writeJSONError(w, http.StatusUnsupportedMediaType, "file is not text")
+		return
+	case err != nil:
+		writePathError(w, r, err)
+		return
+	}
+
+	out := apiTable{
+		Name:      path.Base(relPath),
+		Path:      relPath,
+		Size:      info.Size(),
+		Delimiter: delimiter,
+		Header:    res.Header,
+		Rows:      res.Rows,
+		Page:      res.Page + 1,
+		Limit:     query.Limit,
+		HasMore:   res.HasMore,
+		Offset:    res.Offset,
+	}
+	if out.Header == nil {
+		out.Header = []string{}
+	}
+	if out.Rows == nil {
+		out.Rows = [][]string{}
+	}
+	if query.Sort >= 0 {
+		table.SortRows(out.Rows, query.Sort, query.Desc)
+	}
+	if res.TotalRows >= 0 {
+		out.TotalRows = &res.TotalRows
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// Table helpers
+
+// encode turns a query back into params, leaving out the defaults
+func (q tableQuery) encode() string {
+	params := url.Values{}
+	if q.Delimiter != "" {
+		params.Set("delimiter", q.Delimiter)
+	}
+	if q.Page > 1 {
+		params.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.Limit != constants.DefaultTableLimit {
+		params.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Sort >= 0 {
+		params.Set("sort", strconv.Itoa(q.Sort))
+		if q.Desc {
+			params.Set("order", "desc")
+		}
+	}
+	return "?" + params.Encode()
+}
+
+func isTable(name string) bool {
+	return slices.Contains(tableExtensions, strings.ToLower(path.Ext(name)))
+}
+
+func parseTableQuery(r *http.Request) (tableQuery, bool) {
+	params := r.URL.Query()
+	query := tableQuery{Page: 1, Limit: constants.DefaultTableLimit, Sort: -1}
+
+	if query.Delimiter = params.Get("delimiter"); query.Delimiter != "" {
+		if _, ok := table.Delimiters[query.Delimiter]; !ok {
+			return query, false
+		}
+	}
+	for name, dst := range map[string]*int{"page": &query.Page, "limit": &query.Limit, "sort": &query.Sort} {
+		if val := params.Get(name); val != "" {
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 0 || (n == 0 && name != "sort") {
+				return query, false
+			}
+			*dst = n
+		}
+	}
+	query.Limit = min(query.Limit, constants.MaxTableLimit)
+
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, false
+	}
+	return query, true
+}
+
+// readTable reads the page of a delimited file a query asks for, in file order,
+// detecting the delimiter unless it's given. It returns the delimiter's name too.
+func readTable(mount *storage.Mount, relPath string, info fs.FileInfo, query tableQuery) (string, *table.Page, error) {
+	sample, err := readText(mount.Backend, relPath, constants.TableSniffSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if !search.IsText(sample) {
+		return "", nil, errNotText
+	}
+
+	delimiter := query.Delimiter
+	if delimiter == "" {
+		delimiter = delimiterName(table.Detect(sample))
+		if ext := strings.ToLower(path.Ext(relPath)); ext == ".tsv" || ext == ".tab" {
+			delimiter = "tab"
+		}
+	}
+
+	open := func(offset int64) (io.ReadCloser, error) {
+		return mount.Backend.OpenRange(relPath, offset, -1)
+	}
+	key := mount.Config.Name + "/" + relPath + "\x00" + strconv.FormatInt(info.Size(), 10) + "\x00" + info.ModTime().String()
+	res, err := tableIndex.ReadPage(open, key, table.Delimiters[delimiter], query.Page-1, query.Limit)
+	if err != nil {
+		return "", nil, err
+	}
+	return delimiter, res, nil
+}
+
+func delimiterName(delim rune) string {
+	for name, d := range table.Delimiters {
+		if d == delim {
+			return name
+		}
+	}
+	return "comma"
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/patppuccin/viewr/src/constants"
+	"github.com/patppuccin/viewr/src/storage"
+	"github.com/patppuccin/viewr/src/thumbs"
+)
+
+// handleThumb serves the cached thumbnail of an image, at one of the fixed sizes
+// picked by the "size" param. Thumbnails versioned by thumbURL ("v" param) are
+// cached for good.
+func handleThumb(w http.ResponseWriter, r *http.Request) {
+	thumbCache := getThumbs(r)
+	if thumbCache == nil {
+		http.Error(w, "thumbnails are unavailable", http.StatusNotFound)
+		return
+	}
+
+	mount, relPath, err := resolveRoute(r)
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+
+	size := constants.DefaultThumbnailSize
+	if val := r.URL.Query().Get("size"); val != "" {
+		if size, err = strconv.Atoi(val); err != nil {
+			http.Error(w, "invalid thumbnail size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	info, err := mount.Backend.Stat(storage.Name(relPath))
+	if err != nil {
+		writePathError(w, r, err)
+		return
+	}
+	if info.IsDir() {
+		http.Error(w, "not an image", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	thumb, err := thumbCache.Get(mount.Backend, mount.Config.Name, relPath, info, size)
+	switch {
+	case errors.Is(err, thumbs.ErrUnsupported), errors.Is(err, thumbs.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		writePathError(w, r, err)
+		return
+	}
+
+	file, err := os.Open(thumb.File)
+	if err != nil {
+		// Evicted in the meantime, the next request makes it again
+		getServerCtx(r).Logger.Warn().Msg("failed to open thumbnail: " + err.Error())
+		http.Error(w, "thumbnail is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// Versioned URLs change along with the image & are kept for good, others are
+	// revalidated by the ETag
+	w.Header().Set("Content-Type", thumb.ContentType)
+	if r.URL.Query().Has("v") {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	w.Header().Set("ETag", thumb.ETag)
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
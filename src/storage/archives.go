@@ -0,0 +1,703 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tar listings need a full pass over the archive & ZIP listings a read of its
+// central directory, keep the most recent ones
+const maxCachedArchiveIndexes = 16
+
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether a file name has the extension of a browsable archive
+func IsArchive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) && len(lower) > len(ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithArchives lets the archives of a backend be browsed like folders. Names that
+// run through an archive file ("release.zip/bin/tool") address its members, the
+// archive itself keeps opening, stat-ing & ranging like the file it is, and only
+// listing it reads its contents. Nothing is ever extracted to disk.
+func WithArchives(b Backend) Backend {
+	return &archiveBackend{Backend: b, indexes: map[string]*archiveIndex{}}
+}
+
+type archiveBackend struct {
+	Backend
+
+	mu      sync.Mutex
+	indexes map[string]*archiveIndex // By archive name
+	order   []string                 // Cached names, oldest first
+}
+
+func (a *archiveBackend) Open(name string) (fs.File, error) {
+	archiveName, member, err := a.split(name)
+	if err != nil {
+		return nil, err
+	}
+	if archiveName == "" || member == "." {
+		return a.Backend.Open(name)
+	}
+
+	arc, err := a.openArchive(archiveName)
+	if err != nil {
+		return nil, err
+	}
+	file, err := arc.Open(member)
+	if err != nil {
+		_ = arc.Close()
+		return nil, err
+	}
+	return &archiveFile{File: file, archive: arc}, nil
+}
+
+func (a *archiveBackend) Stat(name string) (fs.FileInfo, error) {
+	archiveName, member, err := a.split(name)
+	if err != nil {
+		return nil, err
+	}
+	if archiveName == "" || member == "." {
+		return a.Backend.Stat(name)
+	}
+
+	arc, err := a.openArchive(archiveName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = arc.Close()
+	}()
+	return arc.Stat(member)
+}
+
+func (a *archiveBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	archiveName, member, err := a.split(name)
+	if err != nil {
+		return nil, err
+	}
+	if archiveName == "" {
+		return a.Backend.ReadDir(name)
+	}
+
+	// A folder can carry an archive extension too
+	if member == "." {
+		info, err := a.Backend.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return a.Backend.ReadDir(name)
+		}
+	}
+
+	arc, err := a.openArchive(archiveName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = arc.Close()
+	}()
+	return arc.ReadDir(member)
+}
+
+func (a *archiveBackend) OpenRange(name string, offset, length int64) (io.ReadCloser, error) {
+	archiveName, member, err := a.split(name)
+	if err != nil {
+		return nil, err
+	}
+	if archiveName == "" || member == "." {
+		return a.Backend.OpenRange(name, offset, length)
+	}
+
+	arc, err := a.openArchive(archiveName)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := arc.OpenRange(member, offset, length)
+	if err != nil {
+		_ = arc.Close()
+		return nil, err
+	}
+	return readCloser{Reader: rc, Closer: closers{rc, arc}}, nil
+}
+
+// split finds the first archive file along name and returns it with the member
+// path inside it ("." for the archive itself). Names that don't lead into an
+// archive return an empty archive name.
+func (a *archiveBackend) split(name string) (archiveName, member string, err error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", "", nil
+	}
+
+	for i := 0; i <= len(name); i++ {
+		if i < len(name) && name[i] != '/' {
+			continue
+		}
+		prefix := name[:i]
+		if !IsArchive(prefix) {
+			continue
+		}
+		if i == len(name) {
+			return prefix, ".", nil
+		}
+
+		// Folders named like archives are just folders
+		info, err := a.Backend.Stat(prefix)
+		if err != nil {
+			return "", "", err
+		}
+		if info.Mode().IsRegular() {
+			return prefix, name[i+1:], nil
+		}
+	}
+	return "", "", nil
+}
+
+func (a *archiveBackend) openArchive(name string) (*archiveFS, error) {
+	info, err := a.Backend.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	lower := strings.ToLower(name)
+	isZip := strings.HasSuffix(lower, ".zip")
+	gzipped := !isZip && !strings.HasSuffix(lower, ".tar")
+
+	a.mu.Lock()
+	index, ok := a.indexes[name]
+	a.mu.Unlock()
+	if !ok || index.size != info.Size() || !index.modTime.Equal(info.ModTime()) {
+		if isZip {
+			index, err = indexZip(a.Backend, name, info)
+		} else {
+			index, err = indexTar(a.Backend, name, info, gzipped)
+		}
+		if err != nil {
+			return nil, err
+		}
+		a.cacheIndex(name, index)
+	}
+
+	arc := &archiveFS{outer: a.Backend, name: name, gzipped: gzipped, index: index}
+	if index.zip != nil {
+		index.zip.acquire()
+		arc.closer = index.zip
+	}
+	return arc, nil
+}
+
+func (a *archiveBackend) cacheIndex(name string, index *archiveIndex) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.indexes[name]; !exists {
+		a.order = append(a.order, name)
+	}
+	a.indexes[name] = index
+	for len(a.order) > maxCachedArchiveIndexes {
+		delete(a.indexes, a.order[0])
+		a.order = a.order[1:]
+	}
+}
+
+// Archive contents ///////////////////////////////
+
+// archiveIndex is the member tree of an archive, synthesizing folders that are
+// only implied by member names
+type archiveIndex struct {
+	size    int64
+	modTime time.Time
+	members map[string]*archiveMember
+	zip     *zipSource // What the members of a ZIP archive read from
+}
+
+type archiveMember struct {
+	info     fileInfo
+	children map[string]bool
+	zipFile  *zip.File
+	offset   int64 // Data offset of an uncompressed tar member, -1 when unknown
+	ordinal  int   // Position in a tar stream
+}
+
+func newArchiveIndex(size int64, modTime time.Time) *archiveIndex {
+	root := &archiveMember{info: fileInfo{name: ".", modTime: modTime, isDir: true}, children: map[string]bool{}}
+	return &archiveIndex{size: size, modTime: modTime, members: map[string]*archiveMember{".": root}}
+}
+
+// add places a member in the tree, creating its parent folders as needed. Later
+// members replace earlier ones of the same name, as when extracting.
+func (idx *archiveIndex) add(name string, m *archiveMember) {
+	for dir, child := path.Dir(name), name; ; dir, child = path.Dir(dir), dir {
+		parent, ok := idx.members[dir]
+		if !ok {
+			parent = &archiveMember{info: fileInfo{name: path.Base(dir), modTime: idx.modTime, isDir: true}, children: map[string]bool{}}
+			idx.members[dir] = parent
+		}
+		if !parent.info.isDir {
+			// A file shadowing a folder path, the folder wins
+			parent.info.isDir, parent.info.size, parent.zipFile, parent.children = true, 0, nil, map[string]bool{}
+		}
+		parent.children[path.Base(child)] = true
+		if dir == "." {
+			break
+		}
+	}
+
+	if existing, ok := idx.members[name]; ok && existing.info.isDir {
+		if !m.info.isDir {
+			return
+		}
+		existing.info.modTime = m.info.modTime
+		return
+	}
+	if m.info.isDir {
+		m.children = map[string]bool{}
+	}
+	idx.members[name] = m
+}
+
+// memberName turns a stored member name into an fs.FS name. Absolute names and
+// names climbing out of the archive are refused rather than rewritten.
+func memberName(raw string) (string, bool) {
+	name := strings.ReplaceAll(raw, "\\", "/")
+	for strings.HasPrefix(name, "./") {
+		name = name[2:]
+	}
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", false
+	}
+	if slices.Contains(strings.Split(name, "/"), "..") {
+		return "", false
+	}
+
+	name = path.Clean(name)
+	return name, name != "." && fs.ValidPath(name)
+}
+
+func indexZip(outer Backend, name string, info fs.FileInfo) (*archiveIndex, error) {
+	source := &zipSource{outer: outer, name: name}
+	source.acquire()
+	defer func() {
+		_ = source.Close()
+	}()
+
+	// Insecure member names are refused one by one below, the archive stays usable
+	zr, err := zip.NewReader(source, info.Size())
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	index := newArchiveIndex(info.Size(), info.ModTime())
+	index.zip = source
+	for _, zf := range zr.File {
+		memberPath, ok := memberName(zf.Name)
+		if !ok || !(zf.Mode().IsRegular() || zf.Mode().IsDir()) {
+			continue
+		}
+		isDir := zf.Mode().IsDir() || strings.HasSuffix(zf.Name, "/")
+		m := &archiveMember{
+			info:   fileInfo{name: path.Base(memberPath), modTime: zf.Modified, isDir: isDir},
+			offset: -1,
+		}
+		if !isDir {
+			m.info.size = int64(zf.UncompressedSize64)
+			m.zipFile = zf
+		}
+		index.add(memberPath, m)
+	}
+	return index, nil
+}
+
+func indexTar(outer Backend, name string, info fs.FileInfo, gzipped bool) (*archiveIndex, error) {
+	body, err := outer.OpenRange(name, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	counter := &countingReader{r: body}
+	var r io.Reader = counter
+	if gzipped {
+		gz, err := gzip.NewReader(counter)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		r = gz
+	}
+
+	index := newArchiveIndex(info.Size(), info.ModTime())
+	tr := tar.NewReader(r)
+	for ordinal := 0; ; ordinal++ {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, tar.ErrInsecurePath) {
+			continue
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+
+		// Links & special files are left out, they are never followed
+		memberPath, ok := memberName(hdr.Name)
+		if !ok || (hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir) {
+			continue
+		}
+
+		m := &archiveMember{
+			info:    fileInfo{name: path.Base(memberPath), modTime: hdr.ModTime, isDir: hdr.Typeflag == tar.TypeDir},
+			offset:  -1,
+			ordinal: ordinal,
+		}
+		if !m.info.isDir {
+			m.info.size = hdr.Size
+			// The tar reader consumes headers exactly, so the data starts here
+			if !gzipped && !isSparse(hdr) {
+				m.offset = counter.n
+			}
+		}
+		index.add(memberPath, m)
+	}
+	return index, nil
+}
+
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveFS is one opened archive, presented as a read-only Backend
+type archiveFS struct {
+	outer   Backend
+	name    string // Archive name within outer
+	gzipped bool
+	index   *archiveIndex
+	closer  io.Closer
+}
+
+func (a *archiveFS) member(op, name string) (*archiveMember, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	m, ok := a.index.members[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return m, nil
+}
+
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	m, err := a.member("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if m.info.isDir {
+		return &dirFile{info: &m.info, readDir: func() ([]fs.DirEntry, error) { return a.ReadDir(name) }}, nil
+	}
+	return &archiveMemberFile{archive: a, member: m}, nil
+}
+
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	m, err := a.member("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info := m.info
+	return &info, nil
+}
+
+func (a *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m, err := a.member("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !m.info.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(m.children))
+	for child := range m.children {
+		info := a.index.members[path.Join(name, child)].info
+		entries = append(entries, fs.FileInfoToDirEntry(&info))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+// OpenRange reads stored ZIP entries & uncompressed tar members straight from the
+// archive file, everything else is decompressed from the start of the member
+func (a *archiveFS) OpenRange(name string, offset, length int64) (io.ReadCloser, error) {
+	m, err := a.member("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if m.info.isDir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+
+	dataOffset := m.offset
+	if m.zipFile != nil && m.zipFile.Method == zip.Store && m.zipFile.Flags&0x1 == 0 {
+		if dataOffset, err = m.zipFile.DataOffset(); err != nil {
+			return nil, err
+		}
+	}
+	if dataOffset >= 0 {
+		offset = min(offset, m.info.size)
+		if length < 0 || offset+length > m.info.size {
+			length = m.info.size - offset
+		}
+		return a.outer.OpenRange(a.name, dataOffset+offset, length)
+	}
+
+	file, err := a.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return rangeOf(file, offset, length)
+}
+
+func (a *archiveFS) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// read opens the decompressed contents of a file member
+func (a *archiveFS) read(m *archiveMember) (io.ReadCloser, error) {
+	if m.zipFile != nil {
+		return m.zipFile.Open()
+	}
+	if m.offset >= 0 {
+		return a.outer.OpenRange(a.name, m.offset, m.info.size)
+	}
+
+	// Compressed tar streams (and sparse members) have to be read up to the member
+	body, err := a.outer.OpenRange(a.name, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = body
+	if a.gzipped {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			_ = body.Close()
+			return nil, err
+		}
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for ordinal := 0; ; ordinal++ {
+		if _, err := tr.Next(); err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+			_ = body.Close()
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if ordinal == m.ordinal {
+			return readCloser{Reader: tr, Closer: body}, nil
+		}
+	}
+}
+
+type archiveMemberFile struct {
+	archive *archiveFS
+	member  *archiveMember
+	body    io.ReadCloser
+}
+
+func (f *archiveMemberFile) Stat() (fs.FileInfo, error) {
+	info := f.member.info
+	return &info, nil
+}
+
+func (f *archiveMemberFile) Read(p []byte) (int, error) {
+	if f.body == nil {
+		body, err := f.archive.read(f.member)
+		if err != nil {
+			return 0, err
+		}
+		f.body = body
+	}
+	return f.body.Read(p)
+}
+
+func (f *archiveMemberFile) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	return f.body.Close()
+}
+
+// archiveFile is a member opened through the wrapper, closing it closes the
+// archive it came from as well
+type archiveFile struct {
+	fs.File
+	archive *archiveFS
+}
+
+func (f *archiveFile) Close() error {
+	return errors.Join(f.File.Close(), f.archive.Close())
+}
+
+func (f *archiveFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if dir, ok := f.File.(fs.ReadDirFile); ok {
+		return dir.ReadDir(n)
+	}
+	return nil, &fs.PathError{Op: "readdir", Path: f.archive.name, Err: errNotDir}
+}
+
+// Archive helpers
+
+// zipSource is the archive file the members of a cached ZIP index read from. The
+// parsed directory outlives any one handle on the file, so it's opened on the
+// first read & closed again once every archiveFS using it is closed.
+type zipSource struct {
+	outer Backend
+	name  string
+
+	mu     sync.Mutex
+	users  int
+	ra     io.ReaderAt
+	closer io.Closer
+}
+
+func (z *zipSource) acquire() {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.users++
+}
+
+func (z *zipSource) ReadAt(p []byte, off int64) (int, error) {
+	z.mu.Lock()
+	if z.ra == nil {
+		file, err := z.outer.Open(z.name)
+		if err != nil {
+			z.mu.Unlock()
+			return 0, err
+		}
+
+		// Local & SFTP files read at offsets natively, remote streams go through ranges
+		ra, ok := file.(io.ReaderAt)
+		var closer io.Closer = file
+		if !ok {
+			_ = file.Close()
+			rra := &rangeReaderAt{backend: z.outer, name: z.name}
+			ra, closer = rra, rra
+		}
+		z.ra, z.closer = ra, closer
+	}
+	ra := z.ra
+	z.mu.Unlock()
+
+	return ra.ReadAt(p, off)
+}
+
+// Close releases one user, closing the file after the last
+func (z *zipSource) Close() error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if z.users--; z.users > 0 || z.closer == nil {
+		return nil
+	}
+	err := z.closer.Close()
+	z.ra, z.closer = nil, nil
+	return err
+}
+
+// rangeReaderAt serves ReadAt from OpenRange, keeping the last stream open so the
+// mostly sequential reads of archive/zip don't reopen on every call
+type rangeReaderAt struct {
+	backend Backend
+	name    string
+
+	mu   sync.Mutex
+	body io.ReadCloser
+	pos  int64
+}
+
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.body == nil || r.pos != off {
+		if r.body != nil {
+			_ = r.body.Close()
+		}
+		body, err := r.backend.OpenRange(r.name, off, -1)
+		if err != nil {
+			r.body = nil
+			return 0, err
+		}
+		r.body, r.pos = body, off
+	}
+
+	n, err := io.ReadFull(r.body, p)
+	r.pos += int64(n)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *rangeReaderAt) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var errs []error
+	for _, closer := range c {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
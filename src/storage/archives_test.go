@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestIsArchive(t *testing.T) {
+	tests := map[string]bool{
+		"release.zip":    true,
+		"release.TAR.GZ": true,
+		"release.tgz":    true,
+		"dir/bundle.tar": true,
+		"notes.txt":      false,
+		".zip":           false,
+		"archive.gz":     false,
+	}
+	for name, want := range tests {
+		if got := IsArchive(name); got != want {
+			t.Errorf("IsArchive(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestWithArchives(t *testing.T) {
+	members := []testMember{
+		{name: "bin/tool", data: "0123456789", store: true},
+		{name: "docs/readme.md", data: "# Readme\n"},
+		{name: "../escape.txt", data: "escape"},
+		{name: "/etc/passwd", data: "root"},
+		{name: "./top.txt", data: "top"},
+	}
+	backend := WithArchives(FromFS(fstest.MapFS{
+		"release.zip":        {Data: buildZip(t, members)},
+		"bundle.tar":         {Data: buildTar(t, members, false)},
+		"bundle.tar.gz":      {Data: buildTar(t, members, true)},
+		"folder.zip/a.txt":   {Data: []byte("a")},
+		"plain/readme.txt":   {Data: []byte("plain")},
+		"plain/not-a-zip.gz": {Data: []byte("gz")},
+	}))
+
+	for _, archiveName := range []string{"release.zip", "bundle.tar", "bundle.tar.gz"} {
+		t.Run(archiveName, func(t *testing.T) {
+			// The archive itself is still a file
+			info, err := backend.Stat(archiveName)
+			if err != nil || info.IsDir() {
+				t.Fatalf("Stat(%s) = %v, %v, want a file", archiveName, info, err)
+			}
+
+			entries, err := backend.ReadDir(archiveName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := entryNames(entries); !slices.Equal(got, []string{"bin/", "docs/", "top.txt"}) {
+				t.Fatalf("ReadDir(%s) = %v, want members without escaping names", archiveName, got)
+			}
+
+			info, err = backend.Stat(archiveName + "/bin/tool")
+			if err != nil || info.Size() != 10 {
+				t.Fatalf("Stat(bin/tool) = %v, %v, want 10 bytes", info, err)
+			}
+
+			data, err := fs.ReadFile(backend, archiveName+"/docs/readme.md")
+			if err != nil || string(data) != "# Readme\n" {
+				t.Fatalf("ReadFile(docs/readme.md) = %q, %v", data, err)
+			}
+
+			rc, err := backend.OpenRange(archiveName+"/bin/tool", 3, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(rc)
+			_ = rc.Close()
+			if string(got) != "3456" {
+				t.Fatalf("OpenRange(bin/tool, 3, 4) = %q, want \"3456\"", got)
+			}
+
+			for _, name := range []string{"escape.txt", "etc/passwd", "bin/tool/x", "missing"} {
+				if _, err := backend.Stat(archiveName + "/" + name); !errors.Is(err, fs.ErrNotExist) {
+					t.Fatalf("Stat(%s) error = %v, want fs.ErrNotExist", name, err)
+				}
+			}
+		})
+	}
+
+	// Folders named like archives are plain folders
+	if data, err := fs.ReadFile(backend, "folder.zip/a.txt"); err != nil || string(data) != "a" {
+		t.Fatalf("ReadFile(folder.zip/a.txt) = %q, %v", data, err)
+	}
+	if entries, err := backend.ReadDir("folder.zip"); err != nil || len(entries) != 1 {
+		t.Fatalf("ReadDir(folder.zip) = %v, %v", entries, err)
+	}
+
+	// Walking into archives works like walking folders, for downloads
+	var walked []string
+	err := fs.WalkDir(backend, "release.zip/bin", func(name string, _ fs.DirEntry, err error) error {
+		walked = append(walked, name)
+		return err
+	})
+	if err != nil || !slices.Equal(walked, []string{"release.zip/bin", "release.zip/bin/tool"}) {
+		t.Fatalf("WalkDir(release.zip/bin) = %v, %v", walked, err)
+	}
+}
+
+func TestArchiveRangeReads(t *testing.T) {
+	members := []testMember{{name: "stored.bin", data: "0123456789", store: true}}
+	// Without io.ReaderAt the archive is read through ranges, like from S3
+	backend := WithArchives(FromFS(noSeekFS{fstest.MapFS{"a.zip": {Data: buildZip(t, members)}}}))
+
+	rc, err := backend.OpenRange("a.zip/stored.bin", 8, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "89" {
+		t.Fatalf("OpenRange(stored.bin, 8) = %q, want \"89\"", got)
+	}
+}
+
+func TestArchiveIndexCache(t *testing.T) {
+	members := []testMember{{name: "bin/tool", data: "0123456789"}, {name: "top.txt", data: "top"}}
+	outer := &countingBackend{Backend: FromFS(fstest.MapFS{"release.zip": {Data: buildZip(t, members)}})}
+	backend := WithArchives(outer)
+
+	// The central directory is read once, only reading a member opens the file again
+	if _, err := backend.ReadDir("release.zip"); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := backend.Stat("release.zip/bin/tool"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, err := fs.ReadFile(backend, "release.zip/top.txt"); err != nil || string(data) != "top" {
+		t.Fatalf("ReadFile(top.txt) = %q, %v", data, err)
+	}
+	if outer.opens != 2 {
+		t.Fatalf("archive opened %d times, want 2", outer.opens)
+	}
+}
+
+// Test helpers
+
+// countingBackend counts how often files are opened
+type countingBackend struct {
+	Backend
+	opens int
+}
+
+func (c *countingBackend) Open(name string) (fs.File, error) {
+	c.opens++
+	return c.Backend.Open(name)
+}
+
+func (c *countingBackend) OpenRange(name string, offset, length int64) (io.ReadCloser, error) {
+	c.opens++
+	return c.Backend.OpenRange(name, offset, length)
+}
+
+type testMember struct {
+	name  string
+	data  string
+	store bool
+}
+
+var testModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func buildZip(t *testing.T, members []testMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range members {
+		method := zip.Deflate
+		if m.store {
+			method = zip.Store
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: m.name, Method: method, Modified: testModTime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, m.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, members []testMember, gzipped bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(&buf)
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	for _, m := range members {
+		hdr := &tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.data)), ModTime: testModTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, m.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
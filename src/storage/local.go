@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/resolver"
+)
+
+// Local is a backend for a directory on the local filesystem. Every access runs
+// through the resolver, so symlinks can't lead outside of the configured root.
+type Local struct {
+	pathCfg models.PathConfig
+}
+
+func NewLocal(pathCfg models.PathConfig) (*Local, error) {
+	info, err := os.Stat(pathCfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: pathCfg.Path, Err: errNotDir}
+	}
+	return &Local{pathCfg: pathCfg}, nil
+}
+
+func (l *Local) resolve(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		name = ""
+	}
+
+	fullPath, err := resolver.Resolve(l.pathCfg, name)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return fullPath, nil
+}
+
+func (l *Local) Open(name string) (fs.File, error) {
+	fullPath, err := l.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+func (l *Local) Stat(name string) (fs.FileInfo, error) {
+	fullPath, err := l.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(fullPath)
+}
+
+func (l *Local) ReadDir(name string) ([]fs.DirEntry, error) {
+	fullPath, err := l.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(fullPath)
+}
+
+func (l *Local) OpenRange(name string, offset, length int64) (io.ReadCloser, error) {
+	file, err := l.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return rangeOf(file, offset, length)
+}
+
+func (l *Local) Close() error {
+	return nil
+}
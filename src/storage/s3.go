@@ -0,0 +1,562 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patppuccin/viewr/src/models"
+)
+
+// SHA-256 of an empty payload, every request this backend sends is body-less
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// How long stats & listings may take, response included
+const s3RequestTimeout = time.Minute
+
+// S3 is a backend for a bucket (or a prefix within one) on any S3-compatible
+// object store. Common prefixes are presented as folders, objects as files, and
+// range reads are passed through to the store as Range requests.
+type S3 struct {
+	endpoint      *url.URL
+	region        string
+	bucket        string
+	prefix        string // Empty, or ends with "/"
+	virtualHosted bool
+	creds         s3Credentials
+	client        *http.Client
+	timeout       time.Duration // Of stats & listings
+	now           func() time.Time
+}
+
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func NewS3(cfg *models.S3Config) (*S3, error) {
+	if cfg == nil || cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3 paths need an endpoint and a bucket")
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, errors.New("invalid s3 endpoint: " + cfg.Endpoint)
+	}
+
+	creds, err := loadS3Credentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// No overall client timeout, downloads of large objects may legitimately run for hours
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	return &S3{
+		endpoint:      endpoint,
+		region:        region,
+		bucket:        cfg.Bucket,
+		prefix:        prefix,
+		virtualHosted: cfg.VirtualHosted,
+		creds:         creds,
+		client:        &http.Client{Transport: transport},
+		timeout:       s3RequestTimeout,
+		now:           time.Now,
+	}, nil
+}
+
+func (s *S3) Open(name string) (fs.File, error) {
+	info, err := s.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dirFile{info: info, readDir: func() ([]fs.DirEntry, error) { return s.ReadDir(name) }}, nil
+	}
+	return &s3File{s3: s, name: name, info: info}, nil
+}
+
+func (s *S3) Stat(name string) (fs.FileInfo, error) {
+	return s.stat("stat", name)
+}
+
+func (s *S3) stat(op, name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &fileInfo{name: ".", isDir: true}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	// Objects take precedence over prefixes of the same name
+	resp, err := s.do(ctx, http.MethodHead, s.prefix+name, nil, nil)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+		return &fileInfo{name: path.Base(name), size: resp.ContentLength, modTime: modTime}, nil
+	case resp.StatusCode != http.StatusNotFound:
+		return nil, &fs.PathError{Op: op, Path: name, Err: s3StatusError(resp)}
+	}
+
+	// S3 has no folders, a prefix exists when anything is stored beneath it
+	result, err := s.list(s.prefix+name+"/", "", "", 1)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if len(result.Contents) == 0 && len(result.CommonPrefixes) == 0 {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return &fileInfo{name: path.Base(name), isDir: true}, nil
+}
+
+func (s *S3) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	dirPrefix := s.prefix
+	if name != "." {
+		dirPrefix += name + "/"
+	}
+
+	var entries []fs.DirEntry
+	token := ""
+	for {
+		result, err := s.list(dirPrefix, "/", token, 1000)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+
+		for _, p := range result.CommonPrefixes {
+			dirName := strings.TrimSuffix(strings.TrimPrefix(p.Prefix, dirPrefix), "/")
+			if fs.ValidPath(dirName) && !strings.Contains(dirName, "/") {
+				entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{name: dirName, isDir: true}))
+			}
+		}
+		for _, obj := range result.Contents {
+			fileName := strings.TrimPrefix(obj.Key, dirPrefix)
+			// Skip zero-byte "folder marker" objects & keys that aren't valid fs names
+			if fileName == "" || !fs.ValidPath(fileName) || strings.Contains(fileName, "/") {
+				continue
+			}
+			entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{name: fileName, size: obj.Size, modTime: obj.LastModified}))
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	// A folder that lists nothing only exists if it is the root
+	if len(entries) == 0 && name != "." {
+		if _, err := s.stat("readdir", name); err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (s *S3) OpenRange(name string, offset, length int64) (io.ReadCloser, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	header := http.Header{}
+	if offset > 0 || length > 0 {
+		byteRange := "bytes=" + strconv.FormatInt(offset, 10) + "-"
+		if length > 0 {
+			byteRange += strconv.FormatInt(offset+length-1, 10)
+		}
+		header.Set("Range", byteRange)
+	}
+
+	// Reads may stream for hours, so they have no timeout past ResponseHeaderTimeout
+	// & end when the body is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	resp, err := s.do(ctx, http.MethodGet, s.prefix+name, nil, header)
+	if err != nil {
+		cancel()
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+		return &s3Body{ReadCloser: resp.Body, cancel: cancel}, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Reading at or past the end yields nothing, just like a local file
+		_ = resp.Body.Close()
+		cancel()
+		return io.NopCloser(strings.NewReader("")), nil
+	default:
+		defer func() {
+			_ = resp.Body.Close()
+			cancel()
+		}()
+		return nil, &fs.PathError{Op: "read", Path: name, Err: s3StatusError(resp)}
+	}
+}
+
+func (s *S3) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// S3 requests ////////////////////////////////////
+
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+func (s *S3) list(prefix, delimiter, token string, maxKeys int) (*s3ListResult, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+	query.Set("max-keys", strconv.Itoa(maxKeys))
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3StatusError(resp)
+	}
+
+	var result s3ListResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	u.RawQuery = canonicalQuery(query)
+
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if s.virtualHosted {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+		u.RawPath = uriEncode(basePath, false) + "/" + uriEncode(key, false)
+	} else {
+		u.Path = basePath + "/" + s.bucket + "/" + key
+		u.RawPath = uriEncode(basePath, false) + "/" + uriEncode(s.bucket, true) + "/" + uriEncode(key, false)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req)
+
+	return s.client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers. Anonymous access (no credentials)
+// sends requests unsigned, for public buckets.
+func (s *S3) sign(req *http.Request) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if s.creds.accessKey == "" {
+		return
+	}
+	if s.creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.creds.sessionToken)
+	}
+
+	// Canonical headers: host plus everything that's x-amz-* or a Range
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "range" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.creds.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.creds.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// S3 helpers /////////////////////////////////////
+
+// loadS3Credentials reads keys from the credentials file when one is configured,
+// and from the environment otherwise
+func loadS3Credentials(cfg *models.S3Config) (s3Credentials, error) {
+	if cfg.CredentialsFile != "" {
+		profile := cfg.Profile
+		if profile == "" {
+			profile = "default"
+		}
+		return readS3CredentialsFile(cfg.CredentialsFile, profile)
+	}
+
+	accessKeyEnv, secretKeyEnv := cfg.AccessKeyEnv, cfg.SecretKeyEnv
+	if accessKeyEnv == "" {
+		accessKeyEnv = "AWS_ACCESS_KEY_ID"
+	}
+	if secretKeyEnv == "" {
+		secretKeyEnv = "AWS_SECRET_ACCESS_KEY"
+	}
+	return s3Credentials{
+		accessKey:    os.Getenv(accessKeyEnv),
+		secretKey:    os.Getenv(secretKeyEnv),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+// readS3CredentialsFile parses a profile from an AWS shared credentials file
+func readS3CredentialsFile(filePath, profile string) (s3Credentials, error) {
+	var creds s3Credentials
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return creds, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	section := ""
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		found = true
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.accessKey = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.secretKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.sessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return creds, err
+	}
+	if !found || creds.accessKey == "" || creds.secretKey == "" {
+		return creds, errors.New("no credentials for profile " + profile + " in " + filePath)
+	}
+	return creds, nil
+}
+
+func s3StatusError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fs.ErrNotExist
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return fs.ErrPermission
+	}
+
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if resp.Request != nil && resp.Request.Method != http.MethodHead {
+		_ = xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3Err)
+	}
+	if s3Err.Code != "" {
+		return errors.New("s3: " + s3Err.Code + ": " + s3Err.Message)
+	}
+	return errors.New("s3: unexpected status " + resp.Status)
+}
+
+// uriEncode applies the SigV4 URI encoding: everything but unreserved characters
+// is percent-encoded, and slashes are kept unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hexDigits[c>>4])
+			sb.WriteByte(hexDigits[c&0xf])
+		}
+	}
+	return sb.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3File streams an object from the start; seeking is provided by OpenReadSeeker
+type s3File struct {
+	s3   *S3
+	name string
+	info fs.FileInfo
+	body io.ReadCloser
+}
+
+func (f *s3File) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.body == nil {
+		body, err := f.s3.OpenRange(f.name, 0, -1)
+		if err != nil {
+			return 0, err
+		}
+		f.body = body
+	}
+	return f.body.Read(p)
+}
+
+func (f *s3File) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	return f.body.Close()
+}
+
+// s3Body is the body of an object read, which ends its request when closed
+type s3Body struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *s3Body) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
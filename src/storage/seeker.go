@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// OpenReadSeeker opens a file for random access, as needed by http.ServeContent.
+// Files that seek natively are returned as-is; everything else is served through
+// the backend's OpenRange, reopening the stream only when a seek moves the offset.
+func OpenReadSeeker(b Backend, name string) (io.ReadSeekCloser, fs.FileInfo, error) {
+	file, err := b.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+
+	if rs, ok := file.(io.ReadSeekCloser); ok {
+		return rs, info, nil
+	}
+	_ = file.Close()
+
+	return &rangeSeeker{backend: b, name: name, size: info.Size()}, info, nil
+}
+
+// ReadAtCloser is a file read at arbitrary offsets
+type ReadAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// OpenReaderAt opens a file for reads at offsets, like the parsers of file formats
+// need. Files that read at offsets natively are returned as-is, everything else
+// reads through the backend's OpenRange.
+func OpenReaderAt(b Backend, name string) (ReadAtCloser, fs.FileInfo, error) {
+	file, err := b.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+
+	if ra, ok := file.(ReadAtCloser); ok {
+		return ra, info, nil
+	}
+	_ = file.Close()
+
+	return &rangeReaderAt{backend: b, name: name}, info, nil
+}
+
+type rangeSeeker struct {
+	backend Backend
+	name    string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+	bodyOff int64 // Offset the open body is positioned at
+}
+
+func (rs *rangeSeeker) Read(p []byte) (int, error) {
+	if rs.offset >= rs.size {
+		return 0, io.EOF
+	}
+
+	// (Re)open the stream whenever a seek moved away from the current position
+	if rs.body != nil && rs.bodyOff != rs.offset {
+		_ = rs.body.Close()
+		rs.body = nil
+	}
+	if rs.body == nil {
+		body, err := rs.backend.OpenRange(rs.name, rs.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		rs.body = body
+		rs.bodyOff = rs.offset
+	}
+
+	n, err := rs.body.Read(p)
+	rs.offset += int64(n)
+	rs.bodyOff += int64(n)
+	return n, err
+}
+
+func (rs *rangeSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += rs.offset
+	case io.SeekEnd:
+		offset += rs.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	rs.offset = offset
+	return offset, nil
+}
+
+func (rs *rangeSeeker) Close() error {
+	if rs.body == nil {
+		return nil
+	}
+	return rs.body.Close()
+}
@@ -0,0 +1,359 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/patppuccin/viewr/src/resolver"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Connections kept open per SFTP path at most
+const sftpPoolSize = 4
+
+// SFTP is a backend for a directory on a remote host. Requests run on a small pool
+// of SSH connections: the least busy one is used, and another is dialed only when
+// all are busy & the pool isn't full (SFTP multiplexes requests over each). Dropped
+// connections leave the pool & are replaced on demand.
+type SFTP struct {
+	pathCfg   models.PathConfig
+	addr      string
+	sshConfig *ssh.ClientConfig
+
+	mu      sync.Mutex
+	dialed  *sync.Cond // Signalled when a dial ends, for calls waiting on a full pool
+	conns   []*sftpConn
+	dialing int
+	closed  bool
+}
+
+type sftpConn struct {
+	ssh      *ssh.Client
+	client   *sftp.Client
+	realRoot string // Root with symlinks resolved, per connection
+	busy     int    // Calls running on it
+}
+
+func NewSFTP(pathCfg models.PathConfig) (*SFTP, error) {
+	cfg := pathCfg.SFTP
+	if cfg == nil || cfg.Host == "" || cfg.User == "" || cfg.KeyFile == "" {
+		return nil, errors.New("sftp paths need a host, a user and a key file")
+	}
+
+	keyData, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	var signer ssh.Signer
+	if cfg.KeyPassphraseEnv != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(os.Getenv(cfg.KeyPassphraseEnv)))
+	} else {
+		signer, err = ssh.ParsePrivateKey(keyData)
+	}
+	if err != nil {
+		return nil, errors.Join(errors.New("invalid key file "+cfg.KeyFile), err)
+	}
+
+	// Host keys are always verified, there's deliberately no way to skip this
+	knownHostsFile := cfg.KnownHosts
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, errors.Join(errors.New("invalid known_hosts file "+knownHostsFile), err)
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+
+	s := &SFTP{
+		pathCfg: pathCfg,
+		addr:    net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		sshConfig: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         15 * time.Second,
+		},
+	}
+	s.dialed = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+func (s *SFTP) Open(name string) (fs.File, error) {
+	var file fs.File
+	err := s.do("open", name, func(c *sftp.Client, fullPath string) error {
+		info, err := c.Stat(fullPath)
+		if err != nil {
+			return err
+		}
+		// Not every server lets folders be opened like files, list them instead
+		if info.IsDir() {
+			file = &dirFile{info: info, readDir: func() ([]fs.DirEntry, error) { return s.ReadDir(name) }}
+			return nil
+		}
+
+		f, err := c.Open(fullPath)
+		if err != nil {
+			return err
+		}
+		file = &sftpFile{File: f, info: info}
+		return nil
+	})
+	return file, err
+}
+
+func (s *SFTP) Stat(name string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := s.do("stat", name, func(c *sftp.Client, fullPath string) error {
+		var err error
+		info, err = c.Stat(fullPath)
+		return err
+	})
+	return info, err
+}
+
+func (s *SFTP) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	err := s.do("readdir", name, func(c *sftp.Client, fullPath string) error {
+		infos, err := c.ReadDir(fullPath)
+		if err != nil {
+			return err
+		}
+		entries = make([]fs.DirEntry, 0, len(infos))
+		for _, info := range infos {
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (s *SFTP) OpenRange(name string, offset, length int64) (io.ReadCloser, error) {
+	file, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return rangeOf(file, offset, length)
+}
+
+func (s *SFTP) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.dialed.Broadcast()
+	var errs []error
+	for _, conn := range slices.Clone(s.conns) {
+		errs = append(errs, s.disconnect(conn))
+	}
+	return errors.Join(errs...)
+}
+
+// Connection handling ////////////////////////////
+
+// do resolves name beneath the remote root and runs fn against a pooled client.
+// A call failing on a dropped connection is retried once on another.
+func (s *SFTP) do(op, name string, fn func(c *sftp.Client, fullPath string) error) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var conn *sftpConn
+		conn, err = s.acquire()
+		if err != nil {
+			break
+		}
+
+		var fullPath string
+		fullPath, err = s.resolve(conn.client, conn.realRoot, name)
+		if err == nil {
+			err = fn(conn.client, fullPath)
+		}
+
+		s.mu.Lock()
+		conn.busy--
+		if isConnError(err) {
+			_ = s.disconnect(conn)
+		}
+		s.mu.Unlock()
+
+		if !isConnError(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+// acquire picks the least busy connection, dialing another when all are busy &
+// the pool has room. Callers decrement the connection's busy count when done.
+func (s *SFTP) acquire() (*sftpConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if s.closed {
+			return nil, fs.ErrClosed
+		}
+
+		var idlest *sftpConn
+		for _, conn := range s.conns {
+			if idlest == nil || conn.busy < idlest.busy {
+				idlest = conn
+			}
+		}
+		full := len(s.conns)+s.dialing >= sftpPoolSize
+		if idlest != nil && (idlest.busy == 0 || full) {
+			idlest.busy++
+			return idlest, nil
+		}
+		if !full {
+			break
+		}
+		// Every connection is still being dialed
+		s.dialed.Wait()
+	}
+
+	s.dialing++
+	s.mu.Unlock()
+	conn, err := s.dial()
+	s.mu.Lock()
+	s.dialing--
+	s.dialed.Broadcast()
+
+	if err != nil {
+		return nil, err
+	}
+	if s.closed {
+		_ = conn.client.Close()
+		_ = conn.ssh.Close()
+		return nil, fs.ErrClosed
+	}
+	conn.busy = 1
+	s.conns = append(s.conns, conn)
+
+	// Drop the connection from the pool as soon as it closes
+	go func() {
+		_ = conn.ssh.Wait()
+		s.mu.Lock()
+		_ = s.disconnect(conn)
+		s.mu.Unlock()
+	}()
+
+	return conn, nil
+}
+
+func (s *SFTP) dial() (*sftpConn, error) {
+	conn, err := ssh.Dial("tcp", s.addr, s.sshConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	root := s.pathCfg.Path
+	if root == "" {
+		root = "."
+	}
+	realRoot, err := client.RealPath(root)
+	if err != nil {
+		_ = client.Close()
+		_ = conn.Close()
+		return nil, err
+	}
+	return &sftpConn{ssh: conn, client: client, realRoot: realRoot}, nil
+}
+
+// disconnect closes conn & removes it from the pool if it's still in it. Callers
+// hold s.mu.
+func (s *SFTP) disconnect(conn *sftpConn) error {
+	i := slices.Index(s.conns, conn)
+	if i < 0 {
+		return nil
+	}
+	s.conns = slices.Delete(s.conns, i, i+1)
+	_ = conn.client.Close()
+	err := conn.ssh.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// resolve joins name onto the remote root and, unless external symlinks are
+// allowed, makes sure the server-side resolved path stays beneath it
+func (s *SFTP) resolve(c *sftp.Client, root, name string) (string, error) {
+	if name == "." {
+		return root, nil
+	}
+
+	fullPath := path.Join(root, name)
+	if s.pathCfg.AllowExternalSymlinks {
+		return fullPath, nil
+	}
+
+	realPath, err := c.RealPath(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if realPath != root && !strings.HasPrefix(realPath, strings.TrimSuffix(root, "/")+"/") {
+		return "", resolver.ErrOutsideRoot
+	}
+	return fullPath, nil
+}
+
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.As(err, &netErr)
+}
+
+// sftpFile pairs an open remote file with the info it was opened with, so Stat
+// doesn't need another round trip
+type sftpFile struct {
+	*sftp.File
+	info fs.FileInfo
+}
+
+func (f *sftpFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
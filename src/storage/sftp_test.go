@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/patppuccin/viewr/src/models"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSFTPBackend(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, root, map[string]string{
+		"docs/notes.txt": "0123456789",
+		"docs/readme.md": "# Readme\n",
+		"top.txt":        "top",
+	})
+
+	srv := newTestSFTPServer(t)
+	backend, err := NewSFTP(models.PathConfig{Name: "remote", Type: "sftp", Path: root, SFTP: srv.config(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+
+	entries, err := backend.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entryNames(entries); !slices.Equal(got, []string{"docs/", "top.txt"}) {
+		t.Fatalf("ReadDir(.) = %v", got)
+	}
+
+	if info, err := backend.Stat("docs/notes.txt"); err != nil || info.Size() != 10 {
+		t.Fatalf("Stat(docs/notes.txt) = %v, %v, want 10 bytes", info, err)
+	}
+	if _, err := backend.Stat("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(missing.txt) error = %v, want fs.ErrNotExist", err)
+	}
+
+	rc, err := backend.OpenRange("docs/notes.txt", 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(got) != "3456" {
+		t.Fatalf("OpenRange(3, 4) = %q, want \"3456\"", got)
+	}
+
+	// Dropped connections are replaced transparently on the next call
+	srv.dropConnections()
+	if info, err := backend.Stat("top.txt"); err != nil || info.Size() != 3 {
+		t.Fatalf("Stat(top.txt) after reconnect = %v, %v, want 3 bytes", info, err)
+	}
+	if srv.dials() != 2 {
+		t.Fatalf("dials = %d, want 2", srv.dials())
+	}
+}
+
+func TestSFTPPool(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, root, map[string]string{"top.txt": "top"})
+
+	srv := newTestSFTPServer(t)
+	backend, err := NewSFTP(models.PathConfig{Name: "remote", Type: "sftp", Path: root, SFTP: srv.config(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = backend.Close()
+	})
+
+	// Concurrent calls spread over the pool, but never beyond its size
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for range 32 {
+		wg.Go(func() {
+			if _, err := backend.Stat("top.txt"); err != nil {
+				errs <- err
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if dials := srv.dials(); dials < 1 || dials > sftpPoolSize {
+		t.Fatalf("dials = %d, want 1 to %d", dials, sftpPoolSize)
+	}
+
+	// Idle connections are reused
+	dials := srv.dials()
+	if _, err := backend.Stat("top.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if srv.dials() != dials {
+		t.Fatalf("dials = %d after an idle call, want %d", srv.dials(), dials)
+	}
+
+	if err := backend.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Stat("top.txt"); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("Stat after Close error = %v, want fs.ErrClosed", err)
+	}
+}
+
+func TestNewSFTPRequiresHostKey(t *testing.T) {
+	srv := newTestSFTPServer(t)
+	cfg := srv.config(t)
+
+	// A known_hosts file without the server's key must refuse to connect
+	emptyKnownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(emptyKnownHosts, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.KnownHosts = emptyKnownHosts
+
+	backend, err := NewSFTP(models.PathConfig{Name: "remote", Type: "sftp", Path: t.TempDir(), SFTP: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Stat("."); err == nil {
+		t.Fatal("Stat succeeded against an unknown host key")
+	}
+}
+
+// Test helpers
+
+// testSFTPServer is an in-process SSH server offering only the sftp subsystem
+type testSFTPServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+	userKey  ed25519.PrivateKey
+
+	mu        sync.Mutex
+	conns     []net.Conn
+	dialCount int
+}
+
+func newTestSFTPServer(t *testing.T) *testSFTPServer {
+	t.Helper()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userPub, userPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizedKey, err := ssh.NewPublicKey(userPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &testSFTPServer{listener: listener, hostKey: hostKey, userKey: userPriv}
+	t.Cleanup(func() {
+		_ = listener.Close()
+		srv.dropConnections()
+	})
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	serverConfig.AddHostKey(hostKey)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.conns = append(srv.conns, conn)
+			srv.dialCount++
+			srv.mu.Unlock()
+			go serveTestSSH(conn, serverConfig)
+		}
+	}()
+
+	return srv
+}
+
+func serveTestSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel, sftp.ReadOnly())
+					if err == nil {
+						_ = server.Serve()
+					}
+					_ = channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+// config writes the client key & known_hosts files for connecting to the server
+func (s *testSFTPServer) config(t *testing.T) *models.SFTPConfig {
+	t.Helper()
+	dir := t.TempDir()
+
+	block, err := ssh.MarshalPrivateKey(s.userKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	knownHostsFile := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.listener.Addr().String())}, s.hostKey.PublicKey())
+	if err := os.WriteFile(knownHostsFile, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	portNum, _ := strconv.Atoi(port)
+	return &models.SFTPConfig{Host: host, Port: portNum, User: "viewr", KeyFile: keyFile, KnownHosts: knownHostsFile}
+}
+
+func (s *testSFTPServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testSFTPServer) dials() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dialCount
+}
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/patppuccin/viewr/src/models"
+)
+
+// Backend is the storage abstraction every handler goes through. Names follow
+// io/fs conventions: slash-separated, unrooted, with "." for the root itself.
+type Backend interface {
+	fs.FS
+	fs.StatFS
+	fs.ReadDirFS
+
+	// OpenRange reads length bytes starting at offset, or up to the end of the
+	// file when length is negative
+	OpenRange(name string, offset, length int64) (io.ReadCloser, error)
+
+	// Close releases connections & handles held by the backend
+	Close() error
+}
+
+var (
+	ErrUnsupportedType = errors.New("unsupported path type")
+	errNotDir          = errors.New("not a directory")
+)
+
+// New opens the backend for a configured path based on its type
+func New(pathCfg models.PathConfig) (Backend, error) {
+	switch strings.ToLower(pathCfg.Type) {
+	case "", "local":
+		return NewLocal(pathCfg)
+	case "zip":
+		return NewZip(pathCfg.Path)
+	case "s3":
+		return NewS3(pathCfg.S3)
+	case "sftp":
+		return NewSFTP(pathCfg)
+	default:
+		return nil, ErrUnsupportedType
+	}
+}
+
+// Name converts a cleaned relative path ("" for the root) into an fs.FS name
+func Name(relPath string) string {
+	if relPath == "" {
+		return "."
+	}
+	return relPath
+}
+
+// Mounts ////////////////////////////////////////
+
+// Mount pairs a configured path with its opened backend
+type Mount struct {
+	Config  models.PathConfig
+	Backend Backend
+}
+
+// Registry holds the backends of all enabled paths, in configuration order
+type Registry struct {
+	mounts []*Mount
+}
+
+// NewRegistry opens a backend for every enabled path, with archives browsable as
+// folders. Paths that fail to open are left out and reported through the returned
+// error, the rest stay usable.
+func NewRegistry(paths []models.PathConfig) (*Registry, error) {
+	registry := &Registry{}
+	var errs []error
+
+	for _, pathCfg := range paths {
+		if pathCfg.Disable {
+			continue
+		}
+		if _, exists := registry.Get(pathCfg.Name); exists {
+			errs = append(errs, errors.New("duplicate path name "+pathCfg.Name))
+			continue
+		}
+
+		backend, err := New(pathCfg)
+		if err != nil {
+			errs = append(errs, errors.Join(errors.New("failed to open path "+pathCfg.Name), err))
+			continue
+		}
+		registry.mounts = append(registry.mounts, &Mount{Config: pathCfg, Backend: WithArchives(backend)})
+	}
+
+	return registry, errors.Join(errs...)
+}
+
+// NewRegistryFrom builds a registry from already opened mounts
+func NewRegistryFrom(mounts ...*Mount) *Registry {
+	return &Registry{mounts: slices.Clone(mounts)}
+}
+
+func (r *Registry) Get(name string) (*Mount, bool) {
+	for _, m := range r.mounts {
+		if m.Config.Name == name {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Registry) List() []*Mount {
+	return slices.Clone(r.mounts)
+}
+
+func (r *Registry) Close() error {
+	var errs []error
+	for _, m := range r.mounts {
+		if err := m.Backend.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Generic fs.FS adapter //////////////////////////
+
+// FromFS adapts any fs.FS (e.g. an fstest.MapFS or a zip.Reader) into a Backend.
+// Range reads seek when the opened file supports it and discard otherwise.
+func FromFS(fsys fs.FS) Backend {
+	return &fsBackend{fsys: fsys}
+}
+
+type fsBackend struct {
+	fsys fs.FS
+}
+
+func (b *fsBackend) Open(name string) (fs.File, error) {
+	return b.fsys.Open(name)
+}
+
+func (b *fsBackend) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(b.fsys, name)
+}
+
+func (b *fsBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(b.fsys, name)
+}
+
+func (b *fsBackend) OpenRange(name string, offset, length int64) (io.ReadCloser, error) {
+	file, err := b.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return rangeOf(file, offset, length)
+}
+
+func (b *fsBackend) Close() error {
+	if closer, ok := b.fsys.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Local helpers
+
+// rangeOf narrows an opened file down to the requested window, taking ownership
+// of the file
+func rangeOf(file fs.File, offset, length int64) (io.ReadCloser, error) {
+	// Not every fs.File allows seeking past the end, clamp to the size
+	if info, err := file.Stat(); err == nil && info.Mode().IsRegular() && offset > info.Size() {
+		offset = info.Size()
+	}
+
+	if offset > 0 {
+		var err error
+		if seeker, ok := file.(io.Seeker); ok {
+			_, err = seeker.Seek(offset, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, file, offset)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+
+	var r io.Reader = file
+	if length >= 0 {
+		r = io.LimitReader(file, length)
+	}
+	return readCloser{Reader: r, Closer: file}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Remote helpers
+
+// fileInfo describes entries of backends that don't have native fs.FileInfo values
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.isDir }
+func (i *fileInfo) Sys() any           { return nil }
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.isDir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// dirFile is an opened folder of a remote backend, listed on demand
+type dirFile struct {
+	info    fs.FileInfo
+	readDir func() ([]fs.DirEntry, error)
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.readDir()
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
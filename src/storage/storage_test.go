@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/patppuccin/viewr/src/models"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"docs/readme.md":  {Data: []byte("# Readme\n")},
+		"docs/notes.txt":  {Data: []byte("0123456789")},
+		"media/empty.bin": {Data: []byte{}},
+	}
+}
+
+func TestFromFS(t *testing.T) {
+	backend := FromFS(testFS())
+
+	entries, err := backend.ReadDir("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "notes.txt" || entries[1].Name() != "readme.md" {
+		t.Fatalf("ReadDir(docs) = %v, want [notes.txt readme.md]", entries)
+	}
+
+	info, err := backend.Stat("docs/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 10 {
+		t.Fatalf("Stat size = %d, want 10", info.Size())
+	}
+
+	if _, err := backend.Stat("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(missing) error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestOpenRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+		want   string
+	}{
+		{name: "whole file", offset: 0, length: -1, want: "0123456789"},
+		{name: "prefix", offset: 0, length: 4, want: "0123"},
+		{name: "middle", offset: 3, length: 4, want: "3456"},
+		{name: "suffix", offset: 7, length: -1, want: "789"},
+		{name: "length past end", offset: 8, length: 100, want: "89"},
+		{name: "offset past end", offset: 20, length: -1, want: ""},
+	}
+
+	backends := map[string]Backend{
+		"seekable":     FromFS(testFS()),
+		"non-seekable": FromFS(noSeekFS{testFS()}),
+	}
+
+	for backendName, backend := range backends {
+		for _, tt := range tests {
+			t.Run(backendName+"/"+tt.name, func(t *testing.T) {
+				rc, err := backend.OpenRange("docs/notes.txt", tt.offset, tt.length)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer func() {
+					_ = rc.Close()
+				}()
+
+				got, err := io.ReadAll(rc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want {
+					t.Fatalf("OpenRange(%d, %d) = %q, want %q", tt.offset, tt.length, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestOpenReadSeeker(t *testing.T) {
+	backend := FromFS(noSeekFS{testFS()})
+
+	rs, info, err := OpenReadSeeker(backend, "docs/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = rs.Close()
+	}()
+
+	if _, ok := rs.(*rangeSeeker); !ok {
+		t.Fatalf("OpenReadSeeker returned %T, want *rangeSeeker for non-seekable files", rs)
+	}
+	if info.Size() != 10 {
+		t.Fatalf("size = %d, want 10", info.Size())
+	}
+
+	// Same access pattern as http.ServeContent: size probe, then ranged reads
+	if end, err := rs.Seek(0, io.SeekEnd); err != nil || end != 10 {
+		t.Fatalf("Seek(0, SeekEnd) = %d, %v, want 10", end, err)
+	}
+	if _, err := rs.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(rs, buf); err != nil || string(buf) != "67" {
+		t.Fatalf("read after seek = %q, %v, want \"67\"", buf, err)
+	}
+	if _, err := rs.Seek(1, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(rs)
+	if err != nil || string(rest) != "123456789" {
+		t.Fatalf("read after seeking back = %q, %v, want \"123456789\"", rest, err)
+	}
+}
+
+func TestOpenReaderAt(t *testing.T) {
+	backend := FromFS(noSeekFS{testFS()})
+
+	ra, info, err := OpenReaderAt(backend, "docs/notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = ra.Close()
+	}()
+
+	if _, ok := ra.(*rangeReaderAt); !ok {
+		t.Fatalf("OpenReaderAt returned %T, want *rangeReaderAt for files without ReadAt", ra)
+	}
+	if info.Size() != 10 {
+		t.Fatalf("size = %d, want 10", info.Size())
+	}
+
+	buf := make([]byte, 3)
+	for _, tt := range []struct {
+		offset int64
+		want   string
+	}{{7, "789"}, {2, "234"}, {5, "567"}} {
+		if _, err := ra.ReadAt(buf, tt.offset); err != nil || string(buf) != tt.want {
+			t.Fatalf("ReadAt(%d) = %q, %v, want %q", tt.offset, buf, err, tt.want)
+		}
+	}
+	if n, err := ra.ReadAt(buf, 8); n != 2 || !errors.Is(err, io.EOF) {
+		t.Fatalf("ReadAt past the end = %d, %v, want 2, EOF", n, err)
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	root := t.TempDir()
+
+	registry, err := NewRegistry([]models.PathConfig{
+		{Name: "local", Path: root},
+		{Name: "disabled", Path: root, Disable: true},
+		{Name: "missing", Path: root + "/missing"},
+		{Name: "unknown", Path: root, Type: "floppy"},
+		{Name: "local", Path: root},
+	})
+	if err == nil {
+		t.Fatal("NewRegistry error = nil, want errors for missing, unknown & duplicate paths")
+	}
+
+	if mounts := registry.List(); len(mounts) != 1 || mounts[0].Config.Name != "local" {
+		t.Fatalf("List() = %v, want only the local mount", mounts)
+	}
+	if _, ok := registry.Get("disabled"); ok {
+		t.Fatal("Get(disabled) found a mount for a disabled path")
+	}
+}
+
+// Test helpers
+
+// noSeekFS hides io.Seeker from opened files, like a remote object stream
+type noSeekFS struct {
+	fsys fs.FS
+}
+
+func (n noSeekFS) Open(name string) (fs.File, error) {
+	file, err := n.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return noSeekFile{file}, nil
+}
+
+type noSeekFile struct {
+	fs.File
+}
@@ -0,0 +1,14 @@
+package storage
+
+import (
+	"archive/zip"
+)
+
+// NewZip exposes the contents of a ZIP file as a read-only tree
+func NewZip(zipPath string) (Backend, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	return FromFS(reader), nil
+}
@@ -0,0 +1,293 @@
+package table
+
+import (
+	"bytes"
+	"cmp"
+	"container/list"
+	"encoding/csv"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const sniffRecords = 20 // Records of the sample parsed to pick a delimiter
+
+// Delimiters tried when detecting one, by name
+var Delimiters = map[string]rune{"comma": ',', "tab": '\t', "semicolon": ';', "pipe": '|'}
+
+// OpenFunc opens a file from the given byte offset
+type OpenFunc func(offset int64) (io.ReadCloser, error)
+
+// Page is a window of a delimited file's rows
+type Page struct {
+	Header    []string
+	Rows      [][]string
+	Page      int   // 0-based, clamped to the last page
+	HasMore   bool  // Rows follow this page
+	TotalRows int   // Rows after the header, -1 until the end was reached once
+	Offset    int64 // Where in the file the page starts
+}
+
+// Index remembers where the pages of recently read files start, so paging deep
+// into large files doesn't read them from the start again. It keeps the least
+// recently used files' offsets out once it holds maxFiles.
+type Index struct {
+	maxFiles int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // Key -> element of lru
+	lru     *list.List               // Of *entry, most recently used first
+}
+
+type entry struct {
+	key    string
+	starts []int64 // Start offsets of the pages read past so far
+	total  int     // Rows after the header, -1 when unknown
+}
+
+func NewIndex(maxFiles int) *Index {
+	return &Index{
+		maxFiles: max(1, maxFiles),
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Detect picks the delimiter of a file from a sample of its start: the one that
+// splits its records into the most fields, the same number on each.
+func Detect(sample []byte) rune {
+	// A record cut off at the end of the sample would throw the counts off
+	if i := bytes.LastIndexByte(sample, '\n'); i > 0 {
+		sample = sample[:i+1]
+	}
+
+	best, bestScore := ',', 0
+	for _, delim := range []rune{',', '\t', ';', '|'} {
+		r := newReader(bytes.NewReader(sample), delim)
+		counts := map[int]int{}
+		records := 0
+		for records < sniffRecords {
+			record, err := r.Read()
+			if err != nil {
+				break
+			}
+			counts[len(record)]++
+			records++
+		}
+
+		// The most common field count, counted as often as it occurs
+		fields, times := 0, 0
+		for n, c := range counts {
+			if c > times || (c == times && n > fields) {
+				fields, times = n, c
+			}
+		}
+		if fields < 2 {
+			continue
+		}
+		if score := times*100 + fields; score > bestScore {
+			best, bestScore = delim, score
+		}
+	}
+	return best
+}
+
+// ReadPage reads the header & the rows of page number page, of size rows each,
+// from the file open opens. The file is told apart from others by key, which
+// should change along with the file.
+func (ix *Index) ReadPage(open OpenFunc, key string, delim rune, page, size int) (*Page, error) {
+	size = max(1, size)
+	page = max(0, page)
+	indexKey := key + "\x00" + string(delim) + "\x00" + strconv.Itoa(size)
+
+	header, dataStart, err := readHeader(open, delim)
+	if err != nil || header == nil {
+		return &Page{Header: header, TotalRows: 0}, err
+	}
+
+	starts, total := ix.lookup(indexKey, dataStart)
+	if total >= 0 {
+		page = min(page, max(0, (total-1)/size))
+	}
+
+	// Read on from the closest page start known
+	from := min(page, len(starts)-1)
+	rc, err := open(starts[from])
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	r := newReader(rc, delim)
+	base := starts[from]
+
+	res := &Page{Header: header, Page: page, TotalRows: total, Offset: -1}
+	row := from * size
+	for {
+		offset := base + r.InputOffset()
+		if row%size == 0 && row/size == len(starts) {
+			starts = append(starts, offset)
+		}
+		if row == (page+1)*size {
+			// One more record tells whether there's a next page
+			if _, err := r.Read(); err == nil {
+				res.HasMore = true
+			} else if !errors.Is(err, io.EOF) {
+				return nil, err
+			} else {
+				total = row
+			}
+			break
+		}
+
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			total = row
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row >= page*size {
+			if res.Offset < 0 {
+				res.Offset = offset
+			}
+			res.Rows = append(res.Rows, slices.Clone(record))
+		}
+		row++
+	}
+	ix.store(indexKey, starts, total)
+	res.TotalRows = total
+
+	// Past the end, the last page is read instead
+	if len(res.Rows) == 0 && total > 0 {
+		return ix.ReadPage(open, key, delim, (total-1)/size, size)
+	}
+	if res.Offset < 0 {
+		res.Offset = dataStart
+	}
+	return res, nil
+}
+
+// SortRows orders rows by a column, see Compare
+func SortRows(rows [][]string, column int, desc bool) {
+	slices.SortStableFunc(rows, Compare(column, desc))
+}
+
+// Compare compares rows by a column, as numbers when both values are ones & as
+// text ignoring case otherwise. Rows missing the column come last.
+func Compare(column int, desc bool) func(a, b []string) int {
+	return func(a, b []string) int {
+		switch {
+		case column >= len(a) && column >= len(b):
+			return 0
+		case column >= len(a):
+			return 1
+		case column >= len(b):
+			return -1
+		}
+		c := compareValues(a[column], b[column])
+		if desc {
+			return -c
+		}
+		return c
+	}
+}
+
+// Table helpers
+
+func newReader(r io.Reader, delim rune) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.Comma = delim
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.ReuseRecord = true
+	return cr
+}
+
+// readHeader reads the first record, returning where the rows after it start
+func readHeader(open OpenFunc, delim rune) ([]string, int64, error) {
+	rc, err := open(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	// A byte order mark isn't part of the first column's name
+	var base int64
+	var src io.Reader = rc
+	bom := make([]byte, 3)
+	n, err := io.ReadFull(rc, bom)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, 0, err
+	}
+	if n == 3 && string(bom) == "\xef\xbb\xbf" {
+		base = 3
+	} else {
+		src = io.MultiReader(bytes.NewReader(bom[:n]), rc)
+	}
+
+	r := newReader(src, delim)
+	record, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return slices.Clone(record), base + r.InputOffset(), nil
+}
+
+func (ix *Index) lookup(key string, dataStart int64) ([]int64, int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if el, ok := ix.entries[key]; ok {
+		ix.lru.MoveToFront(el)
+		e := el.Value.(*entry)
+		return slices.Clone(e.starts), e.total
+	}
+	return []int64{dataStart}, -1
+}
+
+func (ix *Index) store(key string, starts []int64, total int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if el, ok := ix.entries[key]; ok {
+		e := el.Value.(*entry)
+		if len(starts) > len(e.starts) {
+			e.starts = starts
+		}
+		if total >= 0 {
+			e.total = total
+		}
+		ix.lru.MoveToFront(el)
+		return
+	}
+	ix.entries[key] = ix.lru.PushFront(&entry{key: key, starts: starts, total: total})
+	for ix.lru.Len() > ix.maxFiles {
+		oldest := ix.lru.Back()
+		ix.lru.Remove(oldest)
+		delete(ix.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// compareValues compares numbers as such & the rest as text, ignoring case
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	y, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(x, y)
+	case errA == nil:
+		return -1 // Numbers before text
+	case errB == nil:
+		return 1
+	}
+	return cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+}
@@ -0,0 +1,104 @@
+package table
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{name: "comma", sample: "a,b,c\n1,2,3\n4,5,6\n", want: ','},
+		{name: "tab", sample: "a\tb\n1,5\t2\n3,5\t4\n", want: '\t'},
+		{name: "semicolon", sample: "name;price\n\"x;y\";1,5\nz;2,25\n", want: ';'},
+		{name: "pipe", sample: "a|b|c\n1|2|3\n4|5|6", want: '|'},
+		{name: "cut off", sample: "a;b;c\n1;2;3\n4;5;6\n7,8", want: ';'},
+		{name: "one column", sample: "a\nb\n", want: ','},
+	}
+	for _, tt := range tests {
+		if got := Detect([]byte(tt.sample)); got != tt.want {
+			t.Errorf("Detect(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadPage(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("\xef\xbb\xbfid,note\n")
+	for i := 1; i <= 25; i++ {
+		buf.WriteString(strconv.Itoa(i) + ",\"line\nbreak " + strconv.Itoa(i) + "\"\n")
+	}
+	data := buf.Bytes()
+
+	var opened []int64
+	open := func(offset int64) (io.ReadCloser, error) {
+		opened = append(opened, offset)
+		return io.NopCloser(bytes.NewReader(data[offset:])), nil
+	}
+	ix := NewIndex(4)
+
+	page, err := ix.ReadPage(open, "report.csv", ',', 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(page.Header, []string{"id", "note"}) || len(page.Rows) != 10 || page.Rows[0][0] != "11" || !page.HasMore || page.TotalRows != -1 {
+		t.Fatalf("page 1 = %+v", page)
+	}
+	if !strings.HasPrefix(string(data[page.Offset:]), "11,") {
+		t.Fatalf("page 1 offset %d points at %q", page.Offset, data[page.Offset:page.Offset+5])
+	}
+
+	// The last page starts where the index says, without reading the first ones
+	opened = nil
+	page, err = ix.ReadPage(open, "report.csv", ',', 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Rows) != 5 || page.Rows[4][1] != "line\nbreak 25" || page.HasMore || page.TotalRows != 25 {
+		t.Fatalf("page 2 = %+v", page)
+	}
+	if len(opened) != 2 || opened[1] != page.Offset {
+		t.Fatalf("opened at %v, want the header & page offset %d", opened, page.Offset)
+	}
+
+	// Pages past the end read as the last one
+	page, err = ix.ReadPage(open, "report.csv", ',', 9, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Page != 2 || page.Rows[0][0] != "21" {
+		t.Fatalf("page 9 = %+v", page)
+	}
+	page, err = NewIndex(1).ReadPage(open, "report.csv", ',', 9, 10)
+	if err != nil || page.Page != 2 || page.Rows[0][0] != "21" {
+		t.Fatalf("page 9 unindexed = %+v, %v", page, err)
+	}
+}
+
+func TestSortRows(t *testing.T) {
+	rows := [][]string{{"b", "10"}, {"a", "9"}, {"c"}, {"B", "x"}, {"d", "-1.5"}}
+	SortRows(rows, 1, false)
+	var got []string
+	for _, row := range rows {
+		got = append(got, row[0])
+	}
+	if want := []string{"d", "a", "b", "B", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("SortRows() = %v, want %v", got, want)
+	}
+
+	SortRows(rows, 0, true)
+	got = got[:0]
+	for _, row := range rows {
+		got = append(got, row[0])
+	}
+	if want := []string{"d", "c", "b", "B", "a"}; !slices.Equal(got, want) {
+		t.Fatalf("SortRows() descending = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,344 @@
+package thumbs
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patppuccin/viewr/src/meta"
+	"golang.org/x/image/draw"
+
+	// Decoders for image.Decode
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	maxSourceBytes  = 64 << 20 // Larger files aren't read to thumbnail them
+	maxSourcePixels = 64e6     // Nor are images that would decode into more pixels
+	jpegQuality     = 82
+	keyVersion      = "2" // Bumped when thumbnails change, so cached ones are made again
+)
+
+// Sizes are the supported thumbnail edge lengths, in pixels
+var Sizes = []int{128, 256, 512}
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image is too large to thumbnail")
+)
+
+// Extensions of the formats thumbnails are made of
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+
+// Cache keeps generated thumbnails on disk, dropping the least recently used ones
+// once it outgrows its size cap
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element // Key -> element of lru
+	lru      *list.List               // Of *entry, most recently used first
+	total    int64
+	inflight map[string]*call
+	slots    chan struct{} // Bounds concurrent decodes, they're memory hungry
+}
+
+// Thumbnail is a generated thumbnail file
+type Thumbnail struct {
+	File        string
+	ContentType string
+	ETag        string
+}
+
+type entry struct {
+	key  string
+	file string
+	size int64
+}
+
+type call struct {
+	done  chan struct{}
+	thumb Thumbnail
+	err   error
+}
+
+// Supported reports whether thumbnails can be made of a file, by its name
+func Supported(name string) bool {
+	return slices.Contains(imageExtensions, strings.ToLower(path.Ext(name)))
+}
+
+// New opens the cache in dir, picking up thumbnails of earlier runs
+func New(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		inflight: map[string]*call{},
+		slots:    make(chan struct{}, runtime.NumCPU()),
+	}
+
+	// Earlier runs left their access times as file mtimes
+	type found struct {
+		entry
+		used time.Time
+	}
+	var files []found
+	err := filepath.WalkDir(dir, func(p string, de fs.DirEntry, err error) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(p, ".tmp") {
+			_ = os.Remove(p) // Left behind by an interrupted write
+			return nil
+		}
+		info, err := de.Info()
+		if err != nil {
+			return nil
+		}
+		key := strings.TrimSuffix(de.Name(), filepath.Ext(de.Name()))
+		files = append(files, found{entry{key: key, file: p, size: info.Size()}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(files, func(a, b found) int { return a.used.Compare(b.used) })
+	for _, f := range files {
+		c.entries[f.key] = c.lru.PushFront(&f.entry)
+		c.total += f.size
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Get returns the thumbnail of name in fsys at the given size, generating it when
+// the cache doesn't have it yet. id tells files of different file systems apart,
+// info is the current state of the file, so changed files get new thumbnails.
+func (c *Cache) Get(fsys fs.FS, id, name string, info fs.FileInfo, size int) (Thumbnail, error) {
+	if !Supported(name) || !slices.Contains(Sizes, size) {
+		return Thumbnail{}, ErrUnsupported
+	}
+	if info.Size() > maxSourceBytes {
+		return Thumbnail{}, ErrTooLarge
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		keyVersion, id, name, strconv.Itoa(size), strconv.FormatInt(info.Size(), 10), strconv.FormatInt(info.ModTime().UnixNano(), 10),
+	}, "\x00")))
+	key := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		e := el.Value.(*entry)
+		c.mu.Unlock()
+
+		now := time.Now()
+		_ = os.Chtimes(e.file, now, now) // Keeps the order across restarts
+		return thumbnailOf(e), nil
+	}
+
+	// Requests for the same thumbnail wait for the one generating it
+	if cl, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-cl.done
+		return cl.thumb, cl.err
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inflight[key] = cl
+	c.mu.Unlock()
+
+	cl.thumb, cl.err = c.generate(fsys, name, key, size)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(cl.done)
+	return cl.thumb, cl.err
+}
+
+func (c *Cache) generate(fsys fs.FS, name, key string, size int) (Thumbnail, error) {
+	c.slots <- struct{}{}
+	defer func() {
+		<-c.slots
+	}()
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		return Thumbnail{}, err
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxSourceBytes+1))
+	_ = file.Close()
+	if err != nil {
+		return Thumbnail{}, err
+	}
+	if len(data) > maxSourceBytes {
+		return Thumbnail{}, ErrTooLarge
+	}
+
+	// Check the dimensions before decoding whole images
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Thumbnail{}, errors.Join(ErrUnsupported, err)
+	}
+	if float64(cfg.Width)*float64(cfg.Height) > maxSourcePixels {
+		return Thumbnail{}, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Thumbnail{}, errors.Join(ErrUnsupported, err)
+	}
+
+	// Thumbnails lose the EXIF data, so its orientation is applied to the pixels
+	thumb := resize(src, size)
+	if x, err := meta.ReadEXIF(data); err == nil {
+		thumb = orient(thumb, x.Orientation)
+	}
+
+	// Opaque thumbnails as JPEG, others keep their transparency as PNG
+	var buf bytes.Buffer
+	ext := ".jpg"
+	if opaque, ok := thumb.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		ext = ".png"
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return Thumbnail{}, err
+	}
+
+	e := &entry{key: key, file: filepath.Join(c.dir, key[:2], key+ext), size: int64(buf.Len())}
+	if err := writeFile(e.file, buf.Bytes()); err != nil {
+		return Thumbnail{}, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = c.lru.PushFront(e)
+	c.total += e.size
+	c.evict()
+	c.mu.Unlock()
+	return thumbnailOf(e), nil
+}
+
+// evict drops the least recently used thumbnails until the cache fits its cap,
+// always keeping the newest one. c.mu must be held.
+func (c *Cache) evict() {
+	for c.total > c.maxBytes && c.lru.Len() > 1 {
+		e := c.lru.Remove(c.lru.Back()).(*entry)
+		delete(c.entries, e.key)
+		c.total -= e.size
+		_ = os.Remove(e.file)
+	}
+}
+
+// Thumbnail helpers
+
+// resize scales an image down to fit within size x size, smaller images are
+// left as they are
+func resize(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	if w >= h {
+		w, h = size, max(1, h*size/w)
+	} else {
+		w, h = max(1, w*size/h), size
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// orient turns an image the way an EXIF orientation (2-8) says it's meant to be
+// shown
+func orient(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 { // Turned sideways
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // Mirrored
+				dx, dy = w-1-x, y
+			case 3: // Upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // Flipped
+				dx, dy = x, h-1-y
+			case 5: // Transposed
+				dx, dy = y, x
+			case 6: // Turned clockwise
+				dx, dy = h-1-y, x
+			case 7: // Transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // Turned counterclockwise
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// writeFile writes through a temporary file, so readers never see partial files
+func writeFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func thumbnailOf(e *entry) Thumbnail {
+	contentType := "image/jpeg"
+	if strings.HasSuffix(e.file, ".png") {
+		contentType = "image/png"
+	}
+	return Thumbnail{File: e.file, ContentType: contentType, ETag: `"` + e.key[:32] + `"`}
+}
@@ -0,0 +1,182 @@
+package thumbs
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	files := fstest.MapFS{
+		"photo.jpg":  {Data: encodeTest(t, "jpeg", 800, 400, 255), ModTime: time.Unix(1, 0)},
+		"icon.png":   {Data: encodeTest(t, "png", 64, 64, 128), ModTime: time.Unix(1, 0)},
+		"broken.png": {Data: []byte("not a png")},
+		"notes.txt":  {Data: []byte("text")},
+	}
+	cache, err := New(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumb := getThumb(t, cache, files, "photo.jpg", 256)
+	if thumb.ContentType != "image/jpeg" {
+		t.Fatalf("ContentType = %s, want image/jpeg", thumb.ContentType)
+	}
+	if w, h := decodeSize(t, thumb.File); w != 256 || h != 128 {
+		t.Fatalf("thumbnail is %dx%d, want 256x128", w, h)
+	}
+	if again := getThumb(t, cache, files, "photo.jpg", 256); again != thumb {
+		t.Fatalf("second Get() = %+v, want cached %+v", again, thumb)
+	}
+
+	// Transparent images stay PNG & small ones aren't scaled up
+	icon := getThumb(t, cache, files, "icon.png", 128)
+	if w, h := decodeSize(t, icon.File); icon.ContentType != "image/png" || w != 64 || h != 64 {
+		t.Fatalf("icon thumbnail = %+v, %dx%d, want a 64x64 PNG", icon, w, h)
+	}
+
+	// Changed files get new thumbnails
+	files["photo.jpg"].ModTime = time.Unix(2, 0)
+	if changed := getThumb(t, cache, files, "photo.jpg", 256); changed.File == thumb.File {
+		t.Fatal("Get() returned the thumbnail of the previous version")
+	}
+
+	for name, size := range map[string]int{"broken.png": 256, "notes.txt": 256, "photo.jpg": 100} {
+		info, _ := fs.Stat(files, name)
+		if _, err := cache.Get(files, "test", name, info, size); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("Get(%s, %d) error = %v, want ErrUnsupported", name, size, err)
+		}
+	}
+}
+
+func TestEviction(t *testing.T) {
+	files := fstest.MapFS{}
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		files[name] = &fstest.MapFile{Data: encodeTest(t, "jpeg", 600, 600, 255)}
+	}
+
+	dir := t.TempDir()
+	probe, err := New(t.TempDir(), 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	one := getThumb(t, probe, files, "a.jpg", 512)
+	info, _ := os.Stat(one.File)
+
+	// Room for two thumbnails only, a is used again before c comes in
+	cache, err := New(dir, 2*info.Size()+info.Size()/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := getThumb(t, cache, files, "a.jpg", 512)
+	b := getThumb(t, cache, files, "b.jpg", 512)
+	getThumb(t, cache, files, "a.jpg", 512)
+	getThumb(t, cache, files, "c.jpg", 512)
+
+	if _, err := os.Stat(b.File); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("least recently used thumbnail still exists: %v", err)
+	}
+	if _, err := os.Stat(a.File); err != nil {
+		t.Fatalf("recently used thumbnail was evicted: %v", err)
+	}
+
+	// A restarted cache picks the remaining thumbnails up again
+	reopened, err := New(dir, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.lru.Len() != 2 {
+		t.Fatalf("reopened cache holds %d thumbnails, want 2", reopened.lru.Len())
+	}
+}
+
+// Test helpers
+
+func getThumb(t *testing.T, cache *Cache, files fs.FS, name string, size int) Thumbnail {
+	t.Helper()
+	info, err := fs.Stat(files, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := cache.Get(files, "test", name, info, size)
+	if err != nil {
+		t.Fatalf("Get(%s, %d) error = %v", name, size, err)
+	}
+	return thumb
+}
+
+func encodeTest(t *testing.T, format string, w, h int, alpha uint8) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: alpha})
+		}
+	}
+	var buf bytes.Buffer
+	encode := png.Encode
+	if format == "jpeg" {
+		encode = func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) }
+	}
+	if err := encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeSize(t *testing.T, name string) (int, int) {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestOrient(t *testing.T) {
+	// A 2x1 image, red on the left & blue on the right
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	for orientation, want := range map[int][]color.RGBA{
+		1: {red, blue}, // Left to right
+		2: {blue, red}, // Left to right
+		6: {red, blue}, // Top to bottom
+		8: {blue, red}, // Top to bottom
+		3: {blue, red}, // Left to right
+		9: {red, blue}, // Invalid, left as it is
+	} {
+		dst := orient(src, orientation)
+		b := dst.Bounds()
+		var got []color.RGBA
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				got = append(got, color.RGBAModel.Convert(dst.At(x, y)).(color.RGBA))
+			}
+		}
+		if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("orient(%d) = %v, want %v", orientation, got, want)
+		}
+		if sideways := orientation >= 5 && orientation <= 8; sideways != (b.Dy() == 2) {
+			t.Fatalf("orient(%d) is %dx%d", orientation, b.Dx(), b.Dy())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package tree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// parseJSON parses JSON a token at a time, keeping the order of keys. Several
+// values one after another, as in JSON Lines, make up an array.
+func (p *parser) parseJSON(r io.Reader) (*Node, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var values []*Node
+	for i := 0; ; i++ {
+		n, err := p.jsonValue(dec, "", i, 1)
+		if errors.Is(err, io.EOF) && n == nil && i > 0 {
+			break
+		}
+		if n != nil {
+			values = append(values, n)
+		}
+		if err != nil {
+			return wrapValues(values), err
+		}
+	}
+
+	if len(values) == 1 {
+		values[0].Index = -1
+		return values[0], nil
+	}
+	return wrapValues(values), nil
+}
+
+// jsonValue reads the next value of a document, with its children
+func (p *parser) jsonValue(dec *json.Decoder, key string, index, depth int) (*Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		kind := KindArray
+		if tok == '{' {
+			kind = KindObject
+		}
+		n, err := p.node(key, index, depth, kind)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; dec.More(); i++ {
+			childKey, childIndex := "", i
+			if kind == KindObject {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return n, err
+				}
+				childKey, childIndex = keyTok.(string), -1
+			}
+			child, err := p.jsonValue(dec, childKey, childIndex, depth+1)
+			n.add(child)
+			if err != nil {
+				return n, err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // The closing delimiter
+			return n, err
+		}
+		return n, nil
+	case string:
+		return p.scalar(key, index, depth, KindString, tok)
+	case json.Number:
+		return p.scalar(key, index, depth, KindNumber, tok.String())
+	case bool:
+		return p.scalar(key, index, depth, KindBool, strconv.FormatBool(tok))
+	}
+	return p.scalar(key, index, depth, KindNull, "null")
+}
+
+// JSON helpers
+
+// wrapValues makes an array root of a document's values
+func wrapValues(values []*Node) *Node {
+	if len(values) == 0 {
+		return nil
+	}
+	return &Node{Index: -1, Kind: KindArray, Children: values}
+}
+
+// prettyJSON indents each value of a document
+func prettyJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Indent(&out, raw, "", "  "); err != nil {
+			return nil, err
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
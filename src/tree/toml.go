@@ -0,0 +1,105 @@
+package tree
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// parseTOML parses a TOML document, keeping keys in the order they're written
+func (p *parser) parseTOML(data []byte) (*Node, error) {
+	var doc map[string]any
+	md, err := toml.Decode(string(data), &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Where each key is first written, by its dotted path
+	order := map[string]int{}
+	for i, key := range md.Keys() {
+		if _, ok := order[strings.Join(key, "\x00")]; !ok {
+			order[strings.Join(key, "\x00")] = i
+		}
+	}
+	return p.tomlValue(doc, "", -1, nil, order, 1)
+}
+
+// tomlValue converts a decoded value, keyPath leading to it without array indexes
+// as the order of keys is kept that way
+func (p *parser) tomlValue(v any, key string, index int, keyPath []string, order map[string]int, depth int) (*Node, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		n, err := p.node(key, index, depth, KindObject)
+		if n == nil || err != nil {
+			return nil, err
+		}
+		position := func(k string) int {
+			if i, ok := order[strings.Join(append(slices.Clip(keyPath), k), "\x00")]; ok {
+				return i
+			}
+			return len(order)
+		}
+		keys := slices.SortedFunc(maps.Keys(v), func(a, b string) int {
+			return cmp.Or(cmp.Compare(position(a), position(b)), strings.Compare(a, b))
+		})
+		for _, k := range keys {
+			child, err := p.tomlValue(v[k], k, -1, append(slices.Clip(keyPath), k), order, depth+1)
+			n.add(child)
+			if err != nil {
+				return n, err
+			}
+		}
+		return n, nil
+	case []map[string]any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return p.tomlValue(items, key, index, keyPath, order, depth)
+	case []any:
+		n, err := p.node(key, index, depth, KindArray)
+		if n == nil || err != nil {
+			return nil, err
+		}
+		for i, item := range v {
+			child, err := p.tomlValue(item, "", i, keyPath, order, depth+1)
+			n.add(child)
+			if err != nil {
+				return n, err
+			}
+		}
+		return n, nil
+	case string:
+		return p.scalar(key, index, depth, KindString, v)
+	case int64:
+		return p.scalar(key, index, depth, KindNumber, strconv.FormatInt(v, 10))
+	case float64:
+		return p.scalar(key, index, depth, KindNumber, strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		return p.scalar(key, index, depth, KindBool, strconv.FormatBool(v))
+	case time.Time:
+		return p.scalar(key, index, depth, KindString, tomlTime(v))
+	}
+	return p.scalar(key, index, depth, KindNull, "null") // TOML has no other values
+}
+
+// TOML helpers
+
+// tomlTime writes a date & time as the document would, leaving out the parts
+// local dates & times don't have
+func tomlTime(t time.Time) string {
+	switch t.Location().String() {
+	case "date-local":
+		return t.Format(time.DateOnly)
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
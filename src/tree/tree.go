@@ -0,0 +1,246 @@
+package tree
+
+import (
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Formats of structured data files
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatXML  = "xml"
+)
+
+// Kinds of nodes
+const (
+	KindObject  = "object"
+	KindArray   = "array"
+	KindElement = "element" // XML elements, holding attributes, text & elements
+	KindString  = "string"
+	KindNumber  = "number"
+	KindBool    = "bool"
+	KindNull    = "null"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported data format")
+	ErrTooLarge    = errors.New("document is too large to parse")
+
+	errFull = errors.New("node limit reached") // Unwinds parsing once the tree is full
+)
+
+// Extensions of each format
+var formatExtensions = map[string]string{
+	".json": FormatJSON, ".geojson": FormatJSON, ".webmanifest": FormatJSON,
+	".yaml": FormatYAML, ".yml": FormatYAML,
+	".toml": FormatTOML,
+	".xml":  FormatXML, ".plist": FormatXML, ".xsd": FormatXML, ".rss": FormatXML, ".atom": FormatXML,
+}
+
+// Keys that need no quoting in paths like .server.port
+var plainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Node is a value of a document: an object, array or XML element holding more
+// nodes, or a scalar
+type Node struct {
+	Key      string // Object key or XML name, empty for array items & the root
+	Index    int    // Position in the parent array, -1 for others
+	Path     string // jq style like .items[0].name, XPath like /feed/entry[2]/@id for XML
+	Kind     string
+	Value    string // Scalars as written, the text of XML elements with only text
+	Children []*Node
+}
+
+// Limits bound how much of a document is parsed. JSON & XML are parsed as they
+// are read, up to MaxBytes of them, while YAML & TOML documents over MaxBytes
+// aren't parsed at all. Parsing stops once there are MaxNodes nodes, and nodes
+// nested deeper than MaxDepth are left out.
+type Limits struct {
+	MaxBytes int64
+	MaxNodes int
+	MaxDepth int
+}
+
+// parser counts the nodes made against the limits
+type parser struct {
+	Limits
+	nodes     int
+	truncated bool
+	expanding map[*yaml.Node]bool // YAML collections being converted, to stop alias cycles
+}
+
+// Format tells the format of a file by its name, if it's a supported one
+func Format(name string) (string, bool) {
+	format, ok := formatExtensions[strings.ToLower(path.Ext(name))]
+	return format, ok
+}
+
+// Parse parses a document into a tree, reporting whether the tree was cut short
+// by the limits
+func Parse(format string, r io.Reader, limits Limits) (*Node, bool, error) {
+	p := &parser{Limits: limits}
+	src := &limitedReader{r: r, left: limits.MaxBytes}
+
+	var root *Node
+	var err error
+	switch format {
+	case FormatJSON:
+		root, err = p.parseJSON(src)
+	case FormatXML:
+		root, err = p.parseXML(src)
+	case FormatYAML, FormatTOML:
+		var data []byte
+		if data, err = io.ReadAll(src); err != nil {
+			return nil, false, err
+		}
+		if src.cut {
+			return nil, false, ErrTooLarge
+		}
+		if format == FormatYAML {
+			root, err = p.parseYAML(data)
+		} else {
+			root, err = p.parseTOML(data)
+		}
+	default:
+		return nil, false, ErrUnsupported
+	}
+
+	// Data cut off by the byte limit ends in a syntax error, the tree up to it is
+	// kept all the same
+	if errors.Is(err, errFull) || (err != nil && src.cut && root != nil) {
+		p.truncated, err = true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if format == FormatXML {
+		setXPaths(root, "")
+		root.Path = "/"
+	} else {
+		setPaths(root, ".")
+	}
+	return root, p.truncated, nil
+}
+
+// Tree helpers
+
+// node makes a node, nil for nodes nested too deep. Decoded documents skip the
+// children of those, streamed ones read past them. Once the tree is full it fails
+// with errFull.
+func (p *parser) node(key string, index, depth int, kind string) (*Node, error) {
+	if p.nodes >= p.MaxNodes {
+		return nil, errFull
+	}
+	if depth > p.MaxDepth {
+		p.truncated = true
+		return nil, nil
+	}
+	p.nodes++
+	return &Node{Key: key, Index: index, Kind: kind}, nil
+}
+
+// scalar makes a node holding a value
+func (p *parser) scalar(key string, index, depth int, kind, value string) (*Node, error) {
+	n, err := p.node(key, index, depth, kind)
+	if n != nil {
+		n.Value = value
+	}
+	return n, err
+}
+
+// add adds a child to a node, when both were made
+func (n *Node) add(child *Node) {
+	if n != nil && child != nil {
+		n.Children = append(n.Children, child)
+	}
+}
+
+// limitedReader reads up to left bytes, noting whether there was more
+type limitedReader struct {
+	r    io.Reader
+	left int64
+	cut  bool
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.left <= 0 {
+		// One more byte tells a cut off reader from one that just ended
+		var probe [1]byte
+		if n, _ := l.r.Read(probe[:]); n > 0 {
+			l.cut = true
+		}
+		return 0, io.EOF
+	}
+	if int64(len(b)) > l.left {
+		b = b[:l.left]
+	}
+	n, err := l.r.Read(b)
+	l.left -= int64(n)
+	return n, err
+}
+
+// setPaths sets jq style paths, quoting keys that aren't plain names
+func setPaths(n *Node, p string) {
+	n.Path = p
+	for _, child := range n.Children {
+		var childPath string
+		switch {
+		case child.Index >= 0:
+			childPath = strings.TrimSuffix(p, ".") + "[" + strconv.Itoa(child.Index) + "]"
+		case plainKey.MatchString(child.Key):
+			childPath = strings.TrimSuffix(p, ".") + "." + child.Key
+		default:
+			childPath = strings.TrimSuffix(p, ".") + "[" + strconv.Quote(child.Key) + "]"
+		}
+		if strings.HasPrefix(childPath, "[") {
+			childPath = "." + childPath
+		}
+		setPaths(child, childPath)
+	}
+}
+
+// setXPaths sets XPath paths, numbering elements that share their name with a
+// sibling
+func setXPaths(n *Node, p string) {
+	n.Path = p
+	counts := map[string]int{}
+	for _, child := range n.Children {
+		if child.Kind == KindElement {
+			counts[child.Key]++
+		}
+	}
+	seen := map[string]int{}
+	for _, child := range n.Children {
+		switch {
+		case child.Kind != KindElement:
+			setXPaths(child, p+"/"+child.Key)
+		case counts[child.Key] > 1:
+			seen[child.Key]++
+			setXPaths(child, p+"/"+child.Key+"["+strconv.Itoa(seen[child.Key])+"]")
+		default:
+			setXPaths(child, p+"/"+child.Key)
+		}
+	}
+}
+
+// Pretty lays a document out again with even indentation. TOML is written the
+// one way already, so it isn't supported.
+func Pretty(format string, data []byte) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return prettyJSON(data)
+	case FormatYAML:
+		return prettyYAML(data)
+	case FormatXML:
+		return prettyXML(data)
+	}
+	return nil, ErrUnsupported
+}
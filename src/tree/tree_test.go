@@ -0,0 +1,135 @@
+package tree
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var testLimits = Limits{MaxBytes: 1 << 20, MaxNodes: 1000, MaxDepth: 50}
+
+// flatten lists the paths & values of a tree's scalars, in document order
+func flatten(n *Node) []string {
+	var out []string
+	if len(n.Children) == 0 {
+		out = append(out, n.Path+"="+n.Value)
+	}
+	for _, child := range n.Children {
+		out = append(out, flatten(child)...)
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		format string
+		doc    string
+		want   string
+	}{
+		{
+			format: FormatJSON,
+			doc:    `{"server": {"port": 8080, "tls": false}, "names": ["a", null], "a b": 1.50}`,
+			want:   ".server.port=8080 .server.tls=false .names[0]=a .names[1]=null .[\"a b\"]=1.50",
+		},
+		{
+			format: FormatJSON,
+			doc:    "{\"id\": 1}\n{\"id\": 2}\n",
+			want:   ".[0].id=1 .[1].id=2",
+		},
+		{
+			format: FormatYAML,
+			doc:    "server:\n  port: 8080\n  host: &h example.org\nmirrors:\n  - *h\n  - ~\n",
+			want:   ".server.port=8080 .server.host=example.org .mirrors[0]=example.org .mirrors[1]=~",
+		},
+		{
+			format: FormatTOML,
+			doc:    "title = \"x\"\n[server]\nport = 8080\nhost = \"localhost\"\n[[jobs]]\nat = 1979-05-27\n",
+			want:   ".title=x .server.port=8080 .server.host=localhost .jobs[0].at=1979-05-27",
+		},
+		{
+			format: FormatXML,
+			doc:    `<?xml version="1.0"?><feed xmlns="urn:x"><entry id="1"><title>One</title></entry><entry id="2">Two <b>bold</b></entry></feed>`,
+			want:   "/feed/@xmlns=urn:x /feed/entry[1]/@id=1 /feed/entry[1]/title=One /feed/entry[2]/@id=2 /feed/entry[2]/text()=Two /feed/entry[2]/b=bold",
+		},
+	}
+	for _, tt := range tests {
+		root, truncated, err := Parse(tt.format, strings.NewReader(tt.doc), testLimits)
+		if err != nil || truncated {
+			t.Fatalf("Parse(%s) = %v, %v", tt.format, truncated, err)
+		}
+		if got := strings.Join(flatten(root), " "); got != tt.want {
+			t.Errorf("Parse(%s) = %s\nwant %s", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	doc := `{"items": [` + strings.Repeat(`{"n": 1}, `, 100) + `{"n": 1}]}`
+
+	// Cut off by the byte limit, the start of the document is still there
+	root, truncated, err := Parse(FormatJSON, strings.NewReader(doc), Limits{MaxBytes: 100, MaxNodes: 1000, MaxDepth: 50})
+	if err != nil || !truncated || len(root.Children[0].Children) == 0 {
+		t.Fatalf("Parse() over MaxBytes = %v, %v", truncated, err)
+	}
+
+	root, truncated, err = Parse(FormatJSON, strings.NewReader(doc), Limits{MaxBytes: 1 << 20, MaxNodes: 10, MaxDepth: 50})
+	if err != nil || !truncated || len(flatten(root)) > 10 {
+		t.Fatalf("Parse() over MaxNodes = %v, %v", truncated, err)
+	}
+
+	root, truncated, err = Parse(FormatJSON, strings.NewReader(doc), Limits{MaxBytes: 1 << 20, MaxNodes: 1000, MaxDepth: 2})
+	if err != nil || !truncated || len(root.Children[0].Children) != 0 {
+		t.Fatalf("Parse() over MaxDepth = %v, %v", truncated, err)
+	}
+
+	// YAML & TOML aren't parsed in part
+	if _, _, err := Parse(FormatYAML, strings.NewReader("a: 1\nb: 2\n"), Limits{MaxBytes: 5, MaxNodes: 10, MaxDepth: 5}); err != ErrTooLarge {
+		t.Fatalf("Parse(yaml) over MaxBytes = %v, want ErrTooLarge", err)
+	}
+
+	// Aliases expand within the limits, even when they point at themselves
+	root, truncated, err = Parse(FormatYAML, strings.NewReader("a: &a [1, *a]\n"), testLimits)
+	if got := flatten(root); err != nil || !truncated || !slices.Equal(got, []string{".a[0]=1"}) {
+		t.Fatalf("Parse(recursive alias) = %v, %v, %v", got, truncated, err)
+	}
+	var laughs strings.Builder
+	laughs.WriteString("a0: &a0 [lol, lol]\n")
+	for i := 1; i < 40; i++ {
+		fmt.Fprintf(&laughs, "a%d: &a%d [*a%d, *a%d]\n", i, i, i-1, i-1)
+	}
+	root, truncated, err = Parse(FormatYAML, strings.NewReader(laughs.String()), Limits{MaxBytes: 1 << 20, MaxNodes: 5000, MaxDepth: 10})
+	if err != nil || !truncated || len(flatten(root)) > 5000 {
+		t.Fatalf("Parse(exponential aliases) = %d nodes, %v, %v", len(flatten(root)), truncated, err)
+	}
+
+	// Broken documents within the limits fail
+	if _, _, err := Parse(FormatJSON, strings.NewReader(`{"a": [1, 2`), testLimits); err == nil {
+		t.Fatal("Parse() of broken JSON succeeded")
+	}
+}
+
+func TestPretty(t *testing.T) {
+	tests := []struct {
+		format string
+		doc    string
+		want   string
+	}{
+		{format: FormatJSON, doc: `{"a":[1,2]}`, want: "{\n  \"a\": [\n    1,\n    2\n  ]\n}\n"},
+		{format: FormatYAML, doc: "a:\n    - 1 # one\n", want: "a:\n  - 1 # one\n"},
+		{
+			format: FormatXML,
+			doc:    `<?xml version="1.0"?><a x="1&amp;2"><b>text</b><c/><!-- note --></a>`,
+			want:   "<?xml version=\"1.0\"?>\n<a x=\"1&amp;2\">\n  <b>text</b>\n  <c></c>\n  <!-- note -->\n</a>\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := Pretty(tt.format, []byte(tt.doc))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Pretty(%s) = %q, %v\nwant %q", tt.format, got, err, tt.want)
+		}
+	}
+	if _, err := Pretty(FormatTOML, []byte("a = 1")); err != ErrUnsupported {
+		t.Errorf("Pretty(toml) = %v, want ErrUnsupported", err)
+	}
+}
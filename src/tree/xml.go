@@ -0,0 +1,181 @@
+package tree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Keys of the text within XML elements
+const xmlText = "text()"
+
+// Escaping of text & attribute values, leaving line breaks as they are
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// parseXML parses XML a token at a time into a document node holding the root
+// element. Attributes are keyed @name & text text(), elements holding only text
+// take it as their value.
+func (p *parser) parseXML(r io.Reader) (*Node, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil // Shown as is rather than not at all
+	}
+
+	doc := &Node{Index: -1, Kind: KindElement}
+	stack := []*Node{doc} // Open elements, nil for those left out
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return doc, err
+		}
+
+		parent, depth := stack[len(stack)-1], len(stack)
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var n *Node
+			if parent != nil {
+				if n, err = p.node(tok.Name.Local, -1, depth, KindElement); err != nil {
+					return doc, err
+				}
+			}
+			parent.add(n)
+			for _, attr := range tok.Attr {
+				if n == nil {
+					break
+				}
+				child, err := p.scalar("@"+attrName(attr.Name), -1, depth+1, KindString, attr.Value)
+				n.add(child)
+				if err != nil {
+					return doc, err
+				}
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if n := stack[len(stack)-1]; n != nil && len(n.Children) == 1 && n.Children[0].Key == xmlText {
+				n.Value, n.Children = n.Children[0].Value, nil
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			text := strings.TrimSpace(string(tok))
+			if text == "" || parent == nil || parent == doc {
+				continue
+			}
+			child, err := p.scalar(xmlText, -1, depth, KindString, text)
+			parent.add(child)
+			if err != nil {
+				return doc, err
+			}
+		}
+	}
+	if len(doc.Children) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return doc, nil
+}
+
+// XML helpers
+
+// attrName names attributes by their local name, keeping the prefix of namespace
+// declarations
+func attrName(name xml.Name) string {
+	if name.Space == "xmlns" {
+		return "xmlns:" + name.Local
+	}
+	return name.Local
+}
+
+// prettyXML indents elements by their depth, keeping text, comments & the rest.
+// Elements holding only text stay on one line.
+func prettyXML(data []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	// The raw tokens keep the prefixes as written
+	var tokens []xml.Token
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if text, ok := tok.(xml.CharData); ok && len(bytes.TrimSpace(text)) == 0 {
+			continue
+		}
+		tokens = append(tokens, xml.CopyToken(tok))
+	}
+
+	var out bytes.Buffer
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		if _, ok := tokens[i].(xml.EndElement); ok {
+			depth--
+		}
+		out.WriteString(strings.Repeat("  ", max(0, depth)))
+
+		switch tok := tokens[i].(type) {
+		case xml.StartElement:
+			out.WriteString("<" + rawName(tok.Name))
+			for _, attr := range tok.Attr {
+				out.WriteString(" " + rawName(attr.Name) + `="` + attrEscaper.Replace(attr.Value) + `"`)
+			}
+			out.WriteByte('>')
+
+			// Empty elements & ones holding only text close on the same line
+			if i+1 < len(tokens) {
+				if end, ok := tokens[i+1].(xml.EndElement); ok {
+					out.WriteString("</" + rawName(end.Name) + ">")
+					i++
+					continue
+				}
+			}
+			if i+2 < len(tokens) {
+				text, isText := tokens[i+1].(xml.CharData)
+				end, isEnd := tokens[i+2].(xml.EndElement)
+				if isText && isEnd {
+					out.WriteString(textEscaper.Replace(string(bytes.TrimSpace(text))) + "</" + rawName(end.Name) + ">")
+					i += 2
+					continue
+				}
+			}
+			depth++
+		case xml.EndElement:
+			out.WriteString("</" + rawName(tok.Name) + ">")
+		case xml.CharData:
+			out.WriteString(textEscaper.Replace(string(bytes.TrimSpace(tok))))
+		case xml.Comment:
+			out.WriteString("<!--" + string(tok) + "-->")
+		case xml.ProcInst:
+			out.WriteString("<?" + tok.Target)
+			if len(tok.Inst) > 0 {
+				out.WriteString(" " + string(tok.Inst))
+			}
+			out.WriteString("?>")
+		case xml.Directive:
+			out.WriteString("<!" + string(tok) + ">")
+		}
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+func rawName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
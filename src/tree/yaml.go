@@ -0,0 +1,142 @@
+package tree
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"io"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// parseYAML parses each document of a stream, several of which make up an array
+func (p *parser) parseYAML(data []byte) (*Node, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+
+	var docs []*Node
+	for i := 0; ; i++ {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		n, err := p.yamlValue(&doc, "", i, 1)
+		if n != nil {
+			docs = append(docs, n)
+		}
+		if err != nil {
+			return wrapValues(docs), err
+		}
+	}
+
+	switch len(docs) {
+	case 0:
+		return &Node{Index: -1, Kind: KindNull, Value: "null"}, nil
+	case 1:
+		docs[0].Index = -1
+		return docs[0], nil
+	}
+	return wrapValues(docs), nil
+}
+
+// yamlValue converts a YAML node, following aliases to what they point at. An
+// alias inside the node it points at is left out like a node nested too deep.
+func (p *parser) yamlValue(y *yaml.Node, key string, index, depth int) (*Node, error) {
+	for y.Kind == yaml.DocumentNode || y.Kind == yaml.AliasNode {
+		switch {
+		case y.Kind == yaml.AliasNode && y.Alias != nil:
+			y = y.Alias
+		case y.Kind == yaml.DocumentNode && len(y.Content) > 0:
+			y = y.Content[0]
+		default:
+			return p.scalar(key, index, depth, KindNull, "null")
+		}
+	}
+	if p.expanding[y] {
+		p.truncated = true
+		return nil, nil
+	}
+
+	switch y.Kind {
+	case yaml.MappingNode:
+		n, err := p.node(key, index, depth, KindObject)
+		if n == nil || err != nil {
+			return nil, err
+		}
+		defer p.expand(y)()
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			child, err := p.yamlValue(y.Content[i+1], y.Content[i].Value, -1, depth+1)
+			n.add(child)
+			if err != nil {
+				return n, err
+			}
+		}
+		return n, nil
+	case yaml.SequenceNode:
+		n, err := p.node(key, index, depth, KindArray)
+		if n == nil || err != nil {
+			return nil, err
+		}
+		defer p.expand(y)()
+		for i, item := range y.Content {
+			child, err := p.yamlValue(item, "", i, depth+1)
+			n.add(child)
+			if err != nil {
+				return n, err
+			}
+		}
+		return n, nil
+	}
+
+	switch y.ShortTag() {
+	case "!!int", "!!float":
+		return p.scalar(key, index, depth, KindNumber, y.Value)
+	case "!!bool":
+		return p.scalar(key, index, depth, KindBool, y.Value)
+	case "!!null":
+		return p.scalar(key, index, depth, KindNull, cmp.Or(y.Value, "null"))
+	}
+	return p.scalar(key, index, depth, KindString, y.Value)
+}
+
+// YAML helpers
+
+// expand marks a collection as being converted until the returned func is called
+func (p *parser) expand(y *yaml.Node) func() {
+	if p.expanding == nil {
+		p.expanding = map[*yaml.Node]bool{}
+	}
+	p.expanding[y] = true
+	return func() {
+		delete(p.expanding, y)
+	}
+}
+
+// prettyYAML writes each document of a stream again, indented the same way
+// throughout & keeping comments
+func prettyYAML(data []byte) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}